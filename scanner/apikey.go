@@ -0,0 +1,150 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiKeySource supplies the Moltbook API key for each request
+type apiKeySource interface {
+	// get returns the key to use for the next request
+	get() string
+	// rotate is called after the API rejected rejectedKey and reports
+	// whether a different key is now available, so the request is worth
+	// retrying
+	rotate(rejectedKey string) bool
+}
+
+// Bounds for how long a rejected, unchanged API key is trusted before the
+// key source is checked again
+const (
+	minAuthBackoff = 30 * time.Second
+	maxAuthBackoff = 10 * time.Minute
+)
+
+// rotatingKey holds the Moltbook API key, which may be replaced in place
+// (e.g. a rotated MOLTBOOK_API_KEY_FILE) while the scanner runs
+type rotatingKey struct {
+	mu      sync.Mutex
+	key     string
+	backoff time.Duration
+	retryAt time.Time // no reloads before this after an unchanged reload
+}
+
+func newRotatingKey(key string) *rotatingKey {
+	return &rotatingKey{key: key}
+}
+
+func (k *rotatingKey) get() string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.key
+}
+
+// rotate is called after the API rejected rejectedKey. It reloads the key
+// from MOLTBOOK_API_KEY/MOLTBOOK_API_KEY_FILE and reports whether a
+// different key is now in use, so the request is worth retrying. Reloads
+// that yield the same key back off exponentially instead of hot-looping.
+func (k *rotatingKey) rotate(rejectedKey string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	// Another request already rotated the key
+	if k.key != rejectedKey {
+		return true
+	}
+
+	now := time.Now()
+	if now.Before(k.retryAt) {
+		return false
+	}
+
+	key, err := getSecretEnv("MOLTBOOK_API_KEY")
+	if err != nil || key == "" || key == k.key {
+		k.backoff = min(max(2*k.backoff, minAuthBackoff), maxAuthBackoff)
+		k.retryAt = now.Add(k.backoff)
+		if err != nil {
+			log.Printf("Moltbook API rejected the API key and reloading it failed: %v (next reload in %s)", err, k.backoff)
+		} else {
+			log.Printf("Moltbook API rejected the API key and it is unchanged on reload (next reload in %s)", k.backoff)
+		}
+		return false
+	}
+
+	log.Printf("🔄 Moltbook API key rotation detected, now using %s", maskKey(key))
+	k.key = key
+	k.backoff = 0
+	k.retryAt = time.Time{}
+	return true
+}
+
+// keyPool spreads requests round-robin over the MOLTBOOK_API_KEYS keys. A
+// key the API rejects is taken out of rotation until every key has been
+// rejected, at which point all of them are tried again. Keys are only ever
+// referred to by position in logs.
+type keyPool struct {
+	mu       sync.Mutex
+	keys     []string
+	disabled []bool
+	next     int
+}
+
+func newKeyPool(keys []string) *keyPool {
+	return &keyPool{keys: keys, disabled: make([]bool, len(keys))}
+}
+
+// parseAPIKeys splits a MOLTBOOK_API_KEYS value on commas and newlines,
+// dropping blanks and duplicates
+func parseAPIKeys(raw string) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, key := range strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == '\n' }) {
+		key = strings.TrimSpace(key)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (p *keyPool) get() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for range p.keys {
+		i := p.next
+		p.next = (p.next + 1) % len(p.keys)
+		if !p.disabled[i] {
+			return p.keys[i]
+		}
+	}
+
+	log.Printf("Moltbook API rejected all %d API keys, trying them all again", len(p.keys))
+	for i := range p.disabled {
+		p.disabled[i] = false
+	}
+	i := p.next
+	p.next = (p.next + 1) % len(p.keys)
+	return p.keys[i]
+}
+
+func (p *keyPool) rotate(rejectedKey string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	enabled := 0
+	for i, key := range p.keys {
+		if key == rejectedKey && !p.disabled[i] {
+			p.disabled[i] = true
+			log.Printf("Moltbook API rejected API key #%d of %d, taking it out of rotation", i+1, len(p.keys))
+		}
+		if !p.disabled[i] {
+			enabled++
+		}
+	}
+	return enabled > 0
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Attachment is a file the Moltbook API returns inline with a post, its
+// content base64-encoded in Data, possibly as a data: URL
+type Attachment struct {
+	Filename string `json:"filename"`
+	MimeType string `json:"mime_type"`
+	Data     string `json:"data"`
+}
+
+// attachmentLimits bounds how much of a post's inline attachments is
+// decoded and scanned
+type attachmentLimits struct {
+	maxCount int
+	maxBytes int
+}
+
+// newAttachmentLimitsFromEnv returns nil when SCAN_ATTACHMENTS is disabled
+func newAttachmentLimitsFromEnv() *attachmentLimits {
+	if !getEnvBoolOrDefault("SCAN_ATTACHMENTS", true) {
+		log.Printf("Attachment scanning disabled (SCAN_ATTACHMENTS=false)")
+		return nil
+	}
+	return &attachmentLimits{
+		maxCount: getEnvIntOrDefault("ATTACHMENT_MAX_COUNT", 5),
+		maxBytes: getEnvIntOrDefault("ATTACHMENT_MAX_BYTES", 256*1024),
+	}
+}
+
+// textMimeTypes are the non-text/* types scanned as text
+var textMimeTypes = map[string]bool{
+	"application/json":       true,
+	"application/xml":        true,
+	"application/yaml":       true,
+	"application/x-yaml":     true,
+	"application/toml":       true,
+	"application/javascript": true,
+	"application/x-sh":       true,
+}
+
+// isTextMimeType reports whether attachments of mimeType are scanned
+func isTextMimeType(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "text/") || textMimeTypes[mimeType]
+}
+
+// decode returns the text of the first maxBytes of a, or an error if a is
+// not a text attachment. Attachments without a type are sniffed.
+func (l *attachmentLimits) decode(a Attachment) (string, error) {
+	data, mimeType := strings.TrimSpace(a.Data), a.MimeType
+	if rest, ok := strings.CutPrefix(data, "data:"); ok {
+		header, payload, found := strings.Cut(rest, ",")
+		if !found || !strings.HasSuffix(header, ";base64") {
+			return "", fmt.Errorf("not a base64 data URL")
+		}
+		if mimeType == "" {
+			mimeType = strings.TrimSuffix(header, ";base64")
+		}
+		data = payload
+	}
+	if mimeType != "" {
+		parsed, _, err := mime.ParseMediaType(mimeType)
+		if err != nil {
+			return "", fmt.Errorf("invalid type %q", mimeType)
+		}
+		if !isTextMimeType(parsed) {
+			return "", fmt.Errorf("binary type %s", parsed)
+		}
+	}
+
+	// Only the first maxBytes are decoded; a whole number of base64 groups
+	// keeps the cut from corrupting the last one
+	if limit := base64.StdEncoding.EncodedLen(l.maxBytes); len(data) > limit {
+		data = data[:limit]
+	}
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		if decoded, err = base64.RawStdEncoding.DecodeString(data); err != nil {
+			return "", fmt.Errorf("invalid base64: %w", err)
+		}
+	}
+	if mimeType == "" && !strings.HasPrefix(http.DetectContentType(decoded), "text/") {
+		return "", fmt.Errorf("binary content")
+	}
+	return string(decoded), nil
+}
+
+// scanPostAttachments decodes the text attachments of post and returns
+// their findings, with Location set to LocationAttachment and SourceURL to
+// "attachment:<filename>". Keys already in known are not reported again.
+func (s *Scanner) scanPostAttachments(post MoltbookPost, known []APIKeyFinding) []APIKeyFinding {
+	if s.attachments == nil || len(post.Attachments) == 0 {
+		return nil
+	}
+
+	reported := make(map[string]bool)
+	for _, f := range known {
+		reported[f.APIKey] = true
+	}
+
+	var findings []APIKeyFinding
+	for i, a := range post.Attachments {
+		if i >= s.attachments.maxCount {
+			logDebug("Skipping %d attachments of post %s over ATTACHMENT_MAX_COUNT", len(post.Attachments)-i, post.ID)
+			break
+		}
+		name := a.Filename
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		text, err := s.attachments.decode(a)
+		if err != nil {
+			logDebug("Skipping attachment %s of post %s: %v", name, post.ID, err)
+			continue
+		}
+
+		// Scan the attachment as if it were the post's content
+		attached := post
+		attached.Title = ""
+		attached.Content = text
+		for _, f := range s.ScanPost(attached) {
+			if reported[f.APIKey] {
+				continue
+			}
+			reported[f.APIKey] = true
+			f.PostTitle = post.Title
+			f.Location = LocationAttachment
+			f.SourceURL = "attachment:" + name
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// authHeaderPattern matches an Authorization header with a Bearer, Basic or
+// API key credential, as pasted in curl commands and HTTP snippets. Group 1
+// is the scheme and group 2 the credential.
+var authHeaderPattern = regexp.MustCompile(`(?i)\bauthorization\s*:\s*(bearer|basic|api[-_]?key|token)\s+([A-Za-z0-9._~+/=-]{8,})`)
+
+// lineContinuation is a shell backslash-newline with the next line's indent
+var lineContinuation = regexp.MustCompile(`\\\r?\n[ \t]*`)
+
+// placeholderCredential matches stand-ins such as YOUR_API_TOKEN
+var placeholderCredential = regexp.MustCompile(`^[A-Z_]+$`)
+
+// matchAuthHeaders appends an AuthorizationHeader match for each
+// Authorization header in text with a real-looking credential, after
+// joining shell line continuations. Like DatabaseConnectionString matches,
+// the Key is the header with the credential masked, e.g.
+// "Authorization: Basic alice:pa********rd", and Parts holds the credential
+// as written so excerpts mask it. Basic credentials are decoded to report
+// the user name.
+//
+// A credential some other pattern already typed (e.g. an OpenAI key sent as
+// a bearer token) is left to that match. Generic matches inside the header
+// are replaced, as they only capture the start of a token with dots in it,
+// such as a JWT, which is why the number of matches added is returned
+// separately.
+func matchAuthHeaders(text, encoding string, foundKeys map[string]bool, matches []KeyMatch) ([]KeyMatch, int) {
+	added := 0
+	joined := lineContinuation.ReplaceAllString(text, " ")
+	for _, loc := range authHeaderPattern.FindAllStringSubmatchIndex(joined, -1) {
+		header, scheme, credential := joined[loc[0]:loc[1]], joined[loc[2]:loc[3]], joined[loc[4]:loc[5]]
+
+		var masked string
+		switch strings.ToLower(scheme) {
+		case "basic":
+			user, password, ok := decodeBasicCredential(credential)
+			if !ok || isPlaceholderPassword(password) {
+				continue
+			}
+			masked = "Basic " + user + ":" + maskKey(password)
+		case "bearer":
+			if !realLookingToken(credential) {
+				continue
+			}
+			masked = "Bearer " + maskKey(credential)
+		default:
+			if !realLookingToken(credential) {
+				continue
+			}
+			masked = scheme + " " + maskKey(credential)
+		}
+
+		typed := false
+		kept := matches[:0]
+		for _, m := range matches {
+			switch {
+			case m.Type == "Generic" && strings.Contains(header, m.Key):
+				continue
+			case strings.Contains(credential, m.Key):
+				typed = true
+			}
+			kept = append(kept, m)
+		}
+		matches = kept
+		if typed {
+			continue
+		}
+
+		key := "Authorization: " + masked
+		if foundKeys[key] {
+			continue
+		}
+		foundKeys[key] = true
+		matches = append(matches, KeyMatch{
+			Key:      key,
+			Type:     "AuthorizationHeader",
+			Encoding: encoding,
+			Parts:    []string{credential},
+			source:   joined,
+		})
+		added++
+	}
+	return matches, added
+}
+
+// decodeBasicCredential decodes a Basic credential into its user name and
+// password
+func decodeBasicCredential(credential string) (user, password string, ok bool) {
+	decoded, err := base64.StdEncoding.DecodeString(credential)
+	if err != nil || !utf8.Valid(decoded) {
+		return "", "", false
+	}
+	user, password, ok = strings.Cut(string(decoded), ":")
+	if !ok || password == "" {
+		return "", "", false
+	}
+	return user, password, true
+}
+
+// realLookingToken reports whether a bearer or API key credential is long
+// enough to be one and not a stand-in such as YOUR_TOKEN or "xxxxxxxx"
+func realLookingToken(token string) bool {
+	return len(token) >= 16 && !placeholderCredential.MatchString(token) && !isPlaceholderPassword(token)
+}
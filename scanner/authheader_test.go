@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestMatchAuthHeaders(t *testing.T) {
+	token := "Zx8" + strings.Repeat("kQ2mV9", 6)
+	basic := base64.StdEncoding.EncodeToString([]byte("alice:s3cretPassw0rd"))
+
+	tests := []struct {
+		name       string
+		text       string
+		key        string // "" if nothing may be reported
+		credential string // the credential as written
+	}{
+		{
+			name:       "bearer token in curl",
+			text:       `curl -H "Authorization: Bearer ` + token + `" https://api.example.com/v1/me`,
+			key:        "Authorization: Bearer " + maskKey(token),
+			credential: token,
+		},
+		{
+			name:       "Basic credential decoded",
+			text:       "Authorization: Basic " + basic,
+			key:        "Authorization: Basic alice:" + maskKey("s3cretPassw0rd"),
+			credential: basic,
+		},
+		{
+			name:       "API key scheme",
+			text:       "authorization:ApiKey " + token,
+			key:        "Authorization: ApiKey " + maskKey(token),
+			credential: token,
+		},
+		{
+			name:       "header split by a backslash continuation",
+			text:       "curl https://api.example.com \\\n  -H \"Authorization: Basic \\\n    " + basic + "\" \\\n  -d '{}'",
+			key:        "Authorization: Basic alice:" + maskKey("s3cretPassw0rd"),
+			credential: basic,
+		},
+		{
+			name:       "CRLF continuation",
+			text:       "-H 'Authorization: Bearer \\\r\n\t" + token + "'",
+			key:        "Authorization: Bearer " + maskKey(token),
+			credential: token,
+		},
+		{name: "placeholder token", text: "Authorization: Bearer YOUR_API_TOKEN"},
+		{name: "token of x's", text: "Authorization: Bearer " + strings.Repeat("x", 32)},
+		{name: "short token", text: "Authorization: Bearer abc123def"},
+		{name: "shell variable", text: `-H "Authorization: Bearer $TOKEN"`},
+		{name: "Basic placeholder password", text: "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("user:password"))},
+		{name: "Basic without a password", text: "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("alice-no-colon"))},
+		{name: "Basic that isn't base64", text: "Authorization: Basic not-base64-at-all"},
+		{name: "continuation without a header", text: "Bearer \\\n  " + token},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			matches, added := matchAuthHeaders(tc.text, "", map[string]bool{}, nil)
+			if tc.key == "" {
+				if len(matches) > 0 || added != 0 {
+					t.Errorf("matched %q (%d added), want nothing", matchKeys(matches), added)
+				}
+				return
+			}
+			if len(matches) != 1 || added != 1 {
+				t.Fatalf("matched %q (%d added), want one match", matchKeys(matches), added)
+			}
+			m := matches[0]
+			if m.Type != "AuthorizationHeader" || m.Key != tc.key {
+				t.Errorf("matched %s %q, want AuthorizationHeader %q", m.Type, m.Key, tc.key)
+			}
+			if len(m.Parts) != 1 || m.Parts[0] != tc.credential {
+				t.Errorf("Parts = %q, want [%q]", m.Parts, tc.credential)
+			}
+		})
+	}
+}
+
+func TestAuthHeaderDefersToTypedKeys(t *testing.T) {
+	s := newPatternScanner()
+
+	// A key another pattern types is reported as that type alone
+	openAIKey := "sk-" + strings.Repeat("aB3dE5fG7h", 4) + "T3BlbkFJ" + strings.Repeat("k9", 10)
+	matches := s.ScanText("Authorization: Bearer " + openAIKey)
+	if len(matches) != 1 || matches[0].Key != openAIKey || matches[0].Type == "AuthorizationHeader" {
+		t.Errorf("bearer OpenAI key reported as %v %q, want its own type alone", matchTypes(matches), matchKeys(matches))
+	}
+
+	// A JWT replaces the Generic match of its start
+	jwt := "eyJhbGciOiJIUzI1NiJ9." + strings.Repeat("eyJzdWIiOiIx", 3) + "." + strings.Repeat("c2lnbmF0dXJl", 3)
+	text := "curl -H 'Authorization: Bearer " + jwt + "'"
+	matches = s.ScanText(text)
+	types := strings.Join(matchTypes(matches), ",")
+	if types != "AuthorizationHeader" {
+		t.Errorf("ScanText(%q) types = %s, want AuthorizationHeader alone", text, types)
+	}
+	if masked := maskKeys(text, matchKeys(matches)); strings.Contains(masked, jwt) {
+		t.Errorf("masked content still holds the token: %q", masked)
+	}
+}
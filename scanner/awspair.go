@@ -0,0 +1,73 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	awsAccessKeyIDPattern = regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)
+	// awsSecretPattern matches a 40-character base64 run not embedded in a
+	// longer one. On its own this is far too noisy to report.
+	awsSecretPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9+/=])([A-Za-z0-9+/]{40})(?:[^A-Za-z0-9+/=]|$)`)
+)
+
+// matchAWSKeyPairs appends an AWSKeyPair match for each AWS access key ID in
+// text that has a secret-shaped string nearby, pairing it with the closest
+// one. The pair's Key is "<access key ID>:<secret>" and Parts holds both.
+func matchAWSKeyPairs(text, encoding string, foundKeys map[string]bool, matches []KeyMatch) []KeyMatch {
+	idLocs := awsAccessKeyIDPattern.FindAllStringIndex(text, -1)
+	if len(idLocs) == 0 {
+		return matches
+	}
+
+	var secretLocs [][]int
+	for _, loc := range awsSecretPattern.FindAllStringSubmatchIndex(text, -1) {
+		if looksLikeAWSSecret(text[loc[2]:loc[3]]) {
+			secretLocs = append(secretLocs, loc[2:4])
+		}
+	}
+	if len(secretLocs) == 0 {
+		return matches
+	}
+
+	for _, idLoc := range idLocs {
+		closest := secretLocs[0]
+		for _, loc := range secretLocs[1:] {
+			if distance(idLoc, loc) < distance(idLoc, closest) {
+				closest = loc
+			}
+		}
+
+		id, secret := text[idLoc[0]:idLoc[1]], text[closest[0]:closest[1]]
+		key := id + ":" + secret
+		if foundKeys[key] {
+			continue
+		}
+		foundKeys[key] = true
+		matches = append(matches, KeyMatch{
+			Key:      key,
+			Type:     "AWSKeyPair",
+			Encoding: encoding,
+			Parts:    []string{id, secret},
+			source:   text,
+		})
+	}
+	return matches
+}
+
+// looksLikeAWSSecret rejects 40-character runs that are unlikely to be
+// random base64, such as hex digests or words
+func looksLikeAWSSecret(s string) bool {
+	return strings.ContainsAny(s, "abcdefghijklmnopqrstuvwxyz") &&
+		strings.ContainsAny(s, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") &&
+		strings.ContainsAny(s, "0123456789+/")
+}
+
+// distance returns the gap in bytes between two [start, end) spans
+func distance(a, b []int) int {
+	if a[1] <= b[0] {
+		return b[0] - a[1]
+	}
+	return a[0] - b[1]
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// pendingCommentPost is a post whose comments could not be fetched
+type pendingCommentPost struct {
+	post     MoltbookPost
+	attempts int
+}
+
+// commentBackfill tracks posts whose comment fetch failed. The post itself
+// is already in the seen set, so without it a transient API error would mean
+// its comments are never fetched.
+type commentBackfill struct {
+	mu          sync.Mutex
+	posts       map[string]*pendingCommentPost
+	maxAttempts int
+}
+
+func newCommentBackfill(maxAttempts int) *commentBackfill {
+	return &commentBackfill{
+		posts:       make(map[string]*pendingCommentPost),
+		maxAttempts: maxAttempts,
+	}
+}
+
+// add records a failed comment fetch for post
+func (b *commentBackfill) add(post MoltbookPost) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.posts[post.ID]; !ok {
+		b.posts[post.ID] = &pendingCommentPost{post: post, attempts: 1}
+	}
+}
+
+// pending returns a snapshot of the posts awaiting a retry
+func (b *commentBackfill) pending() []MoltbookPost {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	posts := make([]MoltbookPost, 0, len(b.posts))
+	for _, p := range b.posts {
+		posts = append(posts, p.post)
+	}
+	return posts
+}
+
+// done removes a post whose comments were fetched
+func (b *commentBackfill) done(postID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.posts, postID)
+}
+
+// failed counts another failed attempt for a post and reports whether it was
+// dropped for reaching maxAttempts
+func (b *commentBackfill) failed(postID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.posts[postID]
+	if !ok {
+		return false
+	}
+	p.attempts++
+	if p.attempts >= b.maxAttempts {
+		delete(b.posts, postID)
+		return true
+	}
+	return false
+}
+
+// retryPendingComments fetches the comments of posts whose earlier fetch
+// failed, giving up on a post after COMMENT_RETRY_MAX_ATTEMPTS attempts
+func (s *Scanner) retryPendingComments(ctx context.Context, newMessages *int, newComments *int, totalFindings *int, saveErrors *int, tooOld *int) {
+	for _, post := range s.commentBackfill.pending() {
+		if s.stopping() {
+			return
+		}
+		if s.scanPostComments(ctx, post, newMessages, newComments, totalFindings, saveErrors, tooOld) {
+			logCycleDebug(ctx, "Fetched comments of post %s on retry", post.ID)
+			s.commentBackfill.done(post.ID)
+			continue
+		}
+		if s.commentBackfill.failed(post.ID) {
+			log.Printf("Warning: giving up on comments of post %s after %d failed fetches", post.ID, s.commentBackfill.maxAttempts)
+		}
+	}
+}
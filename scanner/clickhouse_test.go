@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// fakeConn is a ClickHouse connection that records the statements it is
+// sent and answers queries with the rows the rows func returns. Methods it
+// doesn't implement panic through the nil embedded driver.Conn.
+type fakeConn struct {
+	driver.Conn
+
+	mu    sync.Mutex
+	execs []fakeStatement
+	rows  func(query string, args []any) [][]any
+}
+
+// fakeStatement is a statement sent to a fakeConn
+type fakeStatement struct {
+	query string
+	args  []any
+}
+
+func (c *fakeConn) Exec(ctx context.Context, query string, args ...any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.execs = append(c.execs, fakeStatement{query: query, args: args})
+	return nil
+}
+
+func (c *fakeConn) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	if c.rows == nil {
+		return &fakeRows{}, nil
+	}
+	return &fakeRows{rows: c.rows(query, args)}, nil
+}
+
+func (c *fakeConn) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
+	rows := &fakeRows{}
+	if c.rows != nil {
+		rows.rows = c.rows(query, args)
+	}
+	return fakeRow{rows}
+}
+
+// inserts returns the statements that inserted into table, the unquoted
+// table name without database or prefix
+func (c *fakeConn) inserts(table string) []fakeStatement {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []fakeStatement
+	for _, st := range c.execs {
+		_, rest, ok := strings.Cut(st.query, "INSERT INTO ")
+		if fields := strings.Fields(rest); ok && len(fields) > 0 && strings.HasSuffix(fields[0], ".`"+table+"`") {
+			out = append(out, st)
+		}
+	}
+	return out
+}
+
+// fakeRows are query results scanned by assigning each value to the
+// destination of the same position
+type fakeRows struct {
+	driver.Rows
+
+	rows [][]any
+	next int
+}
+
+func (r *fakeRows) Next() bool {
+	if r.next >= len(r.rows) {
+		return false
+	}
+	r.next++
+	return true
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	row := r.rows[r.next-1]
+	if len(row) != len(dest) {
+		return fmt.Errorf("row has %d columns, scanned into %d", len(row), len(dest))
+	}
+	for i, v := range row {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(v))
+	}
+	return nil
+}
+
+func (r *fakeRows) Close() error { return nil }
+func (r *fakeRows) Err() error   { return nil }
+
+// fakeRow is the first of some fakeRows, as QueryRow returns it
+type fakeRow struct {
+	rows *fakeRows
+}
+
+func (r fakeRow) Err() error { return nil }
+
+func (r fakeRow) Scan(dest ...any) error {
+	if !r.rows.Next() {
+		return sql.ErrNoRows
+	}
+	return r.rows.Scan(dest...)
+}
+
+func (r fakeRow) ScanStruct(dest any) error {
+	return fmt.Errorf("ScanStruct is not supported")
+}
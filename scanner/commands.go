@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// runCommand runs a one-off subcommand instead of the scan loop
+func runCommand(ctx context.Context, s *Scanner, name string, args []string) error {
+	if err := s.InitDatabase(ctx); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	switch name {
+	case "reprocess":
+		return s.runReprocess(ctx, args)
+	case "export":
+		return s.runExport(ctx, args)
+	case "scan-author":
+		return s.runScanAuthor(ctx, args)
+	case "promote":
+		return s.runPromote(ctx, args)
+	case "replay-dlq":
+		return s.runReplayDLQ(ctx, args)
+	case "selftest":
+		return s.runSelfTest(ctx, args)
+	case "prune":
+		return s.runPrune(ctx, args)
+	default:
+		return fmt.Errorf("unknown command %q (available: reprocess, export, scan-author, promote, replay-dlq, selftest, prune)", name)
+	}
+}
+
+// reprocessBatchSize is how many stored messages are read per query
+const reprocessBatchSize = 1000
+
+// runReprocess re-scans every stored message with the current patterns and
+// saves findings that aren't already recorded for the same post. Progress
+// is written to a checkpoint file after each batch so an interrupted run
+// can resume with -resume.
+func (s *Scanner) runReprocess(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("reprocess", flag.ContinueOnError)
+	checkpointFile := fs.String("checkpoint", "reprocess.checkpoint", "file recording the last processed message ID")
+	resume := fs.Bool("resume", false, "continue after the ID in the checkpoint file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	lastID := ""
+	if *resume {
+		data, err := os.ReadFile(*checkpointFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to read checkpoint: %w", err)
+		}
+		lastID = strings.TrimSpace(string(data))
+		if lastID != "" {
+			log.Printf("Resuming reprocess after message %s", lastID)
+		}
+	}
+
+	existing, err := s.loadFindingFingerprints(ctx)
+	if err != nil {
+		return err
+	}
+
+	var total uint64
+	if err := s.clickhouseConn.QueryRow(ctx, fmt.Sprintf(`SELECT uniqExact(id) FROM %s WHERE id > ?`, s.table("messages")), lastID).Scan(&total); err != nil {
+		return fmt.Errorf("failed to count messages: %w", err)
+	}
+	log.Printf("Reprocessing %d messages with %d patterns", total, len(s.apiKeyPatterns))
+
+	processed, newFindings, saveErrors := 0, 0, 0
+	started := time.Now()
+
+	for {
+		if ctx.Err() != nil {
+			log.Printf("Reprocess interrupted after %d messages; rerun with -resume to continue", processed)
+			return nil
+		}
+
+		batch, err := s.loadMessageBatch(ctx, lastID, reprocessBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, msg := range batch {
+			for _, finding := range s.rescanMessage(msg) {
+				fingerprint := finding.PostID + ":" + hashKey(finding.APIKey)
+				if existing[fingerprint] {
+					continue
+				}
+				if err := s.SaveFinding(ctx, finding); err != nil {
+					saveErrors++
+					continue
+				}
+				existing[fingerprint] = true
+				newFindings++
+			}
+		}
+
+		processed += len(batch)
+		lastID = batch[len(batch)-1].ID
+		if err := os.WriteFile(*checkpointFile, []byte(lastID+"\n"), 0o644); err != nil {
+			return fmt.Errorf("failed to write checkpoint: %w", err)
+		}
+		log.Printf("Reprocessed %d/%d messages, %d new findings", processed, total, newFindings)
+	}
+
+	log.Printf("Reprocess complete in %s: %d messages, %d new findings, %d save errors",
+		time.Since(started).Round(time.Second), processed, newFindings, saveErrors)
+	return nil
+}
+
+// loadFindingFingerprints returns the post_id:key_sha256 pairs already
+// recorded in api_key_findings. Rows saved before key_sha256 existed are
+// hashed from api_key.
+func (s *Scanner) loadFindingFingerprints(ctx context.Context) (map[string]bool, error) {
+	rows, err := s.clickhouseConn.Query(ctx, fmt.Sprintf(`SELECT DISTINCT
+			post_id,
+			if(key_sha256 = '', lower(hex(SHA256(api_key))), key_sha256)
+		FROM %s`, s.table("api_key_findings")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query existing findings: %w", err)
+	}
+	defer rows.Close()
+
+	fingerprints := make(map[string]bool)
+	for rows.Next() {
+		var postID, keyHash string
+		if err := rows.Scan(&postID, &keyHash); err != nil {
+			return nil, fmt.Errorf("failed to scan finding: %w", err)
+		}
+		fingerprints[postID+":"+keyHash] = true
+	}
+	return fingerprints, rows.Err()
+}
+
+// loadMessageBatch reads up to limit stored messages with IDs after afterID,
+// taking the latest saved version of each edited message
+func (s *Scanner) loadMessageBatch(ctx context.Context, afterID string, limit int) ([]ScannedMessage, error) {
+	rows, err := s.clickhouseConn.Query(ctx, fmt.Sprintf(`SELECT
+			id, argMax(message_type, scanned_at), argMax(post_id, scanned_at),
+			argMax(title, scanned_at), argMax(content, scanned_at),
+			argMax(author_id, scanned_at), argMax(author_name, scanned_at),
+			argMax(submolt_id, scanned_at), argMax(submolt_name, scanned_at),
+			argMax(created_at, scanned_at)
+		FROM %s
+		WHERE id > ?
+		GROUP BY id
+		ORDER BY id
+		LIMIT ?`, s.table("messages")), afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	var batch []ScannedMessage
+	for rows.Next() {
+		var m ScannedMessage
+		if err := rows.Scan(&m.ID, &m.MessageType, &m.PostID, &m.Title, &m.Content,
+			&m.AuthorID, &m.AuthorName, &m.SubmoltID, &m.SubmoltName, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		batch = append(batch, m)
+	}
+	return batch, rows.Err()
+}
+
+// rescanMessage runs a stored message back through ScanPost or ScanComment
+func (s *Scanner) rescanMessage(msg ScannedMessage) []APIKeyFinding {
+	author := &Author{ID: msg.AuthorID, Name: msg.AuthorName}
+
+	if msg.MessageType == "post" {
+		return s.ScanPost(MoltbookPost{
+			ID:        msg.ID,
+			Title:     msg.Title,
+			Content:   msg.Content,
+			CreatedAt: msg.CreatedAt,
+			Author:    author,
+			Submolt:   &Submolt{ID: msg.SubmoltID, Name: msg.SubmoltName},
+		})
+	}
+
+	return s.ScanComment(MoltbookComment{
+		ID:        msg.ID,
+		PostID:    msg.PostID,
+		Content:   msg.Content,
+		CreatedAt: msg.CreatedAt,
+		Author:    author,
+	}, "", msg.SubmoltName)
+}
+
+// exportHeader is the header row of the export command's CSV
+var exportHeader = []string{
+	"id", "found_at", "post_created_at", "api_key_type", "severity", "api_key_masked", "key_sha256",
+	"encoding", "location", "author_name", "submolt_name", "post_title", "post_url", "source_url",
+}
+
+// runExport writes the findings in a time range as CSV or SARIF, with keys
+// masked, to stdout or the file given by -out
+func (s *Scanner) runExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	sinceFlag := fs.String("since", "", "only findings found at or after this RFC 3339 time or duration ago (e.g. 720h)")
+	untilFlag := fs.String("until", "", "only findings found before this RFC 3339 time or duration ago")
+	keyType := fs.String("type", "", "only findings of this key type (e.g. AWS)")
+	out := fs.String("out", "", "file to write instead of stdout")
+	format := fs.String("format", "csv", `output format, "csv" or "sarif" (SARIF 2.1.0)`)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var write func(io.Writer, []FindingRecord) error
+	switch *format {
+	case "csv":
+		write = writeFindingsCSV
+	case "sarif":
+		write = writeFindingsSARIF
+	default:
+		return fmt.Errorf("invalid -format %q: must be \"csv\" or \"sarif\"", *format)
+	}
+
+	since, err := parseSince(*sinceFlag)
+	if err != nil {
+		return fmt.Errorf("invalid -since: %w", err)
+	}
+	until, err := parseSince(*untilFlag)
+	if err != nil {
+		return fmt.Errorf("invalid -until: %w", err)
+	}
+
+	findings, err := s.QueryFindings(ctx, FindingFilter{KeyType: *keyType, Since: since, Until: until})
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", *out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := write(w, findings); err != nil {
+		return err
+	}
+
+	if *out != "" {
+		log.Printf("Exported %d findings to %s", len(findings), *out)
+	}
+	return nil
+}
+
+// writeFindingsCSV writes findings as CSV rows under exportHeader
+func writeFindingsCSV(w io.Writer, findings []FindingRecord) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportHeader); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	for _, f := range findings {
+		if err := cw.Write([]string{
+			f.ID,
+			f.FoundAt.UTC().Format(time.RFC3339),
+			f.PostCreatedAt.UTC().Format(time.RFC3339),
+			f.APIKeyType,
+			f.Severity,
+			f.APIKeyMasked,
+			f.KeySHA256,
+			f.Encoding,
+			f.Location,
+			f.AuthorName,
+			f.SubmoltName,
+			f.PostTitle,
+			f.PostURL,
+			f.SourceURL,
+		}); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+// originScanAuthor tags findings produced by the scan-author command
+const originScanAuthor = "scan-author"
+
+// runScanAuthor scans the posts of one author, and their comments on those
+// posts, on demand. Messages are stored and findings saved and alerted like
+// the scan loop's, tagged with originScanAuthor. Findings already recorded
+// for the same message and key are skipped, so reruns don't re-alert.
+func (s *Scanner) runScanAuthor(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("scan-author", flag.ContinueOnError)
+	author := fs.String("author", "", "name of the agent to scan (required)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *author == "" {
+		return errors.New("-author is required")
+	}
+
+	existing, err := s.loadFindingFingerprints(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.LoadNotifiedFingerprints(ctx); err != nil {
+		log.Printf("Warning: failed to load notified fingerprints: %v", err)
+	}
+
+	posts, err := s.FetchUserPosts(ctx, *author)
+	if err != nil {
+		return err
+	}
+	log.Printf("Scanning %d posts by %s", len(posts), *author)
+
+	scanned, totalFindings, saveErrors := 0, 0, 0
+	save := func(msg ScannedMessage, findings []APIKeyFinding) {
+		scanned++
+		if err := s.SaveMessage(ctx, msg); err != nil {
+			saveErrors++
+		}
+		var fresh []APIKeyFinding
+		for _, f := range findings {
+			fingerprint := f.PostID + ":" + hashKey(f.APIKey)
+			if existing[fingerprint] {
+				continue
+			}
+			existing[fingerprint] = true
+			f.Origin = originScanAuthor
+			fresh = append(fresh, f)
+		}
+		s.processFindings(ctx, fresh, &totalFindings, &saveErrors)
+	}
+
+	for _, post := range posts {
+		if ctx.Err() != nil {
+			break
+		}
+		findings := s.ScanPost(post)
+		findings = append(findings, s.scanPostAttachments(post, findings)...)
+		findings = append(findings, s.scanPostLinks(ctx, post, findings)...)
+		save(s.PostToMessage(post), findings)
+
+		if post.CommentCount == 0 {
+			continue
+		}
+		comments, err := s.FetchComments(ctx, post.ID)
+		if err != nil {
+			log.Printf("Warning: failed to fetch comments of post %s: %v", post.ID, err)
+			continue
+		}
+		submoltName := "general"
+		if post.Submolt != nil {
+			submoltName = post.Submolt.Name
+		}
+		for _, comment := range comments {
+			if comment.Author == nil || comment.Author.Name != *author {
+				continue
+			}
+			save(s.CommentToMessage(comment, submoltName), s.ScanComment(comment, post.Title, submoltName))
+		}
+	}
+
+	// Deliver queued notifications before the command exits
+	s.flushNotifiers(ctx)
+
+	log.Printf("Scan of %s complete: %d messages, %d new findings, %d save errors",
+		*author, scanned, totalFindings, saveErrors)
+	return nil
+}
+
+// runPromote moves one finding from findings_quarantine to api_key_findings
+// after it has been reviewed. The promoted finding is not alerted.
+func (s *Scanner) runPromote(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("promote", flag.ContinueOnError)
+	id := fs.String("id", "", "ID of the quarantined finding (required)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !uuidPattern.MatchString(*id) {
+		return errors.New("-id must be a finding UUID")
+	}
+	return s.PromoteFinding(ctx, *id)
+}
+
+// runReplayDLQ retries the messages and findings recorded in DLQ_FILE (or
+// -file) after failing to save. Replayed findings are not alerted again.
+func (s *Scanner) runReplayDLQ(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("replay-dlq", flag.ContinueOnError)
+	path := fs.String("file", os.Getenv("DLQ_FILE"), "dead letter file to replay (default DLQ_FILE)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return errors.New("-file or DLQ_FILE is required")
+	}
+
+	replayed, remaining, err := s.ReplayDeadLetters(ctx, *path)
+	if err != nil {
+		return err
+	}
+	log.Printf("Replayed %d rows from %s, %d still failing", replayed, *path, remaining)
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// errNotModified is returned by doConditionalRequest when the API answers
+// 304 Not Modified, leaving out untouched
+var errNotModified = errors.New("not modified")
+
+// validators are the ETag and Last-Modified headers of a response, sent back
+// as If-None-Match and If-Modified-Since to ask whether it changed
+type validators struct {
+	etag, lastModified string
+}
+
+// validatorCache holds the validators of the last successful response of
+// each URL it is used for. The zero value is ready to use.
+type validatorCache struct {
+	mu    sync.Mutex
+	byURL map[string]validators
+}
+
+// apply adds the conditional headers for url to header, if any are stored
+func (c *validatorCache) apply(url string, header http.Header) {
+	c.mu.Lock()
+	v := c.byURL[url]
+	c.mu.Unlock()
+	if v.etag != "" {
+		header.Set("If-None-Match", v.etag)
+	}
+	if v.lastModified != "" {
+		header.Set("If-Modified-Since", v.lastModified)
+	}
+}
+
+// store records the validators of a successful response for url. A
+// response without any forgets the earlier ones, so an API that stops
+// sending them gets unconditional requests again.
+func (c *validatorCache) store(url string, header http.Header) {
+	v := validators{etag: header.Get("ETag"), lastModified: header.Get("Last-Modified")}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v == (validators{}) {
+		delete(c.byURL, url)
+		return
+	}
+	if c.byURL == nil {
+		c.byURL = make(map[string]validators)
+	}
+	c.byURL[url] = v
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+type (
+	cycleIDKey    struct{}
+	cycleSavesKey struct{}
+)
+
+// cycleSaves counts the ClickHouse saves of one scan cycle
+type cycleSaves struct {
+	attempts atomic.Int64
+	failures atomic.Int64
+}
+
+// withCycleID returns ctx carrying a new random scan cycle ID, which ties
+// together the log lines and rows written during one scan cycle, and
+// counts of the cycle's saves and findings
+func withCycleID(ctx context.Context) context.Context {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	ctx = context.WithValue(ctx, cycleSavesKey{}, &cycleSaves{})
+	ctx = context.WithValue(ctx, cycleFindingsKey{}, &cycleFindings{bySubmolt: make(map[string]int)})
+	return withGivenCycleID(ctx, hex.EncodeToString(b))
+}
+
+// withGivenCycleID returns ctx carrying the cycle ID id, e.g. to replay a
+// row under the cycle that first tried to write it
+func withGivenCycleID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, cycleIDKey{}, id)
+}
+
+// cycleID returns the scan cycle ID carried by ctx, "" outside a scan cycle
+// (e.g. in commands)
+func cycleID(ctx context.Context) string {
+	id, _ := ctx.Value(cycleIDKey{}).(string)
+	return id
+}
+
+// logCycle logs like log.Printf, prefixed with the cycle ID of ctx if any
+func logCycle(ctx context.Context, format string, args ...interface{}) {
+	if id := cycleID(ctx); id != "" {
+		format = "[cycle " + id + "] " + format
+	}
+	log.Printf(format, args...)
+}
+
+// logCycleDebug is logDebug prefixed with the cycle ID of ctx if any
+func logCycleDebug(ctx context.Context, format string, args ...interface{}) {
+	if debugEnabled {
+		logCycle(ctx, "Debug: "+format, args...)
+	}
+}
+
+// recordSave counts a save of the scan cycle of ctx, if any
+func recordSave(ctx context.Context, err error) {
+	saves, ok := ctx.Value(cycleSavesKey{}).(*cycleSaves)
+	if !ok {
+		return
+	}
+	saves.attempts.Add(1)
+	if err != nil {
+		saves.failures.Add(1)
+	}
+}
+
+// checkSaveErrors fails a scan cycle whose share of failed saves is above
+// MAX_SAVE_ERROR_RATE, so a broken database shows up as failing scans
+// rather than as quiet ones
+func (s *Scanner) checkSaveErrors(ctx context.Context) error {
+	saves, ok := ctx.Value(cycleSavesKey{}).(*cycleSaves)
+	if !ok {
+		return nil
+	}
+	attempts, failures := saves.attempts.Load(), saves.failures.Load()
+	if attempts == 0 {
+		return nil
+	}
+	rate := float64(failures) / float64(attempts)
+	saveErrorRate.Set(rate)
+	if rate > s.maxSaveErrRate {
+		return fmt.Errorf("%d of %d saves failed in cycle %s, above MAX_SAVE_ERROR_RATE=%g",
+			failures, attempts, cycleID(ctx), s.maxSaveErrRate)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/hex"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxDecodedBytes bounds how much decoded text each encoding contributes
+const maxDecodedBytes = 64 * 1024
+
+var (
+	urlEscapePattern = regexp.MustCompile(`%[0-9a-fA-F]{2}`)
+	hexRunPattern    = regexp.MustCompile(`[0-9a-fA-F]{32,}`)
+	// entityPattern finds HTML character references and \uXXXX escapes,
+	// consecutive escapes together so surrogate pairs decode as one rune
+	entityPattern  = regexp.MustCompile(`&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[a-zA-Z][a-zA-Z0-9]{1,31});|(?:\\u[0-9a-fA-F]{4})+`)
+	unicodeEscapes = regexp.MustCompile(`\\u([0-9a-fA-F]{4})`)
+)
+
+// decodedText is an alternative rendering of scanned text
+type decodedText struct {
+	encoding string
+	text     string
+}
+
+// decodedVariants returns the URL-decoded text and the decoded long hex runs
+// of text, skipping any output that is mostly non-printable. With entities
+// set (DECODE_ENTITIES) it also returns text with HTML entities and \uXXXX
+// escapes decoded.
+func decodedVariants(text string, entities bool) []decodedText {
+	var variants []decodedText
+
+	if entities {
+		if decoded, ok := decodeEntities(text); ok {
+			variants = append(variants, decodedText{encoding: "entity", text: decoded})
+		}
+	}
+
+	if urlEscapePattern.MatchString(text) {
+		// Decode escapes individually so one malformed "%" doesn't void the rest
+		decoded := urlEscapePattern.ReplaceAllStringFunc(text, func(esc string) string {
+			b, _ := hex.DecodeString(esc[1:])
+			return string(b)
+		})
+		decoded = truncateToRuneBoundary(decoded, maxDecodedBytes)
+		if mostlyPrintable(decoded) {
+			variants = append(variants, decodedText{encoding: "url", text: decoded})
+		}
+	}
+
+	var hexDecoded strings.Builder
+	for _, run := range hexRunPattern.FindAllString(text, -1) {
+		if hexDecoded.Len() >= maxDecodedBytes {
+			break
+		}
+		run = run[:len(run)&^1]
+		b, err := hex.DecodeString(run)
+		if err != nil || !mostlyPrintable(string(b)) {
+			continue
+		}
+		hexDecoded.Write(b)
+		hexDecoded.WriteByte('\n')
+	}
+	if hexDecoded.Len() > 0 {
+		variants = append(variants, decodedText{
+			encoding: "hex",
+			text:     truncateToRuneBoundary(hexDecoded.String(), maxDecodedBytes),
+		})
+	}
+
+	return variants
+}
+
+// decodeEntities decodes the HTML entities and \uXXXX escapes in the first
+// maxDecodedBytes of text. Each escape is decoded in place, so the text
+// around it, and thus the excerpt around a key, is unchanged. It reports
+// false when nothing was decoded, so plain text isn't scanned twice.
+func decodeEntities(text string) (string, bool) {
+	if !strings.ContainsAny(text, "&\\") {
+		return "", false
+	}
+	text = truncateToRuneBoundary(text, maxDecodedBytes)
+
+	changes := 0
+	decoded := entityPattern.ReplaceAllStringFunc(text, func(esc string) string {
+		if esc[0] == '&' {
+			unescaped := html.UnescapeString(esc)
+			if unescaped != esc {
+				changes++
+			}
+			return unescaped
+		}
+		var units []uint16
+		for _, m := range unicodeEscapes.FindAllStringSubmatch(esc, -1) {
+			u, _ := strconv.ParseUint(m[1], 16, 16)
+			units = append(units, uint16(u))
+		}
+		changes += len(units)
+		return string(utf16.Decode(units))
+	})
+	if changes == 0 || !mostlyPrintable(decoded) {
+		return "", false
+	}
+	return decoded, true
+}
+
+// mostlyPrintable reports whether at least 90% of the runes in s are
+// printable or whitespace
+func mostlyPrintable(s string) bool {
+	if s == "" {
+		return false
+	}
+	printable, total := 0, 0
+	for _, r := range s {
+		total++
+		if r != utf8.RuneError && (unicode.IsPrint(r) || unicode.IsSpace(r)) {
+			printable++
+		}
+	}
+	return printable*10 >= total*9
+}
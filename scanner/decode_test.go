@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A DigitalOcean token, which only matches once its "_"s are decoded
+var testDOToken = "dop_v1_" + strings.Repeat("0a1b", 16)
+
+func TestDecodedVariants(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []decodedText
+	}{
+		{
+			name: "URL escapes",
+			text: "token=dop%5Fv1%5Fabc%20def",
+			want: []decodedText{{encoding: "url", text: "token=dop_v1_abc def"}},
+		},
+		{
+			name: "malformed escape beside valid ones",
+			text: "100%ZZ off%21",
+			want: []decodedText{{encoding: "url", text: "100%ZZ off!"}},
+		},
+		{name: "escapes of binary bytes", text: strings.Repeat("%00%01%02", 10)},
+		{
+			name: "hex runs",
+			text: "a " + hex.EncodeToString([]byte("first secret value")) + " b " + hex.EncodeToString([]byte("second secret value")),
+			want: []decodedText{{encoding: "hex", text: "first secret value\nsecond secret value\n"}},
+		},
+		{
+			name: "odd-length hex run",
+			text: hex.EncodeToString([]byte("sixteen chars ok")) + "f",
+			want: []decodedText{{encoding: "hex", text: "sixteen chars ok\n"}},
+		},
+		{name: "hex digest", text: "sha1 " + strings.Repeat("0f", 20)},
+		{name: "short hex run", text: hex.EncodeToString([]byte("fifteen chars!!"))},
+		{name: "plain text", text: "nothing encoded here, not even AT&T"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := decodedVariants(tc.text, false); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("decodedVariants(%q) = %q, want %q", tc.text, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestScanTextReportsEncodedKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		found    bool
+		encoding string
+	}{
+		{name: "raw", text: "DO_TOKEN=" + testDOToken, found: true},
+		{name: "URL-encoded", text: "DO_TOKEN=" + strings.ReplaceAll(testDOToken, "_", "%5F"), found: true, encoding: "url"},
+		{name: "hex-encoded", text: "blob " + hex.EncodeToString([]byte("DO_TOKEN="+testDOToken)), found: true, encoding: "hex"},
+		{name: "URL-encoded too short", text: "dop%5Fv1%5F" + strings.Repeat("0a1b", 8)},
+	}
+	s := newPatternScanner()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var found *KeyMatch
+			matches := s.ScanText(tc.text)
+			for i := range matches {
+				if matches[i].Type == "DigitalOceanPAT" {
+					found = &matches[i]
+				}
+			}
+			switch {
+			case !tc.found:
+				if found != nil {
+					t.Errorf("ScanText(%q) reported %q, want nothing", tc.text, found.Key)
+				}
+			case found == nil:
+				t.Errorf("ScanText(%q) = %v, want a DigitalOceanPAT match", tc.text, matchTypes(matches))
+			case found.Key != testDOToken || found.Encoding != tc.encoding:
+				t.Errorf("ScanText(%q) reported %q with encoding %q, want %q with %q", tc.text, found.Key, found.Encoding, testDOToken, tc.encoding)
+			}
+		})
+	}
+}
+
+func TestDecodeEntities(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string // "" if nothing may be decoded
+	}{
+		{name: "named entities", text: "a &lt;b&gt; &amp; c", want: "a <b> & c"},
+		{name: "numeric references", text: "dop&#95;v1&#x5F;", want: "dop_v1_"},
+		{name: "unicode escapes", text: `"key": "dop\u005fv1\u005F"`, want: `"key": "dop_v1_"`},
+		{name: "surrogate pair", text: `smile \ud83d\ude00!`, want: "smile \U0001F600!"},
+		{name: "bare ampersand", text: "AT&T and R&D"},
+		{name: "unknown entity", text: "&bogus; stays"},
+		{name: "backslash without an escape", text: `C:\users\me`},
+		{name: "references to control characters", text: strings.Repeat("&#1;", 20)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := decodeEntities(tc.text)
+			if ok != (tc.want != "") || got != tc.want {
+				t.Errorf("decodeEntities(%q) = %q, %t, want %q", tc.text, got, ok, tc.want)
+			}
+		})
+	}
+
+	// Entity decoding is only a variant with DECODE_ENTITIES set
+	if got := decodedVariants("&lt;b&gt;", false); got != nil {
+		t.Errorf("decodedVariants without entities = %q, want none", got)
+	}
+	want := []decodedText{{encoding: "entity", text: "<b>"}}
+	if got := decodedVariants("&lt;b&gt;", true); !reflect.DeepEqual(got, want) {
+		t.Errorf("decodedVariants with entities = %q, want %q", got, want)
+	}
+}
+
+func TestScanTextReportsEntityEncodedKeys(t *testing.T) {
+	s := newPatternScanner()
+	text := "<code>DO_TOKEN=" + strings.ReplaceAll(testDOToken, "_", "&#95;") + "</code>"
+	if matches := s.ScanText(text); len(matches) > 0 {
+		t.Errorf("ScanText without DECODE_ENTITIES reported %v", matchTypes(matches))
+	}
+
+	s.decodeEntities = true
+	for _, m := range s.ScanText(text) {
+		if m.Type == "DigitalOceanPAT" {
+			if m.Key != testDOToken || m.Encoding != "entity" {
+				t.Errorf("reported %q with encoding %q, want %q with entity", m.Key, m.Encoding, testDOToken)
+			}
+			return
+		}
+	}
+	t.Errorf("ScanText with DECODE_ENTITIES found no DigitalOceanPAT in %q", text)
+}
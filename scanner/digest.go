@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// activityCounters accumulate scan activity across cycles for the digest
+type activityCounters struct {
+	mu             sync.Mutex
+	started        time.Time
+	messages       uint64
+	saveErrors     uint64
+	findingsByType map[string]uint64
+}
+
+func newActivityCounters() *activityCounters {
+	return &activityCounters{started: time.Now(), findingsByType: make(map[string]uint64)}
+}
+
+// addScan records the outcome of one scan cycle
+func (a *activityCounters) addScan(messages, saveErrors int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.messages += uint64(messages)
+	a.saveErrors += uint64(saveErrors)
+}
+
+// addFinding records a saved finding of keyType
+func (a *activityCounters) addFinding(keyType string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.findingsByType[keyType]++
+}
+
+// digest is a periodic summary of cumulative scan activity since startup
+type digest struct {
+	Event          string            `json:"event"`
+	Since          time.Time         `json:"since"`
+	Messages       uint64            `json:"messages_scanned"`
+	Findings       uint64            `json:"findings"`
+	FindingsByType map[string]uint64 `json:"findings_by_type"`
+	SaveErrors     uint64            `json:"save_errors"`
+	SeenMessages   int               `json:"seen_messages"`
+	PatternMatches map[string]uint64 `json:"pattern_matches"`
+}
+
+func (s *Scanner) newDigest() digest {
+	a := s.activity
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	d := digest{
+		Event:          "digest",
+		Since:          a.started,
+		Messages:       a.messages,
+		FindingsByType: make(map[string]uint64, len(a.findingsByType)),
+		SaveErrors:     a.saveErrors,
+		SeenMessages:   s.seenMessages.Stats().Entries,
+		PatternMatches: make(map[string]uint64),
+	}
+	for keyType, n := range a.findingsByType {
+		d.FindingsByType[keyType] = n
+		d.Findings += n
+	}
+	for pattern, n := range patternMatchesTotal.snapshot() {
+		d.PatternMatches[pattern] = uint64(n)
+	}
+	return d
+}
+
+// typeBreakdown renders FindingsByType as "AWS 3, GitHub 1", most frequent first
+func (d digest) typeBreakdown() string {
+	return breakdown(d.FindingsByType)
+}
+
+// patternBreakdown renders the patterns that matched like typeBreakdown,
+// and how many never did
+func (d digest) patternBreakdown() string {
+	matched := make(map[string]uint64)
+	for pattern, n := range d.PatternMatches {
+		if n > 0 {
+			matched[pattern] = n
+		}
+	}
+	return fmt.Sprintf("%s; %d never matched", breakdown(matched), len(d.PatternMatches)-len(matched))
+}
+
+// breakdown renders counts as "a 3, b 1", largest first, or "none"
+func breakdown(counts map[string]uint64) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s %d", name, counts[name])
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (d digest) String() string {
+	return fmt.Sprintf("since %s: %d messages scanned, %d findings (%s), %d save errors, %d seen messages, pattern matches: %s",
+		d.Since.Format(time.RFC3339), d.Messages, d.Findings, d.typeBreakdown(), d.SaveErrors, d.SeenMessages, d.patternBreakdown())
+}
+
+// digestNotifier is implemented by notifiers that can deliver digests
+type digestNotifier interface {
+	Notifier
+	SendDigest(ctx context.Context, d digest) error
+}
+
+// runDigest logs a digest every digestInterval until ctx is cancelled, also
+// sending it to the notifiers that support digests when DIGEST_NOTIFY is set
+func (s *Scanner) runDigest(ctx context.Context) {
+	ticker := time.NewTicker(s.digestInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d := s.newDigest()
+			log.Printf("📬 Digest %s", d)
+			if !s.digestNotify {
+				continue
+			}
+			for _, n := range s.notifiers {
+				if dn, ok := n.(digestNotifier); ok {
+					if err := dn.SendDigest(ctx, d); err != nil {
+						log.Printf("Warning: %s digest failed: %v", n.Name(), err)
+					}
+				}
+			}
+		}
+	}
+}
+
+// SendDigest posts the digest as JSON, signed like findings
+func (wh *WebhookNotifier) SendDigest(ctx context.Context, d digest) error {
+	body, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+	return wh.post(ctx, body)
+}
+
+// SendDigest sends the digest as one message
+func (t *TelegramNotifier) SendDigest(ctx context.Context, d digest) error {
+	text := fmt.Sprintf("📬 <b>Moltbook scanner digest</b>\n"+
+		"Since: %s\n"+
+		"Messages scanned: %d\n"+
+		"Findings: %d (%s)\n"+
+		"Save errors: %d\n"+
+		"Seen messages: %d\n"+
+		"Pattern matches: %s",
+		html.EscapeString(d.Since.Format(time.RFC3339)),
+		d.Messages,
+		d.Findings,
+		html.EscapeString(d.typeBreakdown()),
+		d.SaveErrors,
+		d.SeenMessages,
+		html.EscapeString(d.patternBreakdown()),
+	)
+	return t.send(ctx, text)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Dead letter kinds
+const (
+	deadLetterMessage = "message"
+	deadLetterFinding = "finding"
+)
+
+// deadLetter is one row that failed to save, as a line of DLQ_FILE. Secrets
+// are masked, so a replayed finding keeps only the masked key; KeySHA256
+// preserves its fingerprint. Likewise ContentSHA256 is the contentHash of
+// a message before masking, so the replayed row isn't taken for an edit
+// when the seen set is loaded.
+type deadLetter struct {
+	Kind          string          `json:"kind"`
+	FailedAt      time.Time       `json:"failed_at"`
+	Error         string          `json:"error"`
+	CycleID       string          `json:"cycle_id,omitempty"`
+	Message       *ScannedMessage `json:"message,omitempty"`
+	Finding       *APIKeyFinding  `json:"finding,omitempty"`
+	KeySHA256     string          `json:"key_sha256,omitempty"`
+	ContentSHA256 string          `json:"content_sha256,omitempty"`
+}
+
+// deadLetterFile appends rows that failed to save to DLQ_FILE as NDJSON, so
+// a ClickHouse outage doesn't silently lose them
+type deadLetterFile struct {
+	mu   sync.Mutex
+	path string
+}
+
+// newDeadLetterFileFromEnv returns nil unless DLQ_FILE is set
+func newDeadLetterFileFromEnv() *deadLetterFile {
+	path := os.Getenv("DLQ_FILE")
+	if path == "" {
+		return nil
+	}
+	log.Printf("Rows that fail to save will be written to %s", path)
+	return &deadLetterFile{path: path}
+}
+
+// write appends dl to the file
+func (d *deadLetterFile) write(dl deadLetter) error {
+	line, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f, err := os.OpenFile(d.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// deadLetterMessage records a message that failed to save, with the secrets
+// in its title and content masked
+func (s *Scanner) deadLetterMessage(ctx context.Context, msg ScannedMessage, saveErr error) {
+	if s.deadLetters == nil {
+		return
+	}
+	hash := msg.contentSHA256()
+	msg.Title = s.maskSecrets(msg.Title)
+	msg.Content = s.maskSecrets(msg.Content)
+	s.writeDeadLetter(deadLetter{
+		Kind:          deadLetterMessage,
+		FailedAt:      s.now(),
+		Error:         saveErr.Error(),
+		CycleID:       cycleID(ctx),
+		Message:       &msg,
+		ContentSHA256: hash,
+	})
+}
+
+// deadLetterFinding records a finding that failed to save, with its key and
+// content masked
+func (s *Scanner) deadLetterFinding(ctx context.Context, finding APIKeyFinding, saveErr error) {
+	if s.deadLetters == nil {
+		return
+	}
+	keyHash := finding.keySHA256()
+	finding.Content = maskKeys(s.maskSecrets(finding.Content), []string{finding.APIKey})
+	finding.APIKey = maskKey(finding.APIKey)
+	s.writeDeadLetter(deadLetter{
+		Kind:      deadLetterFinding,
+		FailedAt:  s.now(),
+		Error:     saveErr.Error(),
+		CycleID:   cycleID(ctx),
+		Finding:   &finding,
+		KeySHA256: keyHash,
+	})
+}
+
+func (s *Scanner) writeDeadLetter(dl deadLetter) {
+	if err := s.deadLetters.write(dl); err != nil {
+		log.Printf("Warning: failed to write %s to DLQ_FILE: %v", dl.Kind, err)
+	}
+}
+
+// ReplayDeadLetters retries the inserts recorded in path. Rows that fail
+// again are written back to path and everything else is removed from it, so
+// the replay can simply be rerun. It must not run while a scanner is
+// appending to the same file.
+func (s *Scanner) ReplayDeadLetters(ctx context.Context, path string) (replayed, remaining int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open dead letter file: %w", err)
+	}
+	var failed []deadLetter
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var dl deadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &dl); err != nil {
+			f.Close()
+			return replayed, 0, fmt.Errorf("line %d: %w", line, err)
+		}
+		if ctx.Err() != nil {
+			failed = append(failed, dl)
+			continue
+		}
+		if err := s.replayDeadLetter(ctx, dl); err != nil {
+			logDebug("Replay of %s from line %d failed: %v", dl.Kind, line, err)
+			dl.Error = err.Error()
+			failed = append(failed, dl)
+			continue
+		}
+		replayed++
+	}
+	f.Close()
+	if err := scanner.Err(); err != nil {
+		return replayed, 0, fmt.Errorf("failed to read dead letter file: %w", err)
+	}
+
+	// Rewrite the file with only the rows still failing
+	tmp := path + ".tmp"
+	out := &deadLetterFile{path: tmp}
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return replayed, len(failed), err
+	}
+	for _, dl := range failed {
+		if err := out.write(dl); err != nil {
+			return replayed, len(failed), fmt.Errorf("failed to write remaining rows: %w", err)
+		}
+	}
+	if len(failed) == 0 {
+		if err := os.WriteFile(tmp, nil, 0o600); err != nil {
+			return replayed, 0, err
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return replayed, len(failed), fmt.Errorf("failed to replace dead letter file: %w", err)
+	}
+	return replayed, len(failed), nil
+}
+
+// replayDeadLetter inserts one recorded row, tagged with the cycle it
+// originally failed in
+func (s *Scanner) replayDeadLetter(ctx context.Context, dl deadLetter) error {
+	if dl.CycleID != "" {
+		ctx = withGivenCycleID(ctx, dl.CycleID)
+	}
+	switch {
+	case dl.Kind == deadLetterMessage && dl.Message != nil:
+		msg := *dl.Message
+		msg.hash = dl.ContentSHA256
+		return s.saveMessage(ctx, msg)
+	case dl.Kind == deadLetterFinding && dl.Finding != nil:
+		finding := *dl.Finding
+		finding.keyHash = dl.KeySHA256
+		return s.saveFinding(ctx, finding)
+	default:
+		return fmt.Errorf("unknown dead letter kind %q", dl.Kind)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplayedMessageKeepsItsContentHash(t *testing.T) {
+	ctx := context.Background()
+	conn := &fakeConn{}
+	s := newPatternScanner()
+	s.clickhouseConn = conn
+	s.writeLimiter = newWriteLimiter(1)
+	s.deadLetters = &deadLetterFile{path: filepath.Join(t.TempDir(), "dlq.ndjson")}
+
+	openAI := "sk-" + strings.Repeat("aB3dE", 8)
+	post := MoltbookPost{ID: "p1", Title: "keys", Content: "OPENAI_API_KEY=" + openAI}
+	s.deadLetterMessage(ctx, s.PostToMessage(post), errors.New("connection refused"))
+
+	replayed, remaining, err := s.ReplayDeadLetters(ctx, s.deadLetters.path)
+	if err != nil || replayed != 1 || remaining != 0 {
+		t.Fatalf("ReplayDeadLetters = %d, %d, %v, want 1 replayed", replayed, remaining, err)
+	}
+	inserts := conn.inserts("messages")
+	if len(inserts) != 1 {
+		t.Fatalf("%d message inserts, want 1", len(inserts))
+	}
+	args := inserts[0].args
+	if content := args[5].(string); strings.Contains(content, openAI) {
+		t.Errorf("replayed message content %q holds the key", content)
+	}
+	if got, want := args[len(args)-1], contentHash(post.Title, post.Content); got != want {
+		t.Errorf("replayed content_sha256 = %v, want the hash of the original content %s", got, want)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// scanTargets selects which kinds of messages are fetched and scanned
+type scanTargets struct {
+	posts    bool
+	comments bool
+}
+
+// parseScanTargets parses SCAN_TARGETS, a comma-separated subset of
+// "posts" and "comments"; empty means both
+func parseScanTargets(raw string) (scanTargets, error) {
+	names := parseNameSet(raw)
+	if len(names) == 0 {
+		return scanTargets{posts: true, comments: true}, nil
+	}
+
+	var t scanTargets
+	for name := range names {
+		switch name {
+		case "posts":
+			t.posts = true
+		case "comments":
+			t.comments = true
+		default:
+			return scanTargets{}, fmt.Errorf("invalid SCAN_TARGETS entry %q: must be \"posts\" or \"comments\"", name)
+		}
+	}
+	return t, nil
+}
+
+// String lists the enabled targets, e.g. "posts,comments"
+func (t scanTargets) String() string {
+	var names []string
+	if t.posts {
+		names = append(names, "posts")
+	}
+	if t.comments {
+		names = append(names, "comments")
+	}
+	return strings.Join(names, ",")
+}
+
+// submoltFilter decides which submolts' posts are scanned
+type submoltFilter struct {
+	include     map[string]bool
+	exclude     map[string]bool
+	scanUnknown bool // whether posts without a submolt are scanned
+}
+
+// newSubmoltFilter builds a filter from comma-separated submolt names. When
+// an include list is given it takes precedence and the exclude list is
+// ignored.
+func newSubmoltFilter(include, exclude string, scanUnknown bool) *submoltFilter {
+	f := &submoltFilter{
+		include:     parseNameSet(include),
+		exclude:     parseNameSet(exclude),
+		scanUnknown: scanUnknown,
+	}
+	if len(f.include) > 0 && len(f.exclude) > 0 {
+		log.Printf("Warning: both INCLUDE_SUBMOLTS and EXCLUDE_SUBMOLTS are set, ignoring EXCLUDE_SUBMOLTS")
+		f.exclude = nil
+	}
+	return f
+}
+
+// allows reports whether posts in submolt should be scanned
+func (f *submoltFilter) allows(submolt *Submolt) bool {
+	if submolt == nil {
+		return f.scanUnknown
+	}
+	name := strings.ToLower(submolt.Name)
+	if len(f.include) > 0 {
+		return f.include[name]
+	}
+	return !f.exclude[name]
+}
+
+// parseNameSet parses a comma-separated list into a lowercase set
+func parseNameSet(raw string) map[string]bool {
+	set := make(map[string]bool)
+	for _, name := range strings.Split(raw, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			set[name] = true
+		}
+	}
+	return set
+}
+
+// keyTypeFilter decides which key types are detected, from
+// ENABLED_KEY_TYPES and DISABLED_KEY_TYPES. Names are matched case-
+// insensitively against the types patterns are registered under.
+type keyTypeFilter struct {
+	enabled  map[string]bool
+	disabled map[string]bool
+}
+
+// newKeyTypeFilter builds a filter from comma-separated key type names. When
+// an enabled list is given it takes precedence and the disabled list is
+// ignored.
+func newKeyTypeFilter(enabled, disabled string) *keyTypeFilter {
+	f := &keyTypeFilter{
+		enabled:  parseNameSet(enabled),
+		disabled: parseNameSet(disabled),
+	}
+	if len(f.enabled) > 0 && len(f.disabled) > 0 {
+		log.Printf("Warning: both ENABLED_KEY_TYPES and DISABLED_KEY_TYPES are set, ignoring DISABLED_KEY_TYPES")
+		f.disabled = nil
+	}
+	return f
+}
+
+// allows reports whether keys of keyType should be detected. A nil filter
+// allows everything.
+func (f *keyTypeFilter) allows(keyType string) bool {
+	if f == nil {
+		return true
+	}
+	name := strings.ToLower(keyType)
+	if len(f.enabled) > 0 {
+		return f.enabled[name]
+	}
+	return !f.disabled[name]
+}
+
+// filterKeyPatterns returns the patterns whose type f allows
+func filterKeyPatterns(patterns []keyPattern, f *keyTypeFilter) []keyPattern {
+	var kept []keyPattern
+	for _, p := range patterns {
+		if f.allows(p.keyType) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// filterContextPatterns returns the keyword-gated patterns whose type f allows
+func filterContextPatterns(patterns []contextPattern, f *keyTypeFilter) []contextPattern {
+	var kept []contextPattern
+	for _, p := range patterns {
+		if f.allows(p.keyType) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// filterPIIPatterns returns the PII patterns whose type f allows
+func filterPIIPatterns(patterns []piiPattern, f *keyTypeFilter) []piiPattern {
+	var kept []piiPattern
+	for _, p := range patterns {
+		if f.allows(p.piiType) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// logActive logs the key types left enabled, and warns about configured
+// names that match no pattern so typos don't go unnoticed
+func (f *keyTypeFilter) logActive(patterns []keyPattern, contextPatterns []contextPattern, piiPatterns []piiPattern) {
+	known := map[string]bool{"awskeypair": true, "databaseconnectionstring": true}
+	for _, p := range compileAPIKeyPatterns() {
+		known[strings.ToLower(p.keyType)] = true
+	}
+	for _, p := range compileContextPatterns() {
+		known[strings.ToLower(p.keyType)] = true
+	}
+	for _, p := range compilePIIPatterns() {
+		known[strings.ToLower(p.piiType)] = true
+	}
+	for _, set := range []map[string]bool{f.enabled, f.disabled} {
+		for name := range set {
+			if !known[name] {
+				log.Printf("Warning: unknown key type %q in ENABLED_KEY_TYPES/DISABLED_KEY_TYPES", name)
+			}
+		}
+	}
+
+	active := make(map[string]bool)
+	for _, p := range patterns {
+		active[p.keyType] = true
+	}
+	for _, p := range contextPatterns {
+		active[p.keyType] = true
+	}
+	for _, p := range piiPatterns {
+		active[p.piiType] = true
+	}
+	for _, keyType := range []string{"AWSKeyPair", "DatabaseConnectionString", "AuthorizationHeader"} {
+		if f.allows(keyType) {
+			active[keyType] = true
+		}
+	}
+	log.Printf("Detecting %d key types (%d patterns): %s",
+		len(active), len(patterns)+len(contextPatterns)+len(piiPatterns), strings.Join(sortedKeys(active), ", "))
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// findingDedupLocks is how many locks the keys of findingDedup are spread
+// over
+const findingDedupLocks = 64
+
+// findingDedup folds repeat sightings of a key into the finding first saved
+// for it within FINDING_DEDUP_WINDOW. A repeat inserts a row into
+// finding_occurrences rather than a findings row; reads add those up to
+// the finding's occurrence_count and last_seen.
+type findingDedup struct {
+	// keyLocks serialize the saves of one key, picked by a hash of it, so
+	// two scan loops seeing the same key at once don't both insert it
+	// while saves of other keys go ahead
+	keyLocks [findingDedupLocks]sync.Mutex
+
+	mu     sync.Mutex // guards first
+	window time.Duration
+	first  map[string]time.Time // key hash -> found_at of the row repeats fold into
+}
+
+// newFindingDedupFromEnv returns nil unless FINDING_DEDUP_WINDOW is set
+func newFindingDedupFromEnv() *findingDedup {
+	window := getEnvDurationOrDefault("FINDING_DEDUP_WINDOW", 0)
+	if window <= 0 {
+		return nil
+	}
+	return &findingDedup{window: window, first: make(map[string]time.Time)}
+}
+
+// lockKey takes the lock of keyHash and returns its unlock
+func (d *findingDedup) lockKey(keyHash string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(keyHash))
+	l := &d.keyLocks[h.Sum32()%findingDedupLocks]
+	l.Lock()
+	return l.Unlock
+}
+
+// firstFinding returns the found_at of the finding of keyHash that repeats
+// fold into, if it is remembered and started after windowStart
+func (d *findingDedup) firstFinding(keyHash string, windowStart time.Time) (time.Time, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	first, ok := d.first[keyHash]
+	return first, ok && !first.Before(windowStart)
+}
+
+// remember records first as the finding of keyHash repeats fold into. A
+// new finding also prunes the keys whose window has ended.
+func (d *findingDedup) remember(keyHash string, first time.Time, isNew bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.first[keyHash] = first
+	if isNew {
+		d.prune(first)
+	}
+}
+
+// saveDedupedFinding saves finding, or records it as another occurrence of
+// the finding saved for the same key within the dedup window. The key's
+// lock is held across the lookup and the write.
+func (s *Scanner) saveDedupedFinding(ctx context.Context, finding APIKeyFinding, keyHash string) error {
+	d := s.findingDedup
+	defer d.lockKey(keyHash)()
+
+	windowStart := finding.FoundAt.Add(-d.window)
+	first, ok := d.firstFinding(keyHash, windowStart)
+	if !ok {
+		// Not in memory, e.g. after a restart: look for a row in the window
+		var err error
+		if first, ok, err = s.lastFindingSince(ctx, keyHash, windowStart); err != nil {
+			return err
+		}
+	}
+
+	if ok {
+		if err := s.insertFindingOccurrence(ctx, keyHash, first, finding.FoundAt); err != nil {
+			return err
+		}
+		d.remember(keyHash, first, false)
+		logDebug("Folded repeat %s finding in post %s into the one found at %s", finding.APIKeyType, finding.PostID, first.Format(time.RFC3339))
+		return nil
+	}
+
+	if err := s.insertFinding(ctx, finding); err != nil {
+		return err
+	}
+	d.remember(keyHash, finding.FoundAt, true)
+	return nil
+}
+
+// prune forgets keys whose window ended before now, so the map only holds
+// keys seen within one window
+func (d *findingDedup) prune(now time.Time) {
+	for keyHash, first := range d.first {
+		if now.Sub(first) > d.window {
+			delete(d.first, keyHash)
+		}
+	}
+}
+
+// lastFindingSince returns the found_at of the newest finding of keyHash
+// found at or after since
+func (s *Scanner) lastFindingSince(ctx context.Context, keyHash string, since time.Time) (time.Time, bool, error) {
+	query := fmt.Sprintf(`SELECT found_at FROM %s WHERE key_sha256 = ? AND found_at >= ? ORDER BY found_at DESC LIMIT 1`,
+		s.table("api_key_findings"))
+	var foundAt time.Time
+	err := s.clickhouseConn.QueryRow(ctx, query, keyHash, since).Scan(&foundAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to look up earlier finding: %w", err)
+	}
+	return foundAt, true, nil
+}
+
+// insertFindingOccurrence records one more sighting, at seenAt, of the
+// finding of keyHash found at foundAt. It is a plain insert, not a
+// mutation of the finding's row, so repeats cost no more than a finding.
+func (s *Scanner) insertFindingOccurrence(ctx context.Context, keyHash string, foundAt, seenAt time.Time) error {
+	query := fmt.Sprintf(`INSERT INTO %s (key_sha256, found_at, occurrences, last_seen) VALUES (?, ?, 1, ?)`,
+		s.table("finding_occurrences"))
+
+	if err := s.writeLimiter.acquire(ctx); err != nil {
+		return err
+	}
+	defer s.writeLimiter.release()
+
+	if err := s.clickhouseConn.Exec(s.insertContext(ctx), query, keyHash, foundAt, seenAt); err != nil {
+		return fmt.Errorf("failed to record finding occurrence: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindingDedupRecordsRepeatsAsRows(t *testing.T) {
+	found := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	var stored []any // found_at of the finding lastFindingSince finds, if any
+	conn := &fakeConn{rows: func(query string, args []any) [][]any {
+		if stored == nil {
+			return nil
+		}
+		return [][]any{stored}
+	}}
+	s := &Scanner{
+		clickhouseConn: conn,
+		writeLimiter:   newWriteLimiter(1),
+		findingDedup:   &findingDedup{window: time.Hour, first: make(map[string]time.Time)},
+	}
+	finding := func(at time.Time) APIKeyFinding {
+		return APIKeyFinding{PostID: "p1", APIKey: "sk-" + strings.Repeat("x", 24), APIKeyType: "OpenAI", FoundAt: at}
+	}
+	save := func(at time.Time) {
+		t.Helper()
+		if err := s.saveFinding(context.Background(), finding(at)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keyHash := finding(found).keySHA256()
+
+	save(found)
+	save(found.Add(time.Minute))
+	if got := len(conn.inserts("api_key_findings")); got != 1 {
+		t.Fatalf("%d findings saved, want 1", got)
+	}
+	occurrences := conn.inserts("finding_occurrences")
+	if len(occurrences) != 1 {
+		t.Fatalf("%d occurrences recorded, want 1", len(occurrences))
+	}
+	if args := occurrences[0].args; args[0] != keyHash || args[1] != found || args[2] != found.Add(time.Minute) {
+		t.Errorf("occurrence recorded with %v, want key %s found at %s seen at %s", args, keyHash, found, found.Add(time.Minute))
+	}
+
+	// After a restart the finding to fold into is looked up
+	s.findingDedup = &findingDedup{window: time.Hour, first: make(map[string]time.Time)}
+	stored = []any{found}
+	save(found.Add(2 * time.Minute))
+	if got := len(conn.inserts("finding_occurrences")); got != 2 {
+		t.Errorf("%d occurrences recorded after a restart, want 2", got)
+	}
+
+	// Past the window the key is a new finding
+	stored = nil
+	save(found.Add(2 * time.Hour))
+	if got := len(conn.inserts("api_key_findings")); got != 2 {
+		t.Errorf("%d findings saved past the window, want 2", got)
+	}
+
+	for _, st := range conn.execs {
+		if strings.Contains(st.query, "ALTER TABLE") {
+			t.Errorf("repeat finding mutated a row: %s", st.query)
+		}
+	}
+}
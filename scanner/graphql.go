@@ -0,0 +1,835 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The GraphQL endpoint implements the subset of GraphQL the dashboard needs:
+// a single read-only query operation with fields, aliases, arguments and
+// variables. Fragments, directives, mutations and introspection beyond
+// __typename are not supported.
+//
+//	type Query {
+//	  findings(type: String, submolt: String, since: String, limit: Int): [Finding!]!
+//	  messages(type: String, authorName: String, since: String, limit: Int): [Message!]!
+//	  stats(since: String): Stats!
+//	}
+//
+// since accepts an RFC 3339 timestamp or a duration like "24h", as in the
+// REST API. Secrets are only ever returned masked.
+
+// maxGraphQLBody bounds the size of a GraphQL request body
+const maxGraphQLBody = 1 << 20
+
+// startGraphQLServer serves POST /graphql (and GET with ?query=) on addr
+// until ctx is cancelled
+func (s *Scanner) startGraphQLServer(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /graphql", s.handleGraphQL)
+	mux.HandleFunc("GET /graphql", s.handleGraphQL)
+	serveHTTP(ctx, "GraphQL", addr, mux)
+}
+
+// graphQLRequest is the standard GraphQL-over-HTTP request body
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+type graphQLResponse struct {
+	Data   interface{}    `json:"data,omitempty"`
+	Errors []graphQLError `json:"errors,omitempty"`
+}
+
+// handleGraphQL serves /graphql
+func (s *Scanner) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if raw := r.URL.Query().Get("variables"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &req.Variables); err != nil {
+				writeGraphQLError(w, http.StatusBadRequest, fmt.Errorf("invalid variables: %w", err))
+				return
+			}
+		}
+	} else {
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGraphQLBody))
+		dec.UseNumber()
+		if err := dec.Decode(&req); err != nil {
+			writeGraphQLError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+	}
+
+	op, err := parseGraphQL(req.Query, req.OperationName)
+	if err != nil {
+		writeGraphQLError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	data, err := s.executeGraphQL(r.Context(), op, req.Variables)
+	if err != nil {
+		writeGraphQLError(w, http.StatusOK, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, graphQLResponse{Data: data})
+}
+
+func writeGraphQLError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, graphQLResponse{Errors: []graphQLError{{Message: err.Error()}}})
+}
+
+// gqlField is a field in a selection set
+type gqlField struct {
+	alias  string // response key, the field name unless aliased
+	name   string
+	args   map[string]interface{} // literal values or gqlVariable references
+	fields []gqlField
+}
+
+// gqlVariable is a $name reference in argument position
+type gqlVariable string
+
+// gqlOperation is a parsed query operation
+type gqlOperation struct {
+	name      string
+	defaults  map[string]interface{} // variable default values
+	selection []gqlField
+}
+
+// executeGraphQL resolves op's root fields
+func (s *Scanner) executeGraphQL(ctx context.Context, op gqlOperation, variables map[string]interface{}) (gqlObject, error) {
+	vars := make(map[string]interface{}, len(op.defaults)+len(variables))
+	for name, v := range op.defaults {
+		vars[name] = v
+	}
+	for name, v := range variables {
+		vars[name] = v
+	}
+
+	var data gqlObject
+	for _, field := range op.selection {
+		args := resolveArgs(field, vars)
+
+		var value interface{}
+		var err error
+		switch field.name {
+		case "__typename":
+			value = "Query"
+		case "findings":
+			value, err = s.resolveFindings(ctx, field, args)
+		case "messages":
+			value, err = s.resolveMessages(ctx, field, args)
+		case "stats":
+			value, err = s.resolveStats(ctx, field, args)
+		default:
+			err = fmt.Errorf("cannot query field %q on type \"Query\"", field.name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, gqlEntry{field.alias, value})
+	}
+	return data, nil
+}
+
+func (s *Scanner) resolveFindings(ctx context.Context, field gqlField, args map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(field, "type", "submolt", "since", "limit"); err != nil {
+		return nil, err
+	}
+	var filter FindingFilter
+	var err error
+	if filter.KeyType, err = stringArg(field, args, "type"); err != nil {
+		return nil, err
+	}
+	if filter.Submolt, err = stringArg(field, args, "submolt"); err != nil {
+		return nil, err
+	}
+	if filter.Since, err = sinceArg(field, args); err != nil {
+		return nil, err
+	}
+	if filter.Limit, err = limitArg(field, args); err != nil {
+		return nil, err
+	}
+
+	// Validate the selection even if no rows come back
+	if _, err := selectFields("Finding", field.fields, findingValues(FindingRecord{})); err != nil {
+		return nil, err
+	}
+
+	findings, err := s.QueryFindings(ctx, filter)
+	if err != nil {
+		log.Printf("Error resolving GraphQL findings: %v", err)
+		return nil, errors.New("findings query failed")
+	}
+
+	list := make([]gqlObject, 0, len(findings))
+	for _, f := range findings {
+		obj, err := selectFields("Finding", field.fields, findingValues(f))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, obj)
+	}
+	return list, nil
+}
+
+func (s *Scanner) resolveMessages(ctx context.Context, field gqlField, args map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(field, "type", "authorName", "since", "limit"); err != nil {
+		return nil, err
+	}
+	var filter MessageFilter
+	var err error
+	if filter.MessageType, err = stringArg(field, args, "type"); err != nil {
+		return nil, err
+	}
+	if filter.AuthorName, err = stringArg(field, args, "authorName"); err != nil {
+		return nil, err
+	}
+	if filter.Since, err = sinceArg(field, args); err != nil {
+		return nil, err
+	}
+	if filter.Limit, err = limitArg(field, args); err != nil {
+		return nil, err
+	}
+
+	if _, err := selectFields("Message", field.fields, messageValues(MessageRecord{})); err != nil {
+		return nil, err
+	}
+
+	messages, err := s.QueryMessages(ctx, filter)
+	if err != nil {
+		log.Printf("Error resolving GraphQL messages: %v", err)
+		return nil, errors.New("messages query failed")
+	}
+
+	list := make([]gqlObject, 0, len(messages))
+	for _, m := range messages {
+		obj, err := selectFields("Message", field.fields, messageValues(m))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, obj)
+	}
+	return list, nil
+}
+
+func (s *Scanner) resolveStats(ctx context.Context, field gqlField, args map[string]interface{}) (interface{}, error) {
+	if err := checkArgs(field, "since"); err != nil {
+		return nil, err
+	}
+	if len(field.fields) == 0 {
+		return nil, errors.New(`type "Stats" must have a selection of subfields`)
+	}
+	since, err := sinceArg(field, args)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := s.QueryFindingStats(ctx, since)
+	if err != nil {
+		log.Printf("Error resolving GraphQL stats: %v", err)
+		return nil, errors.New("stats query failed")
+	}
+
+	counts := func(typeName, key string, sub gqlField, tcs []TypeCount) (interface{}, error) {
+		list := make([]gqlObject, 0, len(tcs))
+		for _, tc := range tcs {
+			obj, err := selectFields(typeName, sub.fields, map[string]interface{}{key: tc.Name, "count": tc.Count})
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, obj)
+		}
+		return list, nil
+	}
+
+	var obj gqlObject
+	for _, sub := range field.fields {
+		var value interface{}
+		var err error
+		switch sub.name {
+		case "__typename":
+			value = "Stats"
+		case "total":
+			value = stats.Total
+		case "byType":
+			value, err = counts("TypeCount", "type", sub, stats.ByType)
+		case "bySeverity":
+			value, err = counts("SeverityCount", "severity", sub, stats.BySeverity)
+		default:
+			err = fmt.Errorf("cannot query field %q on type \"Stats\"", sub.name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		obj = append(obj, gqlEntry{sub.alias, value})
+	}
+	return obj, nil
+}
+
+// findingValues maps the fields of the Finding type
+func findingValues(f FindingRecord) map[string]interface{} {
+	return map[string]interface{}{
+		"postId":        f.PostID,
+		"postTitle":     f.PostTitle,
+		"authorName":    f.AuthorName,
+		"submoltName":   f.SubmoltName,
+		"apiKeyMasked":  f.APIKeyMasked,
+		"keySha256":     f.KeySHA256,
+		"apiKeyType":    f.APIKeyType,
+		"severity":      f.Severity,
+		"encoding":      f.Encoding,
+		"location":      f.Location,
+		"content":       f.Content,
+		"postUrl":       f.PostURL,
+		"sourceUrl":     f.SourceURL,
+		"foundAt":       f.FoundAt.UTC().Format(time.RFC3339Nano),
+		"postCreatedAt": f.PostCreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// messageValues maps the fields of the Message type
+func messageValues(m MessageRecord) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          m.ID,
+		"type":        m.MessageType,
+		"postId":      m.PostID,
+		"parentId":    m.ParentID,
+		"title":       m.Title,
+		"content":     m.Content,
+		"authorName":  m.AuthorName,
+		"submoltName": m.SubmoltName,
+		"messageUrl":  m.MessageURL,
+		"createdAt":   m.CreatedAt.UTC().Format(time.RFC3339Nano),
+		"scannedAt":   m.ScannedAt.UTC().Format(time.RFC3339Nano),
+		"hasApiKey":   m.HasAPIKey,
+		"apiKeyTypes": m.APIKeyTypes,
+	}
+}
+
+// selectFields projects values onto the selection set of an object type
+func selectFields(typeName string, selection []gqlField, values map[string]interface{}) (gqlObject, error) {
+	if len(selection) == 0 {
+		return nil, fmt.Errorf("type %q must have a selection of subfields", typeName)
+	}
+	obj := make(gqlObject, 0, len(selection))
+	for _, f := range selection {
+		if f.name == "__typename" {
+			obj = append(obj, gqlEntry{f.alias, typeName})
+			continue
+		}
+		value, ok := values[f.name]
+		if !ok {
+			return nil, fmt.Errorf("cannot query field %q on type %q", f.name, typeName)
+		}
+		if len(f.fields) > 0 {
+			return nil, fmt.Errorf("field %q of type %q has no subfields", f.name, typeName)
+		}
+		obj = append(obj, gqlEntry{f.alias, value})
+	}
+	return obj, nil
+}
+
+// resolveArgs substitutes variables into field's arguments
+func resolveArgs(field gqlField, vars map[string]interface{}) map[string]interface{} {
+	args := make(map[string]interface{}, len(field.args))
+	for name, v := range field.args {
+		if ref, ok := v.(gqlVariable); ok {
+			value, defined := vars[string(ref)]
+			if !defined {
+				continue
+			}
+			v = value
+		}
+		if v != nil {
+			args[name] = v
+		}
+	}
+	return args
+}
+
+// checkArgs rejects arguments field doesn't take
+func checkArgs(field gqlField, allowed ...string) error {
+	for name := range field.args {
+		known := false
+		for _, a := range allowed {
+			known = known || a == name
+		}
+		if !known {
+			return fmt.Errorf("unknown argument %q on field %q", name, field.name)
+		}
+	}
+	return nil
+}
+
+func stringArg(field gqlField, args map[string]interface{}, name string) (string, error) {
+	v, ok := args[name]
+	if !ok {
+		return "", nil
+	}
+	str, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("argument %q on field %q must be a String", name, field.name)
+	}
+	return str, nil
+}
+
+func sinceArg(field gqlField, args map[string]interface{}) (time.Time, error) {
+	raw, err := stringArg(field, args, "since")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseSince(raw)
+}
+
+// limitArg reads the limit argument, defaulting to 100 and capped like the
+// REST endpoints
+func limitArg(field gqlField, args map[string]interface{}) (int, error) {
+	v, ok := args["limit"]
+	if !ok {
+		return 100, nil
+	}
+
+	var limit int64
+	var err error
+	switch n := v.(type) {
+	case int64:
+		limit = n
+	case json.Number:
+		limit, err = n.Int64()
+	case float64:
+		limit = int64(n)
+		if float64(limit) != n {
+			err = errors.New("not an integer")
+		}
+	default:
+		err = errors.New("not an integer")
+	}
+	if err != nil || limit <= 0 || limit > maxQueryLimit {
+		return 0, fmt.Errorf("argument \"limit\" on field %q must be an Int between 1 and %d", field.name, maxQueryLimit)
+	}
+	return int(limit), nil
+}
+
+// gqlObject is a response object that keeps fields in selection order
+type gqlObject []gqlEntry
+
+type gqlEntry struct {
+	key   string
+	value interface{}
+}
+
+func (o gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, e := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(e.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(e.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// parseGraphQL parses a query document and returns the operation to run:
+// the one named operationName, or the only one in the document
+func parseGraphQL(query, operationName string) (gqlOperation, error) {
+	p := &gqlParser{src: query}
+	p.next()
+
+	var ops []gqlOperation
+	for p.tok.kind != tokEOF {
+		op, err := p.parseOperation()
+		if err != nil {
+			return gqlOperation{}, err
+		}
+		ops = append(ops, op)
+	}
+
+	switch {
+	case len(ops) == 0:
+		return gqlOperation{}, errors.New("query contains no operation")
+	case operationName != "":
+		for _, op := range ops {
+			if op.name == operationName {
+				return op, nil
+			}
+		}
+		return gqlOperation{}, fmt.Errorf("unknown operation %q", operationName)
+	case len(ops) > 1:
+		return gqlOperation{}, errors.New("operationName is required when the query contains several operations")
+	default:
+		return ops[0], nil
+	}
+}
+
+type gqlTokenKind int
+
+const (
+	tokEOF gqlTokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+	tokError
+)
+
+type gqlToken struct {
+	kind  gqlTokenKind
+	value string
+}
+
+// gqlParser is a recursive descent parser over a hand-written lexer
+type gqlParser struct {
+	src string
+	pos int
+	tok gqlToken
+}
+
+// next advances to the next token, skipping whitespace, commas and comments
+func (p *gqlParser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+			continue
+		}
+		if strings.HasPrefix(p.src[p.pos:], "\uFEFF") {
+			p.pos += len("\uFEFF")
+			continue
+		}
+		break
+	}
+	if p.pos >= len(p.src) {
+		p.tok = gqlToken{kind: tokEOF}
+		return
+	}
+
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok = gqlToken{tokPunct, "..."}
+	case strings.IndexByte("{}():$!=[]@", c) >= 0:
+		p.pos++
+		p.tok = gqlToken{tokPunct, string(c)}
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = gqlToken{tokName, p.src[start:p.pos]}
+	case c == '-' || isDigit(c):
+		p.pos++
+		kind := tokInt
+		for p.pos < len(p.src) {
+			d := p.src[p.pos]
+			switch {
+			case isDigit(d):
+			case d == '.' || d == 'e' || d == 'E':
+				kind = tokFloat
+			case (d == '+' || d == '-') && (p.src[p.pos-1] == 'e' || p.src[p.pos-1] == 'E'):
+			default:
+				p.tok = gqlToken{kind, p.src[start:p.pos]}
+				return
+			}
+			p.pos++
+		}
+		p.tok = gqlToken{kind, p.src[start:p.pos]}
+	case c == '"':
+		if strings.HasPrefix(p.src[p.pos:], `"""`) {
+			p.tok = gqlToken{tokError, "block strings are not supported"}
+			return
+		}
+		p.pos++
+		for p.pos < len(p.src) && p.src[p.pos] != '"' && p.src[p.pos] != '\n' {
+			if p.src[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.pos >= len(p.src) || p.src[p.pos] != '"' {
+			p.tok = gqlToken{tokError, "unterminated string"}
+			return
+		}
+		p.pos++
+		// GraphQL escapes are JSON's
+		var str string
+		if err := json.Unmarshal([]byte(p.src[start:p.pos]), &str); err != nil {
+			p.tok = gqlToken{tokError, "invalid string " + p.src[start:p.pos]}
+			return
+		}
+		p.tok = gqlToken{tokString, str}
+	default:
+		p.tok = gqlToken{tokError, fmt.Sprintf("unexpected character %q", c)}
+	}
+}
+
+func isLetter(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+
+// errorf reports a syntax error at the current token
+func (p *gqlParser) errorf(format string, args ...interface{}) error {
+	if p.tok.kind == tokError {
+		return fmt.Errorf("syntax error: %s", p.tok.value)
+	}
+	return fmt.Errorf("syntax error at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *gqlParser) isPunct(value string) bool {
+	return p.tok.kind == tokPunct && p.tok.value == value
+}
+
+func (p *gqlParser) expectPunct(value string) error {
+	if !p.isPunct(value) {
+		return p.errorf("expected %q", value)
+	}
+	p.next()
+	return nil
+}
+
+func (p *gqlParser) expectName() (string, error) {
+	if p.tok.kind != tokName {
+		return "", p.errorf("expected a name")
+	}
+	name := p.tok.value
+	p.next()
+	return name, nil
+}
+
+func (p *gqlParser) parseOperation() (gqlOperation, error) {
+	op := gqlOperation{defaults: make(map[string]interface{})}
+	if p.isPunct("{") {
+		sel, err := p.parseSelectionSet()
+		op.selection = sel
+		return op, err
+	}
+
+	if p.tok.kind != tokName {
+		return op, p.errorf("expected an operation")
+	}
+	switch p.tok.value {
+	case "query":
+	case "mutation", "subscription":
+		return op, fmt.Errorf("%s operations are not supported: the API is read-only", p.tok.value)
+	case "fragment":
+		return op, errors.New("fragments are not supported")
+	default:
+		return op, p.errorf("unexpected %q", p.tok.value)
+	}
+	p.next()
+
+	if p.tok.kind == tokName {
+		op.name = p.tok.value
+		p.next()
+	}
+	if p.isPunct("(") {
+		if err := p.parseVariableDefinitions(op.defaults); err != nil {
+			return op, err
+		}
+	}
+	if p.isPunct("@") {
+		return op, errors.New("directives are not supported")
+	}
+
+	sel, err := p.parseSelectionSet()
+	op.selection = sel
+	return op, err
+}
+
+// parseVariableDefinitions parses ($name: Type = default, ...), recording
+// defaults. Types are not checked beyond their syntax.
+func (p *gqlParser) parseVariableDefinitions(defaults map[string]interface{}) error {
+	p.next()
+	for !p.isPunct(")") {
+		if err := p.expectPunct("$"); err != nil {
+			return err
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return err
+		}
+		if err := p.expectPunct(":"); err != nil {
+			return err
+		}
+		if err := p.parseType(); err != nil {
+			return err
+		}
+		if p.isPunct("=") {
+			p.next()
+			value, err := p.parseValue(true)
+			if err != nil {
+				return err
+			}
+			defaults[name] = value
+		}
+	}
+	p.next()
+	return nil
+}
+
+func (p *gqlParser) parseType() error {
+	if p.isPunct("[") {
+		p.next()
+		if err := p.parseType(); err != nil {
+			return err
+		}
+		if err := p.expectPunct("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.expectName(); err != nil {
+		return err
+	}
+	if p.isPunct("!") {
+		p.next()
+	}
+	return nil
+}
+
+func (p *gqlParser) parseSelectionSet() ([]gqlField, error) {
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+
+	var fields []gqlField
+	for !p.isPunct("}") {
+		if p.isPunct("...") {
+			return nil, errors.New("fragments are not supported")
+		}
+		field, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	p.next()
+
+	if len(fields) == 0 {
+		return nil, p.errorf("empty selection set")
+	}
+	return fields, nil
+}
+
+func (p *gqlParser) parseField() (gqlField, error) {
+	name, err := p.expectName()
+	if err != nil {
+		return gqlField{}, err
+	}
+	field := gqlField{alias: name, name: name}
+	if p.isPunct(":") {
+		p.next()
+		if field.name, err = p.expectName(); err != nil {
+			return gqlField{}, err
+		}
+	}
+
+	if p.isPunct("(") {
+		p.next()
+		field.args = make(map[string]interface{})
+		for !p.isPunct(")") {
+			argName, err := p.expectName()
+			if err != nil {
+				return gqlField{}, err
+			}
+			if err := p.expectPunct(":"); err != nil {
+				return gqlField{}, err
+			}
+			value, err := p.parseValue(false)
+			if err != nil {
+				return gqlField{}, err
+			}
+			field.args[argName] = value
+		}
+		p.next()
+	}
+	if p.isPunct("@") {
+		return gqlField{}, errors.New("directives are not supported")
+	}
+
+	if p.isPunct("{") {
+		if field.fields, err = p.parseSelectionSet(); err != nil {
+			return gqlField{}, err
+		}
+	}
+	return field, nil
+}
+
+// parseValue parses a scalar value or, unless constant, a variable. Enum
+// values are returned as strings.
+func (p *gqlParser) parseValue(constant bool) (interface{}, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokPunct:
+		if tok.value == "$" && !constant {
+			p.next()
+			name, err := p.expectName()
+			return gqlVariable(name), err
+		}
+		if tok.value == "[" || tok.value == "{" {
+			return nil, errors.New("list and object values are not supported")
+		}
+	case tokString:
+		p.next()
+		return tok.value, nil
+	case tokInt:
+		p.next()
+		n, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Int %s", tok.value)
+		}
+		return n, nil
+	case tokFloat:
+		p.next()
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Float %s", tok.value)
+		}
+		return f, nil
+	case tokName:
+		p.next()
+		switch tok.value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return tok.value, nil
+	}
+	return nil, p.errorf("expected a value")
+}
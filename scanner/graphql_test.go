@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGraphQLErrors(t *testing.T) {
+	cases := []struct {
+		name          string
+		query         string
+		operationName string
+		wantErr       string
+	}{
+		{name: "empty document", query: "  # nothing\n", wantErr: "no operation"},
+		{name: "unclosed selection", query: "{ findings { postId }", wantErr: "syntax error"},
+		{name: "empty selection", query: "{ }", wantErr: "empty selection set"},
+		{name: "unterminated string", query: `{ findings(type: "AWS) { postId } }`, wantErr: "unterminated string"},
+		{name: "block string", query: `{ findings(type: """AWS""") { postId } }`, wantErr: "block strings are not supported"},
+		{name: "unexpected character", query: "{ findings % }", wantErr: "unexpected character"},
+		{name: "missing argument value", query: "{ findings(limit: ) { postId } }", wantErr: "expected a value"},
+		{name: "list value", query: "{ findings(type: [AWS]) { postId } }", wantErr: "list and object values are not supported"},
+		{name: "variable in a default", query: "query($a: Int = $b) { findings(limit: $a) { postId } }", wantErr: "expected a value"},
+		{name: "mutation", query: "mutation { deleteFindings }", wantErr: "mutation operations are not supported"},
+		{name: "subscription", query: "subscription { findings { postId } }", wantErr: "subscription operations are not supported"},
+		{name: "fragment definition", query: "fragment F on Finding { postId }", wantErr: "fragments are not supported"},
+		{name: "fragment spread", query: "{ findings { ...F } }", wantErr: "fragments are not supported"},
+		{name: "inline fragment", query: "{ findings { ... on Finding { postId } } }", wantErr: "fragments are not supported"},
+		{name: "field directive", query: "{ findings @skip(if: true) { postId } }", wantErr: "directives are not supported"},
+		{name: "operation directive", query: "query Q @cached { stats { total } }", wantErr: "directives are not supported"},
+		{name: "several operations", query: "query A { stats { total } } query B { stats { total } }", wantErr: "operationName is required"},
+		{name: "unknown operation", query: "query A { stats { total } }", operationName: "B", wantErr: `unknown operation "B"`},
+		{name: "mutation beside a query", query: "query A { stats { total } } mutation B { x }", operationName: "A", wantErr: "mutation operations are not supported"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseGraphQL(tc.query, tc.operationName)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("parseGraphQL(%q) error = %v, want it to contain %q", tc.query, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseGraphQL(t *testing.T) {
+	op, err := parseGraphQL(`
+		# Recent critical findings
+		query Recent($since: String = "24h", $limit: Int! = 10, $type: String) {
+			latest: findings(since: $since, limit: $limit, type: $type, submolt: "general") {
+				postId
+				key: keySha256
+			}
+			stats { total, __typename }
+		}`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := gqlOperation{
+		name:     "Recent",
+		defaults: map[string]interface{}{"since": "24h", "limit": int64(10)},
+		selection: []gqlField{
+			{
+				alias: "latest",
+				name:  "findings",
+				args: map[string]interface{}{
+					"since":   gqlVariable("since"),
+					"limit":   gqlVariable("limit"),
+					"type":    gqlVariable("type"),
+					"submolt": "general",
+				},
+				fields: []gqlField{
+					{alias: "postId", name: "postId"},
+					{alias: "key", name: "keySha256"},
+				},
+			},
+			{
+				alias: "stats",
+				name:  "stats",
+				fields: []gqlField{
+					{alias: "total", name: "total"},
+					{alias: "__typename", name: "__typename"},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(op, want) {
+		t.Errorf("parseGraphQL =\n%+v\nwant\n%+v", op, want)
+	}
+}
+
+func TestParseGraphQLValues(t *testing.T) {
+	op, err := parseGraphQL(`{ f(s: "a\"bé", i: -3, x: 1.5e2, t: true, n: null, e: CRITICAL) { x } }`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"s": "a\"bé",
+		"i": int64(-3),
+		"x": 150.0,
+		"t": true,
+		"n": nil,
+		"e": "CRITICAL",
+	}
+	if got := op.selection[0].args; !reflect.DeepEqual(got, want) {
+		t.Errorf("args = %v, want %v", got, want)
+	}
+}
+
+func TestParseGraphQLOperationName(t *testing.T) {
+	query := "query A { stats { total } } query B { findings { postId } } { messages { id } }"
+	for _, tc := range []struct {
+		operationName string
+		wantField     string
+	}{
+		{"A", "stats"},
+		{"B", "findings"},
+	} {
+		op, err := parseGraphQL(query, tc.operationName)
+		if err != nil {
+			t.Fatalf("parseGraphQL(operationName %q): %v", tc.operationName, err)
+		}
+		if op.name != tc.operationName || op.selection[0].name != tc.wantField {
+			t.Errorf("operationName %q selected %q querying %q, want %q", tc.operationName, op.name, op.selection[0].name, tc.wantField)
+		}
+	}
+
+	// A lone operation runs without its name being given
+	op, err := parseGraphQL("query Only { stats { total } }", "")
+	if err != nil || op.name != "Only" {
+		t.Errorf("parseGraphQL of a single operation = %q, %v", op.name, err)
+	}
+}
+
+func TestLimitArg(t *testing.T) {
+	field := gqlField{name: "findings"}
+	cases := []struct {
+		name    string
+		args    map[string]interface{}
+		want    int
+		wantErr bool
+	}{
+		{name: "default", args: map[string]interface{}{}, want: 100},
+		{name: "literal", args: map[string]interface{}{"limit": int64(5)}, want: 5},
+		{name: "JSON variable", args: map[string]interface{}{"limit": json.Number("7")}, want: 7},
+		{name: "whole float variable", args: map[string]interface{}{"limit": 8.0}, want: 8},
+		{name: "maximum", args: map[string]interface{}{"limit": int64(maxQueryLimit)}, want: maxQueryLimit},
+		{name: "above maximum", args: map[string]interface{}{"limit": int64(maxQueryLimit + 1)}, wantErr: true},
+		{name: "zero", args: map[string]interface{}{"limit": int64(0)}, wantErr: true},
+		{name: "negative", args: map[string]interface{}{"limit": int64(-1)}, wantErr: true},
+		{name: "fraction", args: map[string]interface{}{"limit": 2.5}, wantErr: true},
+		{name: "JSON fraction", args: map[string]interface{}{"limit": json.Number("2.5")}, wantErr: true},
+		{name: "string", args: map[string]interface{}{"limit": "10"}, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := limitArg(field, tc.args)
+			if (err != nil) != tc.wantErr || got != tc.want {
+				t.Errorf("limitArg(%v) = %d, %v, want %d (error %t)", tc.args, got, err, tc.want, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveArgs(t *testing.T) {
+	field := gqlField{args: map[string]interface{}{
+		"type":    gqlVariable("type"),
+		"submolt": gqlVariable("missing"),
+		"since":   nil,
+		"limit":   int64(3),
+	}}
+	got := resolveArgs(field, map[string]interface{}{"type": "AWS"})
+	want := map[string]interface{}{"type": "AWS", "limit": int64(3)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resolveArgs = %v, want %v", got, want)
+	}
+}
+
+func TestExecuteGraphQL(t *testing.T) {
+	foundAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	var gotLimit any
+	conn := &fakeConn{rows: func(query string, args []any) [][]any {
+		gotLimit = args[len(args)-1]
+		return [][]any{{
+			"f1", "p1", "title", "alice", "general", "sk-" + strings.Repeat("x", 24), "OpenAI",
+			"", "content", "masked content", "https://example.com/p1", "", foundAt, foundAt,
+			"abc123", SeverityHigh, "p1",
+		}}
+	}}
+	s := &Scanner{clickhouseConn: conn}
+
+	op, err := parseGraphQL(`query Q($n: Int = 5) {
+		leaks: findings(limit: $n) { post: postId, apiKeyType, severity, keySha256, __typename }
+		kind: __typename
+	}`, "Q")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The variable's value overrides its default
+	data, err := s.executeGraphQL(context.Background(), op, map[string]interface{}{"n": json.Number("2")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotLimit != 2 {
+		t.Errorf("findings queried with limit %v, want 2", gotLimit)
+	}
+	out, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"leaks":[{"post":"p1","apiKeyType":"OpenAI","severity":"high","keySha256":"abc123","__typename":"Finding"}],"kind":"Query"}`
+	if string(out) != want {
+		t.Errorf("response = %s, want %s", out, want)
+	}
+
+	// Without the variable the default applies
+	if _, err := s.executeGraphQL(context.Background(), op, nil); err != nil {
+		t.Fatal(err)
+	}
+	if gotLimit != 5 {
+		t.Errorf("findings queried with limit %v, want the default 5", gotLimit)
+	}
+}
+
+func TestExecuteGraphQLErrors(t *testing.T) {
+	s := &Scanner{clickhouseConn: &fakeConn{}}
+	cases := []struct {
+		name    string
+		query   string
+		wantErr string
+	}{
+		{name: "unknown root field", query: "{ secrets { key } }", wantErr: `cannot query field "secrets" on type "Query"`},
+		{name: "unknown object field", query: "{ findings { apiKey } }", wantErr: `cannot query field "apiKey" on type "Finding"`},
+		{name: "missing selection", query: "{ findings }", wantErr: `type "Finding" must have a selection of subfields`},
+		{name: "subfields of a scalar", query: "{ findings { postId { x } } }", wantErr: `field "postId" of type "Finding" has no subfields`},
+		{name: "unknown argument", query: "{ findings(author: \"x\") { postId } }", wantErr: `unknown argument "author"`},
+		{name: "limit too large", query: "{ findings(limit: 5000) { postId } }", wantErr: `argument "limit" on field "findings" must be an Int`},
+		{name: "non-string type", query: "{ messages(type: 3) { id } }", wantErr: `argument "type" on field "messages" must be a String`},
+		{name: "bad since", query: `{ stats(since: "yesterday") { total } }`, wantErr: "since must be"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			op, err := parseGraphQL(tc.query, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = s.executeGraphQL(context.Background(), op, nil)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("executeGraphQL(%q) error = %v, want it to contain %q", tc.query, err, tc.wantErr)
+			}
+		})
+	}
+}
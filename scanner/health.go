@@ -0,0 +1,37 @@
+package main
+
+import "sync"
+
+// scanHealth keeps the outcome of each scan loop's last cycle for /readyz
+type scanHealth struct {
+	mu      sync.Mutex
+	lastErr map[string]string // loop name to error, only failing loops
+}
+
+func newScanHealth() *scanHealth {
+	return &scanHealth{lastErr: make(map[string]string)}
+}
+
+// record stores the outcome of a cycle of the named loop
+func (h *scanHealth) record(name string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		scanFailuresTotal.Inc()
+		h.lastErr[name] = err.Error()
+	} else {
+		delete(h.lastErr, name)
+	}
+	scanLoopsFailing.Set(float64(len(h.lastErr)))
+}
+
+// failing returns the errors of the loops whose last cycle failed
+func (h *scanHealth) failing() map[string]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	failing := make(map[string]string, len(h.lastErr))
+	for name, err := range h.lastErr {
+		failing[name] = err
+	}
+	return failing
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// httpPoolConfig tunes connection reuse of the outbound HTTP client. The
+// defaults keep enough idle connections to the Moltbook API that busy
+// cycles don't pay for a new TLS handshake per request.
+type httpPoolConfig struct {
+	MaxIdleConns        int    `json:"max_idle_conns"`
+	MaxIdleConnsPerHost int    `json:"max_idle_conns_per_host"`
+	IdleConnTimeout     string `json:"idle_conn_timeout"`
+
+	idleConnTimeout time.Duration
+}
+
+func loadHTTPPoolConfig() httpPoolConfig {
+	timeout := getEnvDurationOrDefault("HTTP_IDLE_CONN_TIMEOUT", 90*time.Second)
+	return httpPoolConfig{
+		MaxIdleConns:        max(getEnvIntOrDefault("HTTP_MAX_IDLE_CONNS", 100), 0),
+		MaxIdleConnsPerHost: max(getEnvIntOrDefault("HTTP_MAX_IDLE_CONNS_PER_HOST", 10), 1),
+		IdleConnTimeout:     timeout.String(),
+		idleConnTimeout:     timeout,
+	}
+}
+
+// apply sets the pool limits on t
+func (c httpPoolConfig) apply(t *http.Transport) {
+	t.MaxIdleConns = c.MaxIdleConns
+	t.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	t.IdleConnTimeout = c.idleConnTimeout
+}
+
+// insecureClient returns a copy of client that doesn't verify TLS
+// certificates, for MOLTBOOK_INSECURE_SKIP_VERIFY
+func insecureClient(client *http.Client) *http.Client {
+	transport := client.Transport.(*http.Transport).Clone()
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.InsecureSkipVerify = true
+	insecure := *client
+	insecure.Transport = transport
+	return &insecure
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// startHTTPServer serves the scanner's HTTP endpoints on addr until ctx is
+// cancelled
+func (s *Scanner) startHTTPServer(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", defaultRegistry)
+	mux.HandleFunc("GET /status", s.handleStatus)
+	mux.HandleFunc("GET /readyz", s.handleReadyz)
+	mux.HandleFunc("GET /authors/top", s.handleTopAuthors)
+	mux.HandleFunc("GET /findings", s.handleFindings)
+	mux.HandleFunc("GET /findings/{id}", s.handleFindingDetail)
+	mux.HandleFunc("GET /keys/{sha256}/spread", s.handleKeySpread)
+	mux.HandleFunc("POST /reload-allowlist", s.handleReloadAllowlist)
+	mux.HandleFunc("POST /notify/replay", s.handleNotifyReplay)
+
+	serveHTTP(ctx, "HTTP", addr, mux)
+}
+
+// serveHTTP serves handler on addr in the background, shutting down when
+// ctx is cancelled
+func serveHTTP(ctx context.Context, name, addr string, handler http.Handler) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Printf("%s server listening on %s", name, addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("%s server error: %v", name, err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+}
+
+// scannerStatus is the body of GET /status
+type scannerStatus struct {
+	Seen             seenStats        `json:"seen"`
+	ClickHouseWrites writeStats       `json:"clickhouse_writes"`
+	HTTPClient       httpPoolConfig   `json:"http_client"`
+	NotifyQueue      notifyQueueStats `json:"notify_queue"`
+}
+
+// handleStatus serves GET /status
+func (s *Scanner) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, scannerStatus{
+		Seen:             s.seenMessages.Stats(),
+		ClickHouseWrites: s.writeLimiter.stats(),
+		HTTPClient:       s.httpPool,
+		NotifyQueue:      s.notifyQueue.stats(),
+	})
+}
+
+// handleReadyz serves GET /readyz: 200 while every scan loop's last cycle
+// succeeded, 503 with the failing loops' errors otherwise
+func (s *Scanner) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	failing := s.health.failing()
+	if len(failing) > 0 {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"ready": false, "failing": failing})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"ready": true})
+}
+
+// handleTopAuthors serves GET /authors/top?limit=N&since=T
+func (s *Scanner) handleTopAuthors(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r, 20)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	since, err := parseSince(r.URL.Query().Get("since"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	offenders, err := s.TopOffenders(r.Context(), limit, since)
+	if err != nil {
+		log.Printf("Error querying top offenders: %v", err)
+		writeError(w, http.StatusInternalServerError, errors.New("query failed"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, offenders)
+}
+
+// zeroUUID sorts before every finding ID, so a cursor with only a time
+// includes the findings found at exactly that time
+const zeroUUID = "00000000-0000-0000-0000-000000000000"
+
+// findingsPage is the body of GET /findings. Next is the cursor to request
+// the following page with; it is the request's own cursor when the page is
+// empty, so a consumer can keep polling with it.
+type findingsPage struct {
+	Findings []FindingRecord `json:"findings"`
+	Next     FindingCursor   `json:"next"`
+}
+
+// handleFindings serves GET /findings?after_found_at=T&after_id=ID&limit=N,
+// the findings after the cursor oldest first. Without a cursor it starts at
+// the oldest finding; after_found_at alone starts at that time. A row whose
+// insert lands after a consumer has paged past its found_at is not seen by
+// that consumer, so with CLICKHOUSE_ASYNC_INSERT consumers should stay a
+// few seconds behind the newest cursor.
+func (s *Scanner) handleFindings(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r, 100)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	cursor, err := parseFindingCursor(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	findings, err := s.QueryFindings(r.Context(), FindingFilter{After: &cursor, Limit: limit})
+	if err != nil {
+		log.Printf("Error querying findings: %v", err)
+		writeError(w, http.StatusInternalServerError, errors.New("query failed"))
+		return
+	}
+
+	page := findingsPage{Findings: findings, Next: cursor}
+	if len(findings) > 0 {
+		last := findings[len(findings)-1]
+		page.Next = FindingCursor{FoundAt: last.FoundAt.UTC(), ID: last.ID}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// parseFindingCursor reads the after_found_at and after_id query parameters
+func parseFindingCursor(r *http.Request) (FindingCursor, error) {
+	q := r.URL.Query()
+	rawTime, id := q.Get("after_found_at"), q.Get("after_id")
+	if rawTime == "" {
+		if id != "" {
+			return FindingCursor{}, errors.New("after_id requires after_found_at")
+		}
+		return FindingCursor{}, nil
+	}
+
+	foundAt, err := time.Parse(time.RFC3339Nano, rawTime)
+	if err != nil {
+		return FindingCursor{}, errors.New("after_found_at must be an RFC 3339 timestamp")
+	}
+	if id == "" {
+		id = zeroUUID
+	} else if !uuidPattern.MatchString(id) {
+		return FindingCursor{}, errors.New("after_id must be a UUID")
+	}
+	return FindingCursor{FoundAt: foundAt, ID: id}, nil
+}
+
+// uuidPattern matches the finding IDs accepted by GET /findings/{id}
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// findingDetail is the body of GET /findings/{id}: the finding and the
+// stored post or comment it was found in, secrets masked. APIKey is only
+// set for an authorized reveal.
+type findingDetail struct {
+	FindingRecord
+	APIKey  string         `json:"api_key,omitempty"`
+	Message *MessageRecord `json:"message"`
+}
+
+// handleFindingDetail serves GET /findings/{id}. The unmasked key is only
+// returned with "X-Reveal: true" and "Authorization: Bearer <REVEAL_TOKEN>",
+// and every reveal attempt is logged for audit.
+func (s *Scanner) handleFindingDetail(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !uuidPattern.MatchString(id) {
+		writeError(w, http.StatusBadRequest, errors.New("id must be a UUID"))
+		return
+	}
+
+	reveal, _ := strconv.ParseBool(r.Header.Get("X-Reveal"))
+	if reveal && !s.revealAuthorized(r) {
+		log.Printf("🔒 AUDIT: denied key reveal for finding %s to %s", id, r.RemoteAddr)
+		writeError(w, http.StatusForbidden, errors.New("reveal not authorized"))
+		return
+	}
+
+	findings, err := s.QueryFindings(r.Context(), FindingFilter{ID: id, Limit: 1})
+	if err != nil {
+		log.Printf("Error querying finding %s: %v", id, err)
+		writeError(w, http.StatusInternalServerError, errors.New("query failed"))
+		return
+	}
+	if len(findings) == 0 {
+		writeError(w, http.StatusNotFound, errors.New("finding not found"))
+		return
+	}
+
+	detail := findingDetail{FindingRecord: findings[0]}
+	// Findings saved before message_id existed only know their post
+	messageID := detail.MessageID
+	if messageID == "" {
+		messageID = detail.PostID
+	}
+	messages, err := s.QueryMessages(r.Context(), MessageFilter{ID: messageID, Limit: 1})
+	if err != nil {
+		log.Printf("Error querying message %s: %v", messageID, err)
+		writeError(w, http.StatusInternalServerError, errors.New("query failed"))
+		return
+	}
+	if len(messages) > 0 {
+		detail.Message = &messages[0]
+	}
+
+	if reveal {
+		log.Printf("🔓 AUDIT: revealed %s key %s of finding %s to %s", detail.APIKeyType, detail.KeySHA256, id, r.RemoteAddr)
+		detail.APIKey = detail.apiKey
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	writeJSON(w, http.StatusOK, detail)
+}
+
+// keyHashPattern matches the key fingerprints accepted by GET /keys/{sha256}/spread
+var keyHashPattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// handleKeySpread serves GET /keys/{sha256}/spread, where sha256 is a
+// finding's key_sha256
+func (s *Scanner) handleKeySpread(w http.ResponseWriter, r *http.Request) {
+	keyHash := r.PathValue("sha256")
+	if !keyHashPattern.MatchString(keyHash) {
+		writeError(w, http.StatusBadRequest, errors.New("sha256 must be a hex SHA-256 fingerprint"))
+		return
+	}
+
+	spread, err := s.KeySpread(r.Context(), keyHash)
+	if err != nil {
+		log.Printf("Error querying spread of key %s: %v", keyHash, err)
+		writeError(w, http.StatusInternalServerError, errors.New("query failed"))
+		return
+	}
+	if len(spread.Occurrences) == 0 {
+		writeError(w, http.StatusNotFound, errors.New("key not found"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, spread)
+}
+
+// revealAuthorized reports whether r carries REVEAL_TOKEN as a bearer
+// token. Reveals are disabled while REVEAL_TOKEN is unset.
+func (s *Scanner) revealAuthorized(r *http.Request) bool {
+	return hasBearerToken(r, s.revealToken)
+}
+
+// hasBearerToken reports whether r is authorized with "Bearer <token>". An
+// empty token authorizes nothing.
+func hasBearerToken(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// handleReloadAllowlist serves POST /reload-allowlist, guarded by
+// ADMIN_TOKEN. A malformed file is rejected and the current allowlist kept.
+func (s *Scanner) handleReloadAllowlist(w http.ResponseWriter, r *http.Request) {
+	if !hasBearerToken(r, s.adminToken) {
+		writeError(w, http.StatusForbidden, errors.New("not authorized"))
+		return
+	}
+
+	entries, err := s.ReloadAllowlist()
+	if err != nil {
+		log.Printf("Allowlist reload failed, keeping the current allowlist: %v", err)
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	log.Printf("Reloaded %d allowlist entries from %s", entries, s.allowlistFile)
+	writeJSON(w, http.StatusOK, map[string]int{"entries": entries})
+}
+
+// handleNotifyReplay serves POST /notify/replay, guarded by ADMIN_TOKEN. It
+// sends the findings since the since parameter (and before until, if given)
+// to the notifiers again; force=true bypasses the throttle and dedup.
+func (s *Scanner) handleNotifyReplay(w http.ResponseWriter, r *http.Request) {
+	if !hasBearerToken(r, s.adminToken) {
+		writeError(w, http.StatusForbidden, errors.New("not authorized"))
+		return
+	}
+
+	q := r.URL.Query()
+	if q.Get("since") == "" {
+		writeError(w, http.StatusBadRequest, errors.New("since is required"))
+		return
+	}
+	since, err := parseSince(q.Get("since"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var until time.Time
+	if raw := q.Get("until"); raw != "" {
+		if until, err = time.Parse(time.RFC3339, raw); err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("until must be an RFC 3339 timestamp"))
+			return
+		}
+	}
+	force := false
+	if raw := q.Get("force"); raw != "" {
+		if force, err = strconv.ParseBool(raw); err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("force must be true or false"))
+			return
+		}
+	}
+
+	result, err := s.ReplayNotifications(r.Context(), since, until, force)
+	if err != nil {
+		log.Printf("Notification replay failed: %v", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Printf("Replayed notifications since %s: %d findings, %d dispatched, %d skipped (force=%t)",
+		since.Format(time.RFC3339), result.Findings, result.Dispatched, result.Skipped, force)
+	writeJSON(w, http.StatusOK, result)
+}
+
+// maxQueryLimit caps the number of rows any read endpoint returns
+const maxQueryLimit = 1000
+
+// parseLimit reads the limit query parameter, defaulting to defaultLimit
+func parseLimit(r *http.Request, defaultLimit int) (int, error) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 || limit > maxQueryLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxQueryLimit)
+	}
+	return limit, nil
+}
+
+// parseSince accepts an RFC 3339 timestamp or a duration looking back from
+// now (e.g. "24h"). An empty value means no lower bound.
+func parseSince(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(raw); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("since must be an RFC 3339 timestamp or a duration like 24h")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding HTTP response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindingDetailShowsTheCommentItWasFoundIn(t *testing.T) {
+	const findingID = "0b6c1d2e-6f1a-4c1e-9a51-2d5e8f3b7c40"
+	for _, tc := range []struct {
+		name, messageID, wantQueried string
+	}{
+		{name: "comment finding", messageID: "c1", wantQueried: "c1"},
+		{name: "finding saved without message_id", messageID: "", wantQueried: "p1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var queried []any
+			conn := &fakeConn{rows: func(query string, args []any) [][]any {
+				if strings.Contains(query, "api_key_findings") {
+					return [][]any{{
+						findingID, "p1", "title (comment)", "alice", "general", "sk-" + strings.Repeat("x", 24), "OpenAI",
+						"", "content", "masked", "https://www.moltbook.com/post/p1", "", time.Now(), time.Now(),
+						"abc123", SeverityCritical, tc.messageID,
+					}}
+				}
+				queried = append(queried, args[1]) // after since
+				return nil
+			}}
+			s := &Scanner{clickhouseConn: conn}
+
+			req := httptest.NewRequest(http.MethodGet, "/findings/"+findingID, nil)
+			req.SetPathValue("id", findingID)
+			rec := httptest.NewRecorder()
+			s.handleFindingDetail(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+			}
+			if len(queried) != 1 || queried[0] != tc.wantQueried {
+				t.Errorf("messages queried by %v, want %q", queried, tc.wantQueried)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// lengthBounds limits the length in bytes of the matches a pattern reports.
+// A zero max means no upper limit.
+type lengthBounds struct {
+	min, max int
+}
+
+// allows reports whether a match of n bytes is within the bounds
+func (b lengthBounds) allows(n int) bool {
+	return n >= b.min && (b.max == 0 || n <= b.max)
+}
+
+func (b lengthBounds) validate() error {
+	if b.min < 0 || b.max < 0 {
+		return fmt.Errorf("lengths must not be negative")
+	}
+	if b.max != 0 && b.max < b.min {
+		return fmt.Errorf("max length %d is below min length %d", b.max, b.min)
+	}
+	return nil
+}
+
+// defaultLengthBounds are the built-in bounds by key type. The generic
+// patterns end in an open-ended {20,}, so without a cap they also report
+// long runs of text that merely follow "api_key=".
+var defaultLengthBounds = map[string]lengthBounds{
+	"generic": {max: 512},
+}
+
+// parseLengthBounds parses PATTERN_LENGTHS, comma-separated
+// KeyType=min:max entries with either side optional, e.g.
+// "Generic=24:256,OpenAI=40:". Entries replace the built-in bounds of
+// their key type; key types are matched case-insensitively.
+func parseLengthBounds(raw string) (map[string]lengthBounds, error) {
+	bounds := make(map[string]lengthBounds, len(defaultLengthBounds))
+	for keyType, b := range defaultLengthBounds {
+		bounds[keyType] = b
+	}
+
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		keyType, rng, ok := strings.Cut(entry, "=")
+		keyType = strings.ToLower(strings.TrimSpace(keyType))
+		minStr, maxStr, hasColon := strings.Cut(rng, ":")
+		if !ok || keyType == "" || !hasColon {
+			return nil, fmt.Errorf("expected KeyType=min:max, got %q", entry)
+		}
+
+		var b lengthBounds
+		var err error
+		if minStr = strings.TrimSpace(minStr); minStr != "" {
+			if b.min, err = strconv.Atoi(minStr); err != nil {
+				return nil, fmt.Errorf("invalid min length in %q", entry)
+			}
+		}
+		if maxStr = strings.TrimSpace(maxStr); maxStr != "" {
+			if b.max, err = strconv.Atoi(maxStr); err != nil {
+				return nil, fmt.Errorf("invalid max length in %q", entry)
+			}
+		}
+		if err := b.validate(); err != nil {
+			return nil, fmt.Errorf("%q: %w", entry, err)
+		}
+		bounds[keyType] = b
+	}
+	return bounds, nil
+}
+
+// applyLengthBounds sets the length bounds of each pattern from bounds,
+// keyed by the lower-cased key type the pattern is registered under
+func applyLengthBounds(patterns []keyPattern, contextPatterns []contextPattern, bounds map[string]lengthBounds) {
+	for i := range patterns {
+		patterns[i].lengths = bounds[strings.ToLower(patterns[i].keyType)]
+	}
+	for i := range contextPatterns {
+		contextPatterns[i].lengths = bounds[strings.ToLower(contextPatterns[i].keyType)]
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultLinkedHosts are paste and raw-file hosts where secrets commonly end
+// up when they are linked from a post rather than pasted into it
+const defaultLinkedHosts = "raw.githubusercontent.com,gist.githubusercontent.com,pastebin.com"
+
+var linkPattern = regexp.MustCompile(`https?://[^\s<>"'()\[\]]+`)
+
+// linkFetcher downloads the documents linked from posts so they can be
+// scanned too. Only http(s) URLs on allowlisted hosts are followed, which
+// keeps the scanner from being pointed at internal services.
+type linkFetcher struct {
+	hosts     map[string]bool
+	maxLinks  int
+	maxBytes  int64
+	userAgent string
+	client    *http.Client
+}
+
+// newLinkFetcherFromEnv returns nil unless SCAN_LINKED is enabled
+func newLinkFetcherFromEnv(httpClient *http.Client, userAgent string) *linkFetcher {
+	if !getEnvBoolOrDefault("SCAN_LINKED", false) {
+		return nil
+	}
+
+	f := &linkFetcher{
+		hosts:     parseNameSet(getEnvOrDefault("LINKED_HOSTS", defaultLinkedHosts)),
+		maxLinks:  getEnvIntOrDefault("LINKED_MAX_LINKS", 3),
+		maxBytes:  int64(getEnvIntOrDefault("LINKED_MAX_BYTES", 256*1024)),
+		userAgent: userAgent,
+	}
+	// Redirects must stay on allowlisted hosts as well
+	client := *httpClient
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("too many redirects")
+		}
+		if !f.allowed(req.URL) {
+			return fmt.Errorf("redirect to disallowed host %s", req.URL.Hostname())
+		}
+		return nil
+	}
+	f.client = &client
+
+	log.Printf("Linked content scanning enabled for hosts: %s", strings.Join(sortedKeys(f.hosts), ", "))
+	return f
+}
+
+// allowed reports whether u is an http(s) URL on an allowlisted host
+func (f *linkFetcher) allowed(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	if u.User != nil {
+		return false
+	}
+	return f.hosts[strings.ToLower(u.Hostname())]
+}
+
+// links returns up to maxLinks distinct allowlisted URLs from the post URL
+// and the links in its content
+func (f *linkFetcher) links(post MoltbookPost) []string {
+	candidates := append([]string{post.URL}, linkPattern.FindAllString(post.Content, -1)...)
+
+	seen := make(map[string]bool)
+	var links []string
+	for _, raw := range candidates {
+		if len(links) >= f.maxLinks {
+			break
+		}
+		// Trailing punctuation is usually part of the sentence, not the URL
+		raw = strings.TrimRight(strings.TrimSpace(raw), ".,;:!?")
+		if raw == "" || seen[raw] {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || !f.allowed(u) {
+			continue
+		}
+		seen[raw] = true
+		links = append(links, raw)
+	}
+	return links
+}
+
+// fetch downloads up to maxBytes of link
+func (f *linkFetcher) fetch(ctx context.Context, link string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", link, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to read body: %w", err)
+	}
+	return string(body), nil
+}
+
+// scanPostLinks fetches the allowlisted documents linked from post and
+// returns their findings, with SourceURL set to the document they came from.
+// Keys already in known (the post's own findings) are not reported again.
+func (s *Scanner) scanPostLinks(ctx context.Context, post MoltbookPost, known []APIKeyFinding) []APIKeyFinding {
+	if s.linkFetcher == nil {
+		return nil
+	}
+
+	reported := make(map[string]bool)
+	for _, f := range known {
+		reported[f.APIKey] = true
+	}
+
+	var findings []APIKeyFinding
+	for _, link := range s.linkFetcher.links(post) {
+		body, err := s.linkFetcher.fetch(ctx, link)
+		if err != nil {
+			logDebug("Skipping linked document %s from post %s: %v", link, post.ID, err)
+			continue
+		}
+
+		// Scan the document as if it were the post's content
+		linked := post
+		linked.Title = ""
+		linked.Content = body
+		for _, f := range s.ScanPost(linked) {
+			if reported[f.APIKey] {
+				continue
+			}
+			reported[f.APIKey] = true
+			f.PostTitle = post.Title
+			f.SourceURL = link
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// sortedKeys returns the keys of set in sorted order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
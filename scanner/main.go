@@ -2,17 +2,30 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
@@ -21,16 +34,19 @@ import (
 
 // MoltbookPost represents a post from the Moltbook API
 type MoltbookPost struct {
-	ID           string    `json:"id"`
-	Title        string    `json:"title"`
-	Content      string    `json:"content"`
-	URL          string    `json:"url"`
-	Upvotes      int       `json:"upvotes"`
-	Downvotes    int       `json:"downvotes"`
-	CommentCount int       `json:"comment_count"`
-	CreatedAt    time.Time `json:"created_at"`
-	Author       *Author   `json:"author"`
-	Submolt      *Submolt  `json:"submolt"`
+	ID           string       `json:"id"`
+	Title        string       `json:"title"`
+	Content      string       `json:"content"`
+	URL          string       `json:"url"`
+	Upvotes      int          `json:"upvotes"`
+	Downvotes    int          `json:"downvotes"`
+	CommentCount int          `json:"comment_count"`
+	CreatedAt    time.Time    `json:"created_at"`
+	Author       *Author      `json:"author"`
+	Submolt      *Submolt     `json:"submolt"`
+	Attachments  []Attachment `json:"attachments"`
+
+	raw json.RawMessage // the JSON the post was decoded from
 }
 
 // MoltbookComment represents a comment from the Moltbook API
@@ -44,6 +60,8 @@ type MoltbookComment struct {
 	CreatedAt time.Time         `json:"created_at"`
 	Author    *Author           `json:"author"`
 	Replies   []MoltbookComment `json:"replies"`
+
+	raw json.RawMessage // the JSON the comment was decoded from
 }
 
 type Author struct {
@@ -56,6 +74,7 @@ type Submolt struct {
 	ID          string `json:"id"`
 	Name        string `json:"name"`
 	DisplayName string `json:"display_name"`
+	Description string `json:"description,omitempty"`
 }
 
 type FeedResponse struct {
@@ -66,9 +85,11 @@ type FeedResponse struct {
 }
 
 type CommentsResponse struct {
-	Success  bool              `json:"success"`
-	Comments []MoltbookComment `json:"comments"`
-	Count    int               `json:"count"`
+	Success    bool              `json:"success"`
+	Comments   []MoltbookComment `json:"comments"`
+	Count      int               `json:"count"`
+	HasMore    bool              `json:"has_more"`
+	NextCursor string            `json:"next_cursor"`
 }
 
 // ScannedMessage represents a message stored in ClickHouse
@@ -91,6 +112,19 @@ type ScannedMessage struct {
 	ScannedAt    time.Time
 	HasAPIKey    bool
 	APIKeyTypes  []string
+
+	// hash overrides the contentHash of Title and Content stored as
+	// content_sha256, for messages replayed from DLQ_FILE with their
+	// secrets masked
+	hash string
+}
+
+// contentSHA256 returns the content hash the seen set knows msg by
+func (msg ScannedMessage) contentSHA256() string {
+	if msg.hash != "" {
+		return msg.hash
+	}
+	return contentHash(msg.Title, msg.Content)
 }
 
 // APIKeyFinding represents a found API key in a post
@@ -101,22 +135,137 @@ type APIKeyFinding struct {
 	SubmoltName   string
 	APIKey        string
 	APIKeyType    string
+	Severity      string
+	Encoding      string // how the key was encoded in the message, "" if raw
 	Content       string
 	PostURL       string
 	FoundAt       time.Time
 	PostCreatedAt time.Time
+	// DetectionLatency is FoundAt minus PostCreatedAt, clamped at zero
+	DetectionLatency time.Duration
+	// SourceURL is the linked document, or "attachment:<filename>" for the
+	// attachment, the key was found in, "" if it was in the message itself
+	SourceURL string
+	// Origin is what produced the finding outside the scan loop, e.g.
+	// "scan-author", "" for the scan loop itself
+	Origin string
+	// Confidence is how likely the match is a real credential, from 0 to 1
+	Confidence float64
+	// Location is the part of the message the key was in, one of the
+	// Location constants, "" for submolt metadata
+	Location string
+	// MessageID is the post or comment the key was found in, "" for
+	// submolt metadata; PostID is a comment's post
+	MessageID string
+
+	// keyHash overrides hashKey(APIKey) as the stored key_sha256, for
+	// findings replayed from DLQ_FILE or POST /notify/replay with a masked
+	// key
+	keyHash string
+}
+
+// keySHA256 returns the fingerprint of the finding's key
+func (f APIKeyFinding) keySHA256() string {
+	if f.keyHash != "" {
+		return f.keyHash
+	}
+	return hashKey(f.APIKey)
 }
 
+// Finding locations, the part of a message a key was found in
+const (
+	LocationTitle      = "title"
+	LocationContent    = "content"
+	LocationAttachment = "attachment"
+)
+
 // Scanner is the main service struct
 type Scanner struct {
-	moltbookAPIKey string
-	clickhouseConn driver.Conn
-	httpClient     *http.Client
-	apiKeyPatterns []*regexp.Regexp
-	baseURL        string
-	pollInterval   time.Duration
-	seenMessages   map[string]bool // tracks both posts and comments by ID
-	databaseName   string
+	moltbookAPIKey  apiKeySource
+	clickhouseConn  driver.Conn
+	httpClient      *http.Client
+	apiClient       *http.Client // httpClient, or a copy skipping TLS verification with MOLTBOOK_INSECURE_SKIP_VERIFY
+	httpPool        httpPoolConfig
+	apiKeyPatterns  []keyPattern
+	contextPatterns []contextPattern
+	keyTypes        *keyTypeFilter
+	piiPatterns     []piiPattern // nil unless SCAN_PII is enabled
+	baseURL         string
+	postInterval    time.Duration
+	pollJitter      float64 // POLL_JITTER, the fraction intervals vary by either way
+	commentInterval time.Duration
+	seenMessages    *syncSeenSet // tracks both posts and comments by ID
+	seenLoadWorkers int          // SEEN_LOAD_CONCURRENCY
+	notifiers       []Notifier
+	alertRoutes     alertRoutes
+	submoltFilter   *submoltFilter
+	targets         scanTargets
+	notifyThrottle  *notifyThrottle
+	notifyDedup     *notifyDedup  // nil when NOTIFY_DEDUP_TTL is 0
+	notifyQueue     *notifyQueue  // nil without notifiers
+	findingDedup    *findingDedup // nil unless FINDING_DEDUP_WINDOW is set
+	databaseName    string
+	tablePrefix     string // prepended to every table name, e.g. "tenantA_"
+	contentCodec    string // MESSAGE_CONTENT_CODEC as a column clause, e.g. " CODEC(ZSTD(3))"
+	writeLimiter    *writeLimiter
+	contentMode     string // FINDING_CONTENT_MODE, one of the ContentMode constants
+	maxScanBytes    int
+	contextWindow   int // runes of content kept either side of a match in findings
+	shutdownGrace   time.Duration
+	matchTimeout    time.Duration
+	decodeEntities  bool
+	rawPayloads     *rawPayloadBuffer // nil unless STORE_RAW_PAYLOAD is enabled
+	trackRevisions  bool              // keep edited messages' old content in message_revisions
+	deadLetters     *deadLetterFile   // nil unless DLQ_FILE is set
+	fetchMaxRetries int
+	maxRespBytes    int64
+	insertSettings  clickhouse.Settings // nil unless CLICKHOUSE_ASYNC_INSERT is enabled
+	commentMaxPages int
+	minComments     int
+	createViews     bool
+	maxMessageAge   time.Duration
+	commentBackfill *commentBackfill
+	minConfidence   float64
+	maxSaveErrRate  float64
+	health          *scanHealth
+	activity        *activityCounters
+	submoltSpikes   *submoltSpikes // nil if SUBMOLT_SPIKE_MULTIPLE is 0
+	digestInterval  time.Duration
+	submoltInterval time.Duration // 0 disables the submolt metadata scan
+	digestNotify    bool
+	userAgent       string
+	linkFetcher     *linkFetcher      // nil unless SCAN_LINKED is enabled
+	attachments     *attachmentLimits // nil when SCAN_ATTACHMENTS is disabled
+	// commentsSince is the newest comment timestamp seen, used to only
+	// fetch newer recent comments
+	commentsSince    time.Time
+	sinceUnsupported bool           // the comments endpoint rejected the since parameter
+	recentComments   bool           // ENABLE_RECENT_COMMENTS, cleared if the endpoint is missing
+	threadComments   threadComments // comments the post loop fetched, for the recent comments scan
+	extraHeaders     http.Header    // sent with every Moltbook API request
+	feedValidators   validatorCache // ETag/Last-Modified of the post feed
+	listenAddr       string
+	adminToken       string
+	allowlistFile    string
+	allowlist        atomic.Pointer[keyAllowlist]
+	graphQLAddr      string
+	revealToken      string          // REVEAL_TOKEN, "" disables key reveals
+	shutdown         <-chan struct{} // closed once Run has been asked to stop
+	// submoltMeta is the content hash of each submolt's metadata when last
+	// scanned, only touched by the submolt scan loop
+	submoltMeta map[string]string
+	// clock returns the time stamped on findings, messages and other
+	// records the scanner generates, and the time message ages and prune
+	// cutoffs are measured from; nil means time.Now
+	clock func() time.Time
+}
+
+// now returns the current time from the scanner's clock
+func (s *Scanner) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock()
 }
 
 // NewScanner creates a new scanner instance
@@ -124,63 +273,93 @@ func NewScanner() (*Scanner, error) {
 	// Load environment variables
 	_ = godotenv.Load()
 
-	moltbookAPIKey := os.Getenv("MOLTBOOK_API_KEY")
-	if moltbookAPIKey == "" {
-		return nil, fmt.Errorf("MOLTBOOK_API_KEY environment variable is required")
+	moltbookAPIKey, err := getSecretEnv("MOLTBOOK_API_KEY")
+	if err != nil {
+		return nil, err
+	}
+	rawAPIKeys, err := getSecretEnv("MOLTBOOK_API_KEYS")
+	if err != nil {
+		return nil, err
+	}
+	var apiKeys apiKeySource
+	if keys := parseAPIKeys(rawAPIKeys); len(keys) > 0 {
+		log.Printf("Using %d Moltbook API keys round-robin", len(keys))
+		apiKeys = newKeyPool(keys)
+	} else if moltbookAPIKey != "" {
+		apiKeys = newRotatingKey(moltbookAPIKey)
+	} else {
+		return nil, fmt.Errorf("MOLTBOOK_API_KEY or MOLTBOOK_API_KEY_FILE environment variable is required")
+	}
+
+	revealToken, err := getSecretEnv("REVEAL_TOKEN")
+	if err != nil {
+		return nil, err
 	}
 
-	clickhouseHost := getEnvOrDefault("CLICKHOUSE_HOST", "localhost")
-	clickhousePort := getEnvOrDefault("CLICKHOUSE_PORT", "9000")
+	chConfig, err := loadClickHouseConfig()
+	if err != nil {
+		return nil, err
+	}
+	// Both end up in SQL, so only plain identifiers are allowed
 	clickhouseDB := getEnvOrDefault("CLICKHOUSE_DATABASE", "moltbook")
-	clickhouseUser := getEnvOrDefault("CLICKHOUSE_USER", "default")
-	clickhousePassword := os.Getenv("CLICKHOUSE_PASSWORD")
+	if !identifierPattern.MatchString(clickhouseDB) {
+		return nil, fmt.Errorf("invalid CLICKHOUSE_DATABASE %q: only letters, digits and underscores are allowed", clickhouseDB)
+	}
+	tablePrefix := os.Getenv("TABLE_PREFIX")
+	if tablePrefix != "" && !identifierPattern.MatchString(tablePrefix) {
+		return nil, fmt.Errorf("invalid TABLE_PREFIX %q: only letters, digits and underscores are allowed", tablePrefix)
+	}
+	contentCodec, err := parseContentCodec(os.Getenv("MESSAGE_CONTENT_CODEC"))
+	if err != nil {
+		return nil, err
+	}
 
 	pollIntervalStr := getEnvOrDefault("POLL_INTERVAL", "60s")
 	pollInterval, err := time.ParseDuration(pollIntervalStr)
 	if err != nil {
 		pollInterval = 60 * time.Second
 	}
+	postInterval := getEnvDurationOrDefault("POLL_INTERVAL_POSTS", pollInterval)
+	pollJitter, err := parsePollJitter(os.Getenv("POLL_JITTER"))
+	if err != nil {
+		return nil, err
+	}
+	commentInterval := getEnvDurationOrDefault("POLL_INTERVAL_COMMENTS", pollInterval)
+	if postInterval <= 0 || commentInterval <= 0 {
+		return nil, fmt.Errorf("POLL_INTERVAL_POSTS and POLL_INTERVAL_COMMENTS must be positive")
+	}
+
+	maxScanBytes := getEnvIntOrDefault("MAX_SCAN_BYTES", 256*1024)
+	contextWindow := getEnvIntOrDefault("CONTEXT_WINDOW", 200)
+	contentMode := strings.ToLower(getEnvOrDefault("FINDING_CONTENT_MODE", ContentModeMaskedExcerpt))
+	switch contentMode {
+	case ContentModeNone, ContentModeMaskedExcerpt, ContentModeFull:
+	default:
+		return nil, fmt.Errorf("invalid FINDING_CONTENT_MODE %q: must be \"none\", \"masked_excerpt\" or \"full\"", contentMode)
+	}
+
+	notifyThrottleInterval := getEnvDurationOrDefault("NOTIFY_THROTTLE", time.Hour)
+	var notifyDedup *notifyDedup
+	if ttl := getEnvDurationOrDefault("NOTIFY_DEDUP_TTL", 30*24*time.Hour); ttl > 0 {
+		notifyDedup = newNotifyDedup(ttl)
+	}
+	shutdownGrace := getEnvDurationOrDefault("SHUTDOWN_GRACE", 10*time.Second)
 
 	// First connect to ClickHouse without specifying database to create it
-	initConn, err := clickhouse.Open(&clickhouse.Options{
-		Addr: []string{fmt.Sprintf("%s:%s", clickhouseHost, clickhousePort)},
-		Auth: clickhouse.Auth{
-			Username: clickhouseUser,
-			Password: clickhousePassword,
-		},
-		Settings: clickhouse.Settings{
-			"max_execution_time": 60,
-		},
-		Compression: &clickhouse.Compression{
-			Method: clickhouse.CompressionLZ4,
-		},
-	})
+	initConn, err := clickhouse.Open(chConfig.options(""))
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to ClickHouse: %w", err)
 	}
 
 	// Create database if it doesn't exist
-	if err := initConn.Exec(context.Background(), fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", clickhouseDB)); err != nil {
+	if err := initConn.Exec(context.Background(), fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", quoteIdent(clickhouseDB))); err != nil {
 		initConn.Close()
 		return nil, fmt.Errorf("failed to create database: %w", err)
 	}
 	initConn.Close()
 
 	// Now connect to the specific database
-	conn, err := clickhouse.Open(&clickhouse.Options{
-		Addr: []string{fmt.Sprintf("%s:%s", clickhouseHost, clickhousePort)},
-		Auth: clickhouse.Auth{
-			Database: clickhouseDB,
-			Username: clickhouseUser,
-			Password: clickhousePassword,
-		},
-		Settings: clickhouse.Settings{
-			"max_execution_time": 60,
-		},
-		Compression: &clickhouse.Compression{
-			Method: clickhouse.CompressionLZ4,
-		},
-	})
+	conn, err := clickhouse.Open(chConfig.options(clickhouseDB))
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to ClickHouse database: %w", err)
 	}
@@ -190,99 +369,552 @@ func NewScanner() (*Scanner, error) {
 		return nil, fmt.Errorf("failed to ping ClickHouse: %w", err)
 	}
 
-	// Compile API key patterns
-	patterns := compileAPIKeyPatterns()
+	seenMessages, err := newSeenSet(seenConfig{
+		Backend:    getEnvOrDefault("SEEN_BACKEND", "map"),
+		Shards:     getEnvIntOrDefault("SEEN_SHARDS", 32),
+		Capacity:   getEnvIntOrDefault("SEEN_CAPACITY", 1_000_000),
+		FPRate:     getEnvFloatOrDefault("SEEN_FP_RATE", 0.001),
+		MaxEntries: getEnvIntOrDefault("SEEN_MAX_ENTRIES", 100_000),
+		TTL:        getEnvDurationOrDefault("SEEN_TTL", 7*24*time.Hour),
+	})
+	if err != nil {
+		return nil, err
+	}
+	seenLoadWorkers := getEnvIntOrDefault("SEEN_LOAD_CONCURRENCY", 1)
+	if seenLoadWorkers < 1 {
+		return nil, fmt.Errorf("SEEN_LOAD_CONCURRENCY must be positive, got %d", seenLoadWorkers)
+	}
 
-	return &Scanner{
-		moltbookAPIKey: moltbookAPIKey,
-		clickhouseConn: conn,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		apiKeyPatterns: patterns,
-		baseURL:        "https://www.moltbook.com/api/v1",
-		pollInterval:   pollInterval,
-		seenMessages:   make(map[string]bool),
-		databaseName:   clickhouseDB,
+	debugEnabled = strings.EqualFold(os.Getenv("LOG_LEVEL"), "debug")
+
+	// Compile API key patterns, keeping only the enabled key types
+	keyTypes := newKeyTypeFilter(os.Getenv("ENABLED_KEY_TYPES"), os.Getenv("DISABLED_KEY_TYPES"))
+	patterns := filterKeyPatterns(compileAPIKeyPatterns(), keyTypes)
+	contextPatterns := filterContextPatterns(compileContextPatterns(), keyTypes)
+	keyLengths, err := parseLengthBounds(os.Getenv("PATTERN_LENGTHS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PATTERN_LENGTHS: %w", err)
+	}
+	applyLengthBounds(patterns, contextPatterns, keyLengths)
+
+	transport, err := newProxyTransport()
+	if err != nil {
+		return nil, err
+	}
+	httpPool := loadHTTPPoolConfig()
+	httpPool.apply(transport)
+	httpClient := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: transport,
+	}
+	baseURL := strings.TrimRight(getEnvOrDefault("MOLTBOOK_BASE_URL", defaultBaseURL), "/")
+	apiClient := httpClient
+	if getEnvBoolOrDefault("MOLTBOOK_INSECURE_SKIP_VERIFY", false) {
+		apiClient = insecureClient(httpClient)
+		log.Printf("⚠️  WARNING: MOLTBOOK_INSECURE_SKIP_VERIFY=true, TLS certificates of %s are NOT verified. Only use this against development or internal Moltbook mirrors.", baseURL)
+	}
+
+	notifiers, err := newNotifiersFromEnv(httpClient)
+	if err != nil {
+		return nil, err
+	}
+	alertRoutes, err := parseAlertRoutes(getEnvOrDefault("ALERT_ROUTES", defaultAlertRoutes))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ALERT_ROUTES: %w", err)
+	}
+	alertRoutes.logActive(notifiers)
+	notifyWorkers := getEnvIntOrDefault("NOTIFY_WORKERS", defaultNotifyWorkers)
+	if notifyWorkers < 1 {
+		return nil, fmt.Errorf("NOTIFY_WORKERS must be positive, got %d", notifyWorkers)
+	}
+	notifyQueueSize := getEnvIntOrDefault("NOTIFY_QUEUE_SIZE", defaultNotifyQueueSize)
+	if notifyQueueSize < 1 {
+		return nil, fmt.Errorf("NOTIFY_QUEUE_SIZE must be positive, got %d", notifyQueueSize)
+	}
+
+	userAgent := getEnvOrDefault("HTTP_USER_AGENT", defaultUserAgent)
+	extraHeaders, err := parseHeaderList(os.Getenv("HTTP_EXTRA_HEADERS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTTP_EXTRA_HEADERS: %w", err)
+	}
+
+	// PII detection has its own policy implications, so it is opt-in
+	var piiPatterns []piiPattern
+	if getEnvBoolOrDefault("SCAN_PII", false) {
+		piiPatterns = filterPIIPatterns(compilePIIPatterns(), keyTypes)
+	}
+	keyTypes.logActive(patterns, contextPatterns, piiPatterns)
+	declarePatternMetrics(patterns, contextPatterns, piiPatterns, keyTypes)
+
+	targets, err := parseScanTargets(os.Getenv("SCAN_TARGETS"))
+	if err != nil {
+		return nil, err
+	}
+
+	submoltFilter := newSubmoltFilter(
+		os.Getenv("INCLUDE_SUBMOLTS"),
+		os.Getenv("EXCLUDE_SUBMOLTS"),
+		getEnvBoolOrDefault("SCAN_UNKNOWN_SUBMOLT", true),
+	)
+
+	adminToken, err := getSecretEnv("ADMIN_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scanner{
+		moltbookAPIKey:  apiKeys,
+		clickhouseConn:  conn,
+		httpClient:      httpClient,
+		apiClient:       apiClient,
+		httpPool:        httpPool,
+		apiKeyPatterns:  patterns,
+		contextPatterns: contextPatterns,
+		keyTypes:        keyTypes,
+		piiPatterns:     piiPatterns,
+		baseURL:         baseURL,
+		postInterval:    postInterval,
+		pollJitter:      pollJitter,
+		commentInterval: commentInterval,
+		recentComments:  getEnvBoolOrDefault("ENABLE_RECENT_COMMENTS", true),
+		seenMessages:    seenMessages,
+		seenLoadWorkers: seenLoadWorkers,
+		databaseName:    clickhouseDB,
+		tablePrefix:     tablePrefix,
+		contentCodec:    contentCodec,
+		insertSettings:  asyncInsertSettings(),
+		writeLimiter:    newWriteLimiter(getEnvIntOrDefault("CLICKHOUSE_MAX_CONCURRENCY", defaultClickHouseMaxConcurrency)),
+		rawPayloads:     newRawPayloadBufferFromEnv(),
+		trackRevisions:  getEnvBoolOrDefault("TRACK_REVISIONS", false),
+		deadLetters:     newDeadLetterFileFromEnv(),
+		maxScanBytes:    maxScanBytes,
+		matchTimeout:    getEnvDurationOrDefault("SCAN_MATCH_TIMEOUT", 5*time.Second),
+		decodeEntities:  getEnvBoolOrDefault("DECODE_ENTITIES", false),
+		contextWindow:   contextWindow,
+		contentMode:     contentMode,
+		notifiers:       notifiers,
+		alertRoutes:     alertRoutes,
+		submoltFilter:   submoltFilter,
+		targets:         targets,
+		notifyThrottle:  newNotifyThrottle(notifyThrottleInterval),
+		notifyDedup:     notifyDedup,
+		findingDedup:    newFindingDedupFromEnv(),
+		shutdownGrace:   shutdownGrace,
+		fetchMaxRetries: max(getEnvIntOrDefault("FETCH_MAX_RETRIES", 3), 0),
+		maxRespBytes:    int64(max(getEnvIntOrDefault("MAX_RESPONSE_BYTES", defaultMaxResponseBytes), 1)),
+		commentMaxPages: max(getEnvIntOrDefault("COMMENTS_MAX_PAGES", 10), 1),
+		minComments:     max(getEnvIntOrDefault("MIN_COMMENTS_TO_FETCH", 1), 1),
+		createViews:     getEnvBoolOrDefault("CREATE_VIEWS", false),
+		maxMessageAge:   getEnvDurationOrDefault("MAX_MESSAGE_AGE", 0),
+		minConfidence:   getEnvFloatOrDefault("MIN_CONFIDENCE", 0),
+		maxSaveErrRate:  getEnvFloatOrDefault("MAX_SAVE_ERROR_RATE", 0.5),
+		health:          newScanHealth(),
+		commentBackfill: newCommentBackfill(max(getEnvIntOrDefault("COMMENT_RETRY_MAX_ATTEMPTS", 5), 1)),
+		activity:        newActivityCounters(),
+		submoltSpikes:   newSubmoltSpikesFromEnv(),
+		digestInterval:  getEnvDurationOrDefault("DIGEST_INTERVAL", 0),
+		submoltInterval: getEnvDurationOrDefault("POLL_INTERVAL_SUBMOLTS", 0),
+		submoltMeta:     make(map[string]string),
+		digestNotify:    getEnvBoolOrDefault("DIGEST_NOTIFY", false),
+		userAgent:       userAgent,
+		linkFetcher:     newLinkFetcherFromEnv(httpClient, userAgent),
+		attachments:     newAttachmentLimitsFromEnv(),
+		extraHeaders:    extraHeaders,
+		listenAddr:      os.Getenv("LISTEN_ADDR"),
+		graphQLAddr:     os.Getenv("GRAPHQL_ADDR"),
+		revealToken:     revealToken,
+		adminToken:      adminToken,
+		allowlistFile:   os.Getenv("ALLOWLIST_FILE"),
+	}
+
+	if s.allowlistFile != "" {
+		entries, err := s.ReloadAllowlist()
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Loaded %d allowlist entries from %s", entries, s.allowlistFile)
+	}
+
+	if len(notifiers) > 0 {
+		s.notifyQueue = newNotifyQueue(notifyWorkers, notifyQueueSize, s.sendToNotifiers)
+	}
+
+	return s, nil
+}
+
+// clickhouseConfig holds the connection settings shared by every
+// clickhouse.Open call
+type clickhouseConfig struct {
+	addr     string
+	user     string
+	password string
+	protocol clickhouse.Protocol
+	// compression is nil when CLICKHOUSE_COMPRESSION is none
+	compression *clickhouse.Compression
+	// dialTimeout bounds connecting to the server and readTimeout waiting
+	// for a response, so a stalled network fails writes instead of hanging
+	dialTimeout time.Duration
+	readTimeout time.Duration
+}
+
+// loadClickHouseConfig reads the ClickHouse connection settings from the
+// environment. CLICKHOUSE_PROTOCOL selects the native (default, port 9000) or
+// HTTP (port 8123) interface; obviously mismatched ports fail fast.
+func loadClickHouseConfig() (clickhouseConfig, error) {
+	var protocol clickhouse.Protocol
+	var defaultPort string
+	switch p := strings.ToLower(getEnvOrDefault("CLICKHOUSE_PROTOCOL", "native")); p {
+	case "native":
+		protocol, defaultPort = clickhouse.Native, "9000"
+	case "http":
+		protocol, defaultPort = clickhouse.HTTP, "8123"
+	default:
+		return clickhouseConfig{}, fmt.Errorf("invalid CLICKHOUSE_PROTOCOL %q: must be \"native\" or \"http\"", p)
+	}
+
+	var compression *clickhouse.Compression
+	switch c := strings.ToLower(getEnvOrDefault("CLICKHOUSE_COMPRESSION", "lz4")); c {
+	case "lz4":
+		compression = &clickhouse.Compression{Method: clickhouse.CompressionLZ4}
+	case "zstd":
+		compression = &clickhouse.Compression{Method: clickhouse.CompressionZSTD}
+	case "none":
+	default:
+		return clickhouseConfig{}, fmt.Errorf("invalid CLICKHOUSE_COMPRESSION %q: must be \"lz4\", \"zstd\" or \"none\"", c)
+	}
+
+	password, err := getSecretEnv("CLICKHOUSE_PASSWORD")
+	if err != nil {
+		return clickhouseConfig{}, err
+	}
+
+	port := getEnvOrDefault("CLICKHOUSE_PORT", defaultPort)
+	switch {
+	case protocol == clickhouse.Native && (port == "8123" || port == "8443"):
+		return clickhouseConfig{}, fmt.Errorf("CLICKHOUSE_PORT %s is an HTTP port but CLICKHOUSE_PROTOCOL is native: set CLICKHOUSE_PROTOCOL=http or use the native port (9000)", port)
+	case protocol == clickhouse.HTTP && (port == "9000" || port == "9440"):
+		return clickhouseConfig{}, fmt.Errorf("CLICKHOUSE_PORT %s is a native port but CLICKHOUSE_PROTOCOL is http: set CLICKHOUSE_PROTOCOL=native or use the HTTP port (8123)", port)
+	}
+
+	return clickhouseConfig{
+		addr:        fmt.Sprintf("%s:%s", getEnvOrDefault("CLICKHOUSE_HOST", "localhost"), port),
+		user:        getEnvOrDefault("CLICKHOUSE_USER", "default"),
+		password:    password,
+		protocol:    protocol,
+		compression: compression,
+		dialTimeout: getEnvDurationOrDefault("CLICKHOUSE_DIAL_TIMEOUT", 5*time.Second),
+		readTimeout: getEnvDurationOrDefault("CLICKHOUSE_READ_TIMEOUT", 30*time.Second),
 	}, nil
 }
 
-// compileAPIKeyPatterns returns compiled regex patterns for various API keys
-func compileAPIKeyPatterns() []*regexp.Regexp {
-	patterns := []string{
+// asyncInsertSettings returns the query settings for message and finding
+// inserts when CLICKHOUSE_ASYNC_INSERT is enabled, nil otherwise. Async
+// inserts are buffered server-side and flushed in bulk, which raises
+// throughput at the cost of durability: unless CLICKHOUSE_ASYNC_INSERT_WAIT
+// is set, an insert returns before its rows are written, so rows still in
+// the buffer are lost if the server crashes and insert errors go unreported.
+func asyncInsertSettings() clickhouse.Settings {
+	if !getEnvBoolOrDefault("CLICKHOUSE_ASYNC_INSERT", false) {
+		return nil
+	}
+	wait := 0
+	if getEnvBoolOrDefault("CLICKHOUSE_ASYNC_INSERT_WAIT", false) {
+		wait = 1
+	}
+	log.Printf("ClickHouse async inserts enabled (wait_for_async_insert=%d)", wait)
+	return clickhouse.Settings{
+		"async_insert":          1,
+		"wait_for_async_insert": wait,
+	}
+}
+
+// insertContext applies insertSettings to ctx for a message or finding insert
+func (s *Scanner) insertContext(ctx context.Context) context.Context {
+	if s.insertSettings == nil {
+		return ctx
+	}
+	return clickhouse.Context(ctx, clickhouse.WithSettings(s.insertSettings))
+}
+
+// options builds driver options for the given database ("" for none)
+func (c clickhouseConfig) options(database string) *clickhouse.Options {
+	return &clickhouse.Options{
+		Protocol: c.protocol,
+		Addr:     []string{c.addr},
+		Auth: clickhouse.Auth{
+			Database: database,
+			Username: c.user,
+			Password: c.password,
+		},
+		Settings: clickhouse.Settings{
+			"max_execution_time": 60,
+		},
+		Compression: c.compression,
+		DialTimeout: c.dialTimeout,
+		ReadTimeout: c.readTimeout,
+	}
+}
+
+// keyPattern is an API key pattern and the key type it detects, used to
+// enable or disable patterns by type. Matches are reported as keyType,
+// except that Generic matches are reported as the type getAPIKeyType gives
+// them when it recognises the key.
+type keyPattern struct {
+	re      *regexp.Regexp
+	keyType string
+	name    string       // stable label for metrics, see patternName
+	lengths lengthBounds // matches outside these are discarded
+	// keyGroup is the index of the pattern's "key" group, which is the key
+	// when the pattern also matches the text around it, or 0 for the whole
+	// match
+	keyGroup int
+}
+
+// compileAPIKeyPatterns returns compiled regex patterns for various API keys.
+// Patterns are matched case-insensitively, with normalizeKey restoring the
+// canonical casing, unless they start with (?-i): formats whose only
+// distinguishing feature is a case-sensitive prefix opt out that way.
+// Go regexps have no lookarounds, so a pattern that must not match inside
+// a longer run matches its boundaries too and captures the key as (?P<key>).
+func compileAPIKeyPatterns() []keyPattern {
+	patterns := []struct{ keyType, pattern string }{
 		// OpenAI
-		`sk-[a-zA-Z0-9]{20,}`,
-		`sk-proj-[a-zA-Z0-9_-]{20,}`,
+		{"OpenAI", `sk-[a-zA-Z0-9]{20,}`},
+		{"OpenAI", `sk-proj-[a-zA-Z0-9_-]{20,}`},
 		// Anthropic
-		`sk-ant-[a-zA-Z0-9_-]{20,}`,
-		// Google/GCP
-		`AIza[0-9A-Za-z_-]{35}`,
+		{"Anthropic", `sk-ant-[a-zA-Z0-9_-]{20,}`},
+		// Google/GCP and Firebase
+		{"Google", `AIza[0-9A-Za-z_-]{35}`},
+		{"GoogleOAuthSecret", `GOCSPX-[A-Za-z0-9_-]{28}`},
+		{"FirebaseCloudMessaging", `AAAA[A-Za-z0-9_-]{7}:[A-Za-z0-9_-]{140,}`},
 		// AWS
-		`AKIA[0-9A-Z]{16}`,
-		`ASIA[0-9A-Z]{16}`,
+		{"AWS", `AKIA[0-9A-Z]{16}`},
+		{"AWS", `ASIA[0-9A-Z]{16}`},
 		// GitHub
-		`ghp_[a-zA-Z0-9]{36}`,
-		`gho_[a-zA-Z0-9]{36}`,
-		`ghu_[a-zA-Z0-9]{36}`,
-		`ghs_[a-zA-Z0-9]{36}`,
-		`ghr_[a-zA-Z0-9]{36}`,
-		`github_pat_[a-zA-Z0-9]{22}_[a-zA-Z0-9]{59}`,
+		{"GitHub", `ghp_[a-zA-Z0-9]{36}`},
+		{"GitHub", `gho_[a-zA-Z0-9]{36}`},
+		{"GitHub", `ghu_[a-zA-Z0-9]{36}`},
+		{"GitHub", `ghs_[a-zA-Z0-9]{36}`},
+		{"GitHub", `ghr_[a-zA-Z0-9]{36}`},
+		{"GitHub", `github_pat_[a-zA-Z0-9]{22}_[a-zA-Z0-9]{59}`},
 		// Stripe
-		`sk_live_[0-9a-zA-Z]{24,}`,
-		`sk_test_[0-9a-zA-Z]{24,}`,
-		`rk_live_[0-9a-zA-Z]{24,}`,
-		`rk_test_[0-9a-zA-Z]{24,}`,
-		// Twilio
-		`SK[0-9a-fA-F]{32}`,
+		{"Stripe", `sk_live_[0-9a-zA-Z]{24,}`},
+		{"Stripe", `sk_test_[0-9a-zA-Z]{24,}`},
+		{"Stripe", `rk_live_[0-9a-zA-Z]{24,}`},
+		{"Stripe", `rk_test_[0-9a-zA-Z]{24,}`},
+		// Twilio API key SIDs, case-sensitive: ignoring case, "sk" plus 32
+		// hex digits also matches ordinary hex strings
+		{"Twilio", `(?-i)SK[0-9a-fA-F]{32}`},
 		// SendGrid
-		`SG\.[a-zA-Z0-9_-]{22}\.[a-zA-Z0-9_-]{43}`,
+		{"SendGrid", `SG\.[a-zA-Z0-9_-]{22}\.[a-zA-Z0-9_-]{43}`},
 		// Slack
-		`xoxb-[0-9]{10,13}-[0-9]{10,13}-[a-zA-Z0-9]{24}`,
-		`xoxp-[0-9]{10,13}-[0-9]{10,13}-[a-zA-Z0-9]{24}`,
-		`xoxa-[0-9]{10,13}-[0-9]{10,13}-[a-zA-Z0-9]{24}`,
+		{"Slack", `xoxb-[0-9]{10,13}-[0-9]{10,13}-[a-zA-Z0-9]{24}`},
+		{"Slack", `xoxp-[0-9]{10,13}-[0-9]{10,13}-[a-zA-Z0-9]{24}`},
+		{"Slack", `xoxa-[0-9]{10,13}-[0-9]{10,13}-[a-zA-Z0-9]{24}`},
 		// Discord
-		`[MN][A-Za-z\d]{23,}\.[\w-]{6}\.[\w-]{27}`,
+		{"Discord", `[MN][A-Za-z\d]{23,}\.[\w-]{6}\.[\w-]{27}`},
 		// Telegram
-		`[0-9]{8,10}:[a-zA-Z0-9_-]{35}`,
+		{"Telegram", `[0-9]{8,10}:[a-zA-Z0-9_-]{35}`},
 		// Supabase
-		`sbp_[a-zA-Z0-9]{40,}`,
-		`eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9\.[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]+`,
+		{"Supabase", `sbp_[a-zA-Z0-9]{40,}`},
+		{"Supabase", `eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9\.[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]+`},
 		// Moltbook
-		`moltbook_sk_[a-zA-Z0-9_-]{20,}`,
+		{"Moltbook", `moltbook_sk_[a-zA-Z0-9_-]{20,}`},
+		// Azure
+		// Storage account keys are 64 random bytes; without the boundaries
+		// any 88-byte window of a longer base64 blob (images, certificates)
+		// would match
+		{"AzureStorageKey", `(?:^|[^A-Za-z0-9+/_-])(?P<key>[A-Za-z0-9+/]{86}==)(?:[^A-Za-z0-9+/=_-]|$)`},
+		{"AzureSAS", `sv=[0-9]{4}-[0-9]{2}-[0-9]{2}&[^\s"'<>]*?sig=[A-Za-z0-9%+/=]{20,}`},
+		// DigitalOcean
+		{"DigitalOceanPAT", `dop_v1_[a-f0-9]{64}`},
+		{"DigitalOceanOAuth", `do[or]_v1_[a-f0-9]{64}`},
+		// Package registries
+		{"NPM", `npm_[A-Za-z0-9]{36}`},
+		{"PyPI", `pypi-AgEIcHlwaS5vcmc[A-Za-z0-9_-]{50,}`},
+		{"DockerHub", `dckr_pat_[A-Za-z0-9_-]{27,}`},
+		// Shopify
+		{"ShopifyAccessToken", `shpat_[a-fA-F0-9]{32}`},
+		{"ShopifyCustomAppToken", `shpca_[a-fA-F0-9]{32}`},
+		{"ShopifyPrivateAppToken", `shppa_[a-fA-F0-9]{32}`},
+		{"ShopifySharedSecret", `shpss_[a-fA-F0-9]{32}`},
+		// Square
+		{"SquareAccessToken", `sq0atp-[A-Za-z0-9_-]{22}`},
+		{"SquareOAuthSecret", `sq0csp-[A-Za-z0-9_-]{43}`},
+		{"SquareAccessToken", `EAAA[A-Za-z0-9]{60}`},
+		// Productivity tools
+		{"AtlassianAPIToken", `ATATT3[A-Za-z0-9_=-]{150,}`},
+		{"LinearAPIKey", `lin_api_[A-Za-z0-9]{40,}`},
+		{"NotionToken", `secret_[A-Za-z0-9]{43}`},
+		{"NotionToken", `ntn_[A-Za-z0-9]{46}`},
 		// Generic API key patterns
-		`api[_-]?key[_-]?[=:]["']?[a-zA-Z0-9_-]{20,}["']?`,
-		`apikey[=:]["']?[a-zA-Z0-9_-]{20,}["']?`,
-		`secret[_-]?key[_-]?[=:]["']?[a-zA-Z0-9_-]{20,}["']?`,
-		`access[_-]?token[=:]["']?[a-zA-Z0-9_-]{20,}["']?`,
-		`bearer\s+[a-zA-Z0-9_-]{20,}`,
+		{"Generic", `api[_-]?key[_-]?[=:]["']?[a-zA-Z0-9_-]{20,}["']?`},
+		{"Generic", `apikey[=:]["']?[a-zA-Z0-9_-]{20,}["']?`},
+		{"Generic", `secret[_-]?key[_-]?[=:]["']?[a-zA-Z0-9_-]{20,}["']?`},
+		{"Generic", `access[_-]?token[=:]["']?[a-zA-Z0-9_-]{20,}["']?`},
+		{"Generic", `bearer\s+[a-zA-Z0-9_-]{20,}`},
+		// Mailgun
+		{"Mailgun", `key-[0-9a-f]{32}`},
 		// Private keys (partial match)
-		`-----BEGIN\s+(RSA\s+)?PRIVATE\s+KEY-----`,
-		`-----BEGIN\s+OPENSSH\s+PRIVATE\s+KEY-----`,
+		{"PrivateKey", `-----BEGIN\s+(RSA\s+)?PRIVATE\s+KEY-----`},
+		{"PrivateKey", `-----BEGIN\s+OPENSSH\s+PRIVATE\s+KEY-----`},
 	}
 
-	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	compiled := make([]keyPattern, 0, len(patterns))
+	names := make(map[string]int)
 	for _, p := range patterns {
-		re, err := regexp.Compile(`(?i)` + p)
+		re, err := regexp.Compile(`(?i)` + p.pattern)
 		if err != nil {
-			log.Printf("Warning: failed to compile pattern %s: %v", p, err)
+			log.Printf("Warning: failed to compile pattern %s: %v", p.pattern, err)
 			continue
 		}
-		compiled = append(compiled, re)
+		compiled = append(compiled, keyPattern{
+			re:       re,
+			keyType:  p.keyType,
+			name:     patternName(p.keyType, p.pattern, names),
+			keyGroup: max(re.SubexpIndex("key"), 0),
+		})
 	}
 
 	return compiled
 }
 
-// getAPIKeyType returns a human-readable type for the matched API key
+// patternName names a pattern by its key type and literal prefix, e.g.
+// "GitHub:ghp_", so the name survives patterns being added or reordered.
+// Patterns without a usable prefix, or sharing one, are numbered within
+// names, which counts the names handed out so far.
+func patternName(keyType, pattern string, names map[string]int) string {
+	name := keyType
+	if re, err := regexp.Compile(pattern); err == nil {
+		if prefix, _ := re.LiteralPrefix(); prefix != "" {
+			name += ":" + truncateToRuneBoundary(prefix, 12)
+		}
+	}
+	names[name]++
+	if n := names[name]; n > 1 {
+		name += "#" + strconv.Itoa(n)
+	}
+	return name
+}
+
+// contextPattern matches a key shape that is too generic on its own (UUIDs,
+// hex strings) and only counts when one of its keywords appears nearby
+type contextPattern struct {
+	re       *regexp.Regexp
+	keywords *regexp.Regexp
+	keyType  string
+	lengths  lengthBounds
+}
+
+// contextWindowBytes is how far around a match keywords are looked for
+const contextWindowBytes = 100
+
+// compileContextPatterns returns the keyword-gated patterns
+func compileContextPatterns() []contextPattern {
+	return []contextPattern{
+		{
+			re:       regexp.MustCompile(`(?i)[0-9a-f]{32}-us[0-9]{1,2}`),
+			keywords: regexp.MustCompile(`(?i)mailchimp|mc_?api|chimp`),
+			keyType:  "Mailchimp",
+		},
+		{
+			re:       regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`),
+			keywords: regexp.MustCompile(`(?i)postmark`),
+			keyType:  "Postmark",
+		},
+		{
+			re:       regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`),
+			keywords: regexp.MustCompile(`(?i)heroku`),
+			keyType:  "Heroku",
+		},
+		{
+			// The Global API key is always used together with the account email
+			re:       regexp.MustCompile(`\b[0-9a-f]{37}\b`),
+			keywords: regexp.MustCompile(`(?i)x-auth-email|cf_api_email|cloudflare_email|(?:cloudflare|\bcf_)[^\n]{0,60}[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}`),
+			keyType:  "CloudflareGlobalKey",
+		},
+		{
+			re:       regexp.MustCompile(`\b[A-Za-z0-9_-]{40}\b`),
+			keywords: regexp.MustCompile(`(?i)cloudflare|\bcf_(?:api_)?token`),
+			keyType:  "CloudflareAPIToken",
+		},
+		{
+			re:       regexp.MustCompile(`\b[A-Za-z0-9_-]{32}\b`),
+			keywords: regexp.MustCompile(`(?i)fastly`),
+			keyType:  "Fastly",
+		},
+	}
+}
+
+// hasNearbyKeyword reports whether keywords matches within
+// contextWindowBytes of text[start:end]
+func hasNearbyKeyword(text string, start, end int, keywords *regexp.Regexp) bool {
+	from := max(start-contextWindowBytes, 0)
+	to := min(end+contextWindowBytes, len(text))
+	return keywords.MatchString(text[from:to])
+}
+
+// firebaseKeywords mark a Google API key as part of a Firebase web config
+var firebaseKeywords = regexp.MustCompile(`(?i)firebase|authDomain|messagingSenderId|appspot\.com`)
+
+// contextualKeyType refines keyType using the text around the match at
+// text[start:end]. Google API keys in a Firebase config are Firebase web
+// keys, which are meant to ship in client apps and matter much less.
+func contextualKeyType(keyType, text string, start, end int) string {
+	if keyType == "Google" && hasNearbyKeyword(text, start, end, firebaseKeywords) {
+		return "FirebaseWebKey"
+	}
+	return keyType
+}
+
+// getAPIKeyType guesses the type of a key from its prefix and shape. Keys
+// matched by a typed pattern take that pattern's type; this is only the
+// fallback for Generic matches.
 func getAPIKeyType(key string) string {
 	key = strings.ToLower(key)
 	switch {
+	case len(key) == 88 && strings.HasSuffix(key, "=="):
+		return "AzureStorageKey"
+	case strings.HasPrefix(key, "sv=") && strings.Contains(key, "sig="):
+		return "AzureSAS"
+	case strings.HasPrefix(key, "dop_v1_"):
+		return "DigitalOceanPAT"
+	case strings.HasPrefix(key, "doo_v1_"), strings.HasPrefix(key, "dor_v1_"):
+		return "DigitalOceanOAuth"
+	case strings.HasPrefix(key, "npm_"):
+		return "NPM"
+	case strings.HasPrefix(key, "pypi-"):
+		return "PyPI"
+	case strings.HasPrefix(key, "dckr_pat_"):
+		return "DockerHub"
+	case strings.HasPrefix(key, "shpat_"):
+		return "ShopifyAccessToken"
+	case strings.HasPrefix(key, "shpca_"):
+		return "ShopifyCustomAppToken"
+	case strings.HasPrefix(key, "shppa_"):
+		return "ShopifyPrivateAppToken"
+	case strings.HasPrefix(key, "shpss_"):
+		return "ShopifySharedSecret"
+	case strings.HasPrefix(key, "sq0atp-"), strings.HasPrefix(key, "eaaa") && len(key) == 64:
+		return "SquareAccessToken"
+	case strings.HasPrefix(key, "sq0csp-"):
+		return "SquareOAuthSecret"
+	case strings.HasPrefix(key, "atatt3"):
+		return "AtlassianAPIToken"
+	case strings.HasPrefix(key, "lin_api_"):
+		return "LinearAPIKey"
+	case strings.HasPrefix(key, "secret_") && len(key) == 50 && !strings.ContainsAny(key, "=:"), strings.HasPrefix(key, "ntn_"):
+		return "NotionToken"
 	case strings.HasPrefix(key, "sk-ant-"):
 		return "Anthropic"
 	case strings.HasPrefix(key, "sk-proj-"), strings.HasPrefix(key, "sk-"):
 		return "OpenAI"
 	case strings.HasPrefix(key, "aiza"):
 		return "Google"
+	case strings.HasPrefix(key, "gocspx-"):
+		return "GoogleOAuthSecret"
+	case strings.HasPrefix(key, "aaaa") && strings.Contains(key, ":") && len(key) > 150:
+		return "FirebaseCloudMessaging"
 	case strings.HasPrefix(key, "akia"), strings.HasPrefix(key, "asia"):
 		return "AWS"
 	case strings.HasPrefix(key, "ghp_"), strings.HasPrefix(key, "gho_"), strings.HasPrefix(key, "ghu_"), strings.HasPrefix(key, "ghs_"), strings.HasPrefix(key, "ghr_"), strings.HasPrefix(key, "github_pat_"):
@@ -293,6 +925,8 @@ func getAPIKeyType(key string) string {
 		return "SendGrid"
 	case strings.HasPrefix(key, "xoxb-"), strings.HasPrefix(key, "xoxp-"), strings.HasPrefix(key, "xoxa-"):
 		return "Slack"
+	case strings.HasPrefix(key, "key-"):
+		return "Mailgun"
 	case strings.HasPrefix(key, "sbp_"):
 		return "Supabase"
 	case strings.HasPrefix(key, "moltbook_sk_"):
@@ -306,15 +940,98 @@ func getAPIKeyType(key string) string {
 	}
 }
 
+// Finding severities, from most to least urgent
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+)
+
+// severityEnum is the ClickHouse type severities are compared as, ordered so
+// max() picks the most urgent
+const severityEnum = "Enum8('low' = 1, 'medium' = 2, 'high' = 3, 'critical' = 4)"
+
+// getSeverity rates how damaging a leaked key of the given type is
+func getSeverity(keyType string) string {
+	switch keyType {
+	case "AWS", "AWSKeyPair", "PrivateKey", "GitHub", "Stripe", "OpenAI", "Anthropic", "AzureStorageKey", "DigitalOceanPAT",
+		"Heroku", "CloudflareGlobalKey":
+		return SeverityCritical
+	case "Google", "Slack", "SendGrid", "Supabase", "AzureSAS", "DigitalOceanOAuth", "NPM", "PyPI", "DockerHub",
+		"Mailgun", "Mailchimp", "Postmark", "GoogleOAuthSecret", "FirebaseCloudMessaging", "CloudflareAPIToken", "Fastly", "DatabaseConnectionString", "AuthorizationHeader",
+		"ShopifyAccessToken", "ShopifyCustomAppToken", "ShopifyPrivateAppToken", "ShopifySharedSecret", "SquareAccessToken", "SquareOAuthSecret":
+		return SeverityHigh
+	case "Moltbook", "Generic", "AtlassianAPIToken", "LinearAPIKey", "NotionToken":
+		return SeverityMedium
+	case "FirebaseWebKey", PIITypeEmail, PIITypePhone:
+		return SeverityLow
+	default:
+		return SeverityLow
+	}
+}
+
+// getConfidence estimates how likely a match of the given type is a real
+// credential. Prefixed formats rarely match anything else; formats that are
+// only recognised by nearby keywords, and the catch-all patterns, often do.
+func getConfidence(keyType string) float64 {
+	switch keyType {
+	case "Generic", "Unknown":
+		return 0.3
+	case "Mailchimp", "Postmark", "Heroku", "CloudflareGlobalKey", "CloudflareAPIToken", "Fastly", "FirebaseWebKey":
+		return 0.6
+	default:
+		return 0.9
+	}
+}
+
+// identifierPattern matches the database names and table prefixes accepted
+// from the environment
+var identifierPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// quoteIdent quotes a ClickHouse identifier for interpolation into a query.
+// Identifiers from the environment are validated against identifierPattern
+// in NewScanner; escaping keeps the quoting sound for any other caller.
+func quoteIdent(name string) string {
+	return "`" + identEscaper.Replace(name) + "`"
+}
+
+var identEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+
+// table returns the quoted, qualified name of one of the scanner's tables,
+// with TABLE_PREFIX applied
+func (s *Scanner) table(name string) string {
+	return quoteIdent(s.databaseName) + "." + quoteIdent(s.tablePrefix+name)
+}
+
+// contentCodecPattern matches the MESSAGE_CONTENT_CODEC values accepted,
+// which are spliced into the schema statements
+var contentCodecPattern = regexp.MustCompile(`(?i)^((ZSTD|LZ4HC)(\([0-9]{1,2}\))?|LZ4|NONE)$`)
+
+// parseContentCodec turns MESSAGE_CONTENT_CODEC, a ClickHouse codec such as
+// ZSTD(3), into the CODEC clause of the messages content column. "" keeps
+// the server's default compression (LZ4).
+func parseContentCodec(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	if !contentCodecPattern.MatchString(raw) {
+		return "", fmt.Errorf("invalid MESSAGE_CONTENT_CODEC %q: expected ZSTD, ZSTD(level), LZ4HC(level), LZ4 or NONE", raw)
+	}
+	return fmt.Sprintf(" CODEC(%s)", strings.ToUpper(raw)), nil
+}
+
 // InitDatabase creates the necessary tables in ClickHouse
 func (s *Scanner) InitDatabase(ctx context.Context) error {
 	db := s.databaseName
+	findingsTable := s.table("api_key_findings")
 
 	queries := []string{
 		// Ensure database exists (redundant but safe)
-		fmt.Sprintf(`CREATE DATABASE IF NOT EXISTS %s`, db),
+		fmt.Sprintf(`CREATE DATABASE IF NOT EXISTS %s`, quoteIdent(db)),
 		// API key findings table
-		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s.api_key_findings (
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
 			id UUID DEFAULT generateUUIDv4(),
 			post_id String,
 			post_title String,
@@ -326,17 +1043,49 @@ func (s *Scanner) InitDatabase(ctx context.Context) error {
 			post_url String,
 			found_at DateTime64(3),
 			post_created_at DateTime64(3),
-			created_at DateTime64(3) DEFAULT now64(3)
+			created_at DateTime64(3) DEFAULT now64(3),
+			detection_latency_ms UInt64,
+			encoding LowCardinality(String),
+			key_sha256 String,
+			source_url String,
+			origin LowCardinality(String),
+			severity LowCardinality(String),
+			cycle_id String,
+			location LowCardinality(String),
+			message_id String,
+			occurrence_count UInt64 DEFAULT 1,
+			last_seen DateTime64(3) DEFAULT found_at
 		) ENGINE = MergeTree()
-		ORDER BY (found_at, post_id)`, db),
+		ORDER BY (found_at, post_id)`, findingsTable),
+		// Columns added after the initial schema, for existing deployments
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS detection_latency_ms UInt64`, findingsTable),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS encoding LowCardinality(String)`, findingsTable),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS key_sha256 String`, findingsTable),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS source_url String`, findingsTable),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS origin LowCardinality(String)`, findingsTable),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS severity LowCardinality(String)`, findingsTable),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS cycle_id String`, findingsTable),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS location LowCardinality(String)`, findingsTable),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS occurrence_count UInt64 DEFAULT 1`, findingsTable),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS last_seen DateTime64(3) DEFAULT found_at`, findingsTable),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS message_id String`, findingsTable),
+		// Findings below MIN_CONFIDENCE, held for review until promoted. The
+		// table is cloned from the findings table as it exists now, so new
+		// findings columns need an ALTER for it too.
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s AS %s`, s.table("findings_quarantine"), findingsTable),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS confidence Float32`, s.table("findings_quarantine")),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS location LowCardinality(String)`, s.table("findings_quarantine")),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS occurrence_count UInt64 DEFAULT 1`, s.table("findings_quarantine")),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS last_seen DateTime64(3) DEFAULT found_at`, s.table("findings_quarantine")),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS message_id String`, s.table("findings_quarantine")),
 		// Messages table - stores all scanned posts and comments
-		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s.messages (
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
 			id String,
 			message_type LowCardinality(String),
 			post_id String,
 			parent_id String,
 			title String,
-			content String,
+			content String%s,
 			author_id String,
 			author_name String,
 			submolt_id String,
@@ -348,9 +1097,80 @@ func (s *Scanner) InitDatabase(ctx context.Context) error {
 			created_at DateTime64(3),
 			scanned_at DateTime64(3) DEFAULT now64(3),
 			has_api_key UInt8,
-			api_key_types Array(String)
+			api_key_types Array(String),
+			cycle_id String,
+			content_sha256 String
 		) ENGINE = MergeTree()
-		ORDER BY (scanned_at, message_type, id)`, db),
+		ORDER BY (scanned_at, message_type, id)`, s.table("messages"), s.contentCodec),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS cycle_id String`, s.table("messages")),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS content_sha256 String`, s.table("messages")),
+		// Key fingerprints already notified, so restarts don't re-alert
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			key_sha256 String,
+			notified_at DateTime64(3)
+		) ENGINE = ReplacingMergeTree(notified_at)
+		ORDER BY key_sha256`, s.table("notified_fingerprints")),
+	}
+	if s.contentCodec != "" {
+		// Existing parts keep their compression until they are merged
+		queries = append(queries, fmt.Sprintf(`ALTER TABLE %s MODIFY COLUMN content String%s`, s.table("messages"), s.contentCodec))
+	}
+	if s.rawPayloads != nil {
+		// Original API JSON per message, only created when STORE_RAW_PAYLOAD is set
+		queries = append(queries, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			id String,
+			message_type LowCardinality(String),
+			payload String,
+			fetched_at DateTime64(3)
+		) ENGINE = ReplacingMergeTree(fetched_at)
+		ORDER BY id`, s.table("raw_payloads")))
+	}
+	if s.trackRevisions {
+		// Earlier versions of edited messages, only created when TRACK_REVISIONS is set
+		queries = append(queries, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			id String,
+			revision String,
+			content String%s,
+			observed_at DateTime64(3)
+		) ENGINE = ReplacingMergeTree()
+		ORDER BY (id, observed_at, revision)`, s.table("message_revisions"), s.contentCodec))
+	}
+	if s.findingDedup != nil {
+		// One row per repeat sighting of a deduplicated finding, added to
+		// its occurrence_count and last_seen when read
+		queries = append(queries, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			key_sha256 String,
+			found_at DateTime64(3),
+			occurrences SimpleAggregateFunction(sum, UInt64),
+			last_seen SimpleAggregateFunction(max, DateTime64(3))
+		) ENGINE = AggregatingMergeTree()
+		ORDER BY (key_sha256, found_at)`, s.table("finding_occurrences")))
+	}
+	if s.createViews {
+		// One row per distinct key, kept up to date by a materialized view
+		// on findings inserts. Rows are merged in the background, so reads
+		// still aggregate by key_sha256 (or use FINAL).
+		queries = append(queries,
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			key_sha256 String,
+			api_key_type SimpleAggregateFunction(any, String),
+			first_seen SimpleAggregateFunction(min, DateTime64(3)),
+			last_seen SimpleAggregateFunction(max, DateTime64(3)),
+			occurrence_count SimpleAggregateFunction(sum, UInt64),
+			highest_severity SimpleAggregateFunction(max, %s)
+		) ENGINE = AggregatingMergeTree()
+		ORDER BY key_sha256`, s.table("unique_keys"), severityEnum),
+			fmt.Sprintf(`CREATE MATERIALIZED VIEW IF NOT EXISTS %s TO %s AS
+		SELECT
+			key_sha256,
+			any(api_key_type) AS api_key_type,
+			min(found_at) AS first_seen,
+			max(found_at) AS last_seen,
+			count() AS occurrence_count,
+			max(CAST(if(severity = '', 'low', severity) AS %s)) AS highest_severity
+		FROM %s
+		GROUP BY key_sha256`, s.table("unique_keys_mv"), s.table("unique_keys"), severityEnum, findingsTable),
+		)
 	}
 
 	for _, query := range queries {
@@ -359,59 +1179,319 @@ func (s *Scanner) InitDatabase(ctx context.Context) error {
 		}
 	}
 
-	log.Printf("Database '%s' initialized successfully (2 tables ready)", db)
+	if err := s.ValidateSchema(ctx); err != nil {
+		return err
+	}
+
+	log.Printf("Database '%s' initialized successfully (%d tables ready)", db, len(s.expectedSchema()))
 	return nil
 }
 
-// LoadSeenMessages loads previously scanned message IDs from the database
+// messageHashExpr is the content hash of a messages row: the stored
+// content_sha256, or for rows saved before it existed the hash contentHash
+// computes, in SQL
+const messageHashExpr = `if(content_sha256 = '', lower(hex(SHA256(concat(title, '\0', content)))), content_sha256)`
+
+// LoadSeenMessages loads previously scanned message IDs from the database.
+// With SEEN_LOAD_CONCURRENCY above 1 the messages table is read in that
+// many partitions by a hash of id, each by its own query.
 func (s *Scanner) LoadSeenMessages(ctx context.Context) error {
-	db := s.databaseName
+	start := time.Now()
+	workers := max(s.seenLoadWorkers, 1)
+
+	var (
+		wg     sync.WaitGroup
+		total  atomic.Int64
+		errMu  sync.Mutex
+		errOut error
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(partition int) {
+			defer wg.Done()
+			n, err := s.loadSeenPartition(ctx, partition, workers)
+			total.Add(int64(n))
+			if err != nil {
+				errMu.Lock()
+				if errOut == nil {
+					errOut = err
+				}
+				errMu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if errOut != nil {
+		return errOut
+	}
+
+	s.seenMessages.recordMetrics()
+	log.Printf("Loaded %d previously scanned messages in %s (%d workers)",
+		total.Load(), time.Since(start).Round(time.Millisecond), workers)
+	return nil
+}
 
-	// Load from messages table
-	rows, err := s.clickhouseConn.Query(ctx, fmt.Sprintf(`SELECT id FROM %s.messages`, db))
+// loadSeenPartition adds the messages whose id hashes to partition of
+// partitions to the seen set and returns how many IDs it loaded. Each ID
+// hashes to a single partition, so the counts of different partitions add
+// up.
+func (s *Scanner) loadSeenPartition(ctx context.Context, partition, partitions int) (int, error) {
+	// Load from messages table, with the content hash of the latest stored
+	// version of each ID (see contentHash) so edits made while stopped are
+	// detected. Older versions are left out: the seen set keeps one hash
+	// per ID, and loading them in no particular order could leave an
+	// edited message with a stale hash that reports it edited again. The
+	// stored hash is used rather than hashing the row, as rows replayed
+	// from DLQ_FILE hold masked text.
+	where := ""
+	var args []any
+	if partitions > 1 {
+		where = `WHERE cityHash64(id) % ? = ?`
+		args = append(args, uint64(partitions), uint64(partition))
+	}
+	query := fmt.Sprintf(`SELECT id, argMax(%s, scanned_at)
+		FROM %s
+		%s
+		GROUP BY id`, messageHashExpr, s.table("messages"), where)
+	rows, err := s.clickhouseConn.Query(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("failed to query messages: %w", err)
+		return 0, fmt.Errorf("failed to query messages: %w", err)
 	}
 	defer rows.Close()
 
+	loaded := 0
 	for rows.Next() {
-		var id string
-		if err := rows.Scan(&id); err != nil {
-			return fmt.Errorf("failed to scan message ID: %w", err)
+		var id, hash string
+		if err := rows.Scan(&id, &hash); err != nil {
+			return loaded, fmt.Errorf("failed to scan message ID: %w", err)
 		}
-		s.seenMessages[id] = true
+		s.seenMessages.TryAddVersion(id, hash)
+		loaded++
+	}
+	if err := rows.Err(); err != nil {
+		return loaded, fmt.Errorf("failed to read messages: %w", err)
 	}
+	return loaded, nil
+}
 
-	log.Printf("Loaded %d previously scanned messages", len(s.seenMessages))
-	return nil
+// APIStatusError is returned when the Moltbook API answers with a non-200 status
+type APIStatusError struct {
+	StatusCode int
+	Body       string
 }
 
-// FetchFeed fetches posts from the Moltbook API
-func (s *Scanner) FetchFeed(ctx context.Context, sort string, limit int) ([]MoltbookPost, error) {
-	url := fmt.Sprintf("%s/posts?sort=%s&limit=%d", s.baseURL, sort, limit)
+func (e *APIStatusError) Error() string {
+	return fmt.Sprintf("API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// retryable reports whether the request may succeed if sent again
+func (e *APIStatusError) retryable() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// defaultBaseURL is the Moltbook API, unless MOLTBOOK_BASE_URL points to a
+// mirror or stub server
+const defaultBaseURL = "https://www.moltbook.com/api/v1"
+
+// defaultUserAgent identifies the scanner to Moltbook
+const defaultUserAgent = "moltbook-scanner/1.0 (+https://github.com/mathieubellon/moltbook-scanner)"
+
+// retryBaseDelay is the backoff before the first retry; it doubles each attempt
+const retryBaseDelay = 500 * time.Millisecond
+
+// doRequest performs an authenticated GET against the Moltbook API and
+// decodes the JSON response into out. Network errors and 502/503/504
+// responses are retried up to fetchMaxRetries times with exponential backoff
+// plus jitter; 429 responses are retried after their Retry-After delay.
+// A 401 or 403 reloads the API key and, if it was rotated, retries with the
+// new one. Other 4xx responses fail immediately.
+func (s *Scanner) doRequest(ctx context.Context, url string, out interface{}) error {
+	return s.doConditionalRequest(ctx, url, nil, out)
+}
+
+// doConditionalRequest is doRequest sending the validators cache holds for
+// url, and storing those of the response. It returns errNotModified if the
+// API answers 304. A nil cache makes the request unconditional.
+func (s *Scanner) doConditionalRequest(ctx context.Context, url string, cache *validatorCache, out interface{}) error {
+	key := s.moltbookAPIKey.get()
+	err := s.doRequestWithRetries(ctx, url, key, cache, out)
+
+	var statusErr *APIStatusError
+	if errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusUnauthorized || statusErr.StatusCode == http.StatusForbidden) {
+		if s.moltbookAPIKey.rotate(key) {
+			return s.doRequestWithRetries(ctx, url, s.moltbookAPIKey.get(), cache, out)
+		}
+	}
+	return err
+}
+
+// doRequestWithRetries is doConditionalRequest with a fixed API key
+func (s *Scanner) doRequestWithRetries(ctx context.Context, url, key string, cache *validatorCache, out interface{}) error {
+	var lastErr error
+	attempts := 0
+
+	for attempt := 0; attempt <= s.fetchMaxRetries; attempt++ {
+		attempts++
+		retryAfter, err := s.doRequestOnce(ctx, url, key, cache, out)
+		if err == nil || errors.Is(err, errNotModified) {
+			return err
+		}
+		lastErr = err
+
+		var statusErr *APIStatusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() {
+			break
+		}
+		if errors.Is(err, errResponseTooLarge) {
+			// The same request will return the same oversized body
+			break
+		}
+		if ctx.Err() != nil || attempt == s.fetchMaxRetries {
+			break
+		}
+
+		delay := retryAfter
+		if delay <= 0 {
+			backoff := retryBaseDelay << attempt
+			delay = backoff + time.Duration(rand.Int63n(int64(backoff)))
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			break
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("after %d attempts: %w", attempts, lastErr)
+		case <-timer.C:
+		}
+	}
+
+	return fmt.Errorf("after %d attempts: %w", attempts, lastErr)
+}
 
+// doRequestOnce performs a single attempt of doConditionalRequest. For 429
+// responses it also returns the server's requested Retry-After delay.
+func (s *Scanner) doRequestOnce(ctx context.Context, url, key string, cache *validatorCache, out interface{}) (time.Duration, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return 0, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+s.moltbookAPIKey)
+	for name, values := range s.extraHeaders {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	req.Header.Set("User-Agent", s.userAgent)
+	req.Header.Set("Authorization", "Bearer "+key)
 	req.Header.Set("Content-Type", "application/json")
+	if cache != nil {
+		cache.apply(url, req.Header)
+	}
 
-	resp, err := s.httpClient.Do(req)
+	resp, err := s.apiClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch feed: %w", err)
+		return 0, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && cache != nil {
+		return 0, errNotModified
+	}
+
+	body := &limitedBody{r: resp.Body, remaining: s.maxRespBytes}
+
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+		body, _ := io.ReadAll(body)
+		var retryAfter time.Duration
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				retryAfter = time.Duration(secs) * time.Second
+			}
+		}
+		return retryAfter, &APIStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	if err := json.NewDecoder(body).Decode(out); err != nil {
+		if errors.Is(err, errResponseTooLarge) {
+			return 0, fmt.Errorf("%w: more than %d bytes (MAX_RESPONSE_BYTES)", errResponseTooLarge, s.maxRespBytes)
+		}
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if cache != nil {
+		cache.store(url, resp.Header)
+	}
+
+	return 0, nil
+}
+
+// defaultMaxResponseBytes caps API responses at 16MB unless
+// MAX_RESPONSE_BYTES says otherwise
+const defaultMaxResponseBytes = 16 << 20
+
+var errResponseTooLarge = errors.New("response body too large")
+
+// limitedBody is io.LimitReader that fails with errResponseTooLarge instead
+// of a clean EOF, so a cut-off body isn't mistaken for malformed JSON
+type limitedBody struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// Only an error if there was more to read
+		var one [1]byte
+		if n, _ := b.r.Read(one[:]); n > 0 {
+			return 0, errResponseTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
 	}
+	n, err := b.r.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// LoadCommentCheckpoint initializes commentsSince from the newest stored
+// comment, unless it was already set (e.g. by the -since flag)
+func (s *Scanner) LoadCommentCheckpoint(ctx context.Context) error {
+	if !s.commentsSince.IsZero() {
+		return nil
+	}
+
+	query := fmt.Sprintf(`SELECT max(created_at) FROM %s WHERE message_type = 'comment'`, s.table("messages"))
+	if err := s.clickhouseConn.QueryRow(ctx, query).Scan(&s.commentsSince); err != nil {
+		return fmt.Errorf("failed to query latest comment: %w", err)
+	}
+	if s.commentsSince.Unix() <= 0 {
+		// max() of no rows is the epoch
+		s.commentsSince = time.Time{}
+		return nil
+	}
+
+	log.Printf("Fetching recent comments created since %s", s.commentsSince.Format(time.RFC3339))
+	return nil
+}
+
+// FetchFeed fetches posts from the Moltbook API. The request is conditional
+// on the feed's last ETag or Last-Modified, if the API sent one, and
+// returns an error wrapping errNotModified if the feed is unchanged.
+func (s *Scanner) FetchFeed(ctx context.Context, sort string, limit int) ([]MoltbookPost, error) {
+	url := fmt.Sprintf("%s/posts?sort=%s&limit=%d", s.baseURL, sort, limit)
 
 	var feedResp FeedResponse
-	if err := json.NewDecoder(resp.Body).Decode(&feedResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := s.doConditionalRequest(ctx, url, &s.feedValidators, &feedResp); err != nil {
+		return nil, fmt.Errorf("failed to fetch feed: %w", err)
 	}
 
 	if !feedResp.Success {
@@ -421,39 +1501,45 @@ func (s *Scanner) FetchFeed(ctx context.Context, sort string, limit int) ([]Molt
 	return feedResp.Posts, nil
 }
 
-// FetchComments fetches comments for a specific post from the Moltbook API
-func (s *Scanner) FetchComments(ctx context.Context, postID string) ([]MoltbookComment, error) {
-	url := fmt.Sprintf("%s/posts/%s/comments", s.baseURL, postID)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
+// AgentProfileResponse is the response of GET /agents/profile
+type AgentProfileResponse struct {
+	Success     bool           `json:"success"`
+	RecentPosts []MoltbookPost `json:"recentPosts"`
+}
 
-	req.Header.Set("Authorization", "Bearer "+s.moltbookAPIKey)
-	req.Header.Set("Content-Type", "application/json")
+// FetchUserPosts fetches the posts of the agent named authorName. The API
+// has no paginated per-author listing, so this is the posts its profile
+// reports as recent.
+func (s *Scanner) FetchUserPosts(ctx context.Context, authorName string) ([]MoltbookPost, error) {
+	endpoint := fmt.Sprintf("%s/agents/profile?name=%s", s.baseURL, url.QueryEscape(authorName))
 
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch comments: %w", err)
+	var profileResp AgentProfileResponse
+	if err := s.doRequest(ctx, endpoint, &profileResp); err != nil {
+		return nil, fmt.Errorf("failed to fetch profile: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	if !profileResp.Success {
+		return nil, fmt.Errorf("API returned success=false")
 	}
 
-	var commentsResp CommentsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&commentsResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
+	return profileResp.RecentPosts, nil
+}
 
-	if !commentsResp.Success {
-		return nil, fmt.Errorf("API returned success=false")
+// FetchComments fetches comments for a specific post from the Moltbook API,
+// following pagination when the API reports more comments than it returned
+func (s *Scanner) FetchComments(ctx context.Context, postID string) ([]MoltbookComment, error) {
+	endpoint := fmt.Sprintf("%s/posts/%s/comments", s.baseURL, postID)
+
+	comments, err := s.fetchCommentPages(ctx, endpoint, "post "+postID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch comments: %w", err)
 	}
 
-	// Flatten nested comments
+	return flattenComments(comments), nil
+}
+
+// flattenComments returns comments and all their nested replies
+func flattenComments(comments []MoltbookComment) []MoltbookComment {
 	var allComments []MoltbookComment
 	var flatten func(comments []MoltbookComment)
 	flatten = func(comments []MoltbookComment) {
@@ -464,162 +1550,542 @@ func (s *Scanner) FetchComments(ctx context.Context, postID string) ([]MoltbookC
 			}
 		}
 	}
-	flatten(commentsResp.Comments)
-
-	return allComments, nil
+	flatten(comments)
+	return allComments
 }
 
-// FetchRecentComments fetches recent comments from all posts
-func (s *Scanner) FetchRecentComments(ctx context.Context) ([]MoltbookComment, error) {
-	url := fmt.Sprintf("%s/comments?sort=new&limit=100", s.baseURL)
+// fetchCommentPages fetches endpoint and its following pages while the API
+// reports more comments (has_more, next_cursor, or a count above what was
+// returned), up to commentMaxPages. Pages are requested by cursor when the
+// API provides one, by offset otherwise. done, if set, stops pagination
+// early once a page has everything the caller needs. label names the
+// comment set in logs.
+func (s *Scanner) fetchCommentPages(ctx context.Context, endpoint, label string, done func(page []MoltbookComment) bool) ([]MoltbookComment, error) {
+	var all []MoltbookComment
+	var cursor, firstID string
+	count, pages := 0, 0
+
+	for pages < s.commentMaxPages {
+		pageURL := endpoint
+		if pages > 0 {
+			sep := "?"
+			if strings.Contains(endpoint, "?") {
+				sep = "&"
+			}
+			if cursor != "" {
+				pageURL += sep + "cursor=" + url.QueryEscape(cursor)
+			} else {
+				pageURL += fmt.Sprintf("%soffset=%d", sep, len(all))
+			}
+		}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		var resp CommentsResponse
+		err := s.doRequest(ctx, pageURL, &resp)
+		if err == nil && !resp.Success {
+			err = fmt.Errorf("API returned success=false")
+		}
+		if err != nil {
+			if pages == 0 {
+				return nil, err
+			}
+			logCycle(ctx, "Warning: stopped paginating comments for %s after %d pages: %v", label, pages, err)
+			break
+		}
+		pages++
+
+		if len(resp.Comments) == 0 {
+			break
+		}
+		// An API that ignores offset keeps returning the first page
+		if pages > 1 && resp.Comments[0].ID == firstID {
+			logCycleDebug(ctx, "Comments endpoint for %s ignores pagination parameters", label)
+			break
+		}
+		if pages == 1 {
+			firstID = resp.Comments[0].ID
+		}
+
+		all = append(all, resp.Comments...)
+		count = max(count, resp.Count)
+		cursor = resp.NextCursor
+
+		more := resp.HasMore || resp.NextCursor != "" || len(flattenComments(all)) < count
+		if !more || (done != nil && done(resp.Comments)) {
+			break
+		}
 	}
 
-	req.Header.Set("Authorization", "Bearer "+s.moltbookAPIKey)
-	req.Header.Set("Content-Type", "application/json")
+	if fetched := len(flattenComments(all)); fetched < count && (done == nil || pages >= s.commentMaxPages) {
+		logCycle(ctx, "⚠️  Comments for %s truncated: fetched %d of %d after %d pages (COMMENTS_MAX_PAGES=%d)",
+			label, fetched, count, pages, s.commentMaxPages)
+	}
 
-	resp, err := s.httpClient.Do(req)
+	return all, nil
+}
+
+// FetchRecentComments fetches recent comments from all posts. A non-zero
+// since asks the API for comments created after that time only; if the API
+// rejects the parameter it is dropped for the rest of the session. Further
+// pages are only fetched until one reaches comments that were already seen.
+func (s *Scanner) FetchRecentComments(ctx context.Context, since time.Time) ([]MoltbookComment, error) {
+	endpoint := fmt.Sprintf("%s/comments?sort=new&limit=100", s.baseURL)
+	withSince := !since.IsZero() && !s.sinceUnsupported
+	if withSince {
+		endpoint += "&since=" + url.QueryEscape(since.UTC().Format(time.RFC3339Nano))
+	}
+
+	reachedSeen := func(page []MoltbookComment) bool {
+		return s.seenMessages.Has(page[len(page)-1].ID)
+	}
+	comments, err := s.fetchCommentPages(ctx, endpoint, "recent comments", reachedSeen)
 	if err != nil {
+		var statusErr *APIStatusError
+		if withSince && errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusBadRequest || statusErr.StatusCode == http.StatusUnprocessableEntity) {
+			logCycle(ctx, "Comments endpoint rejected the since parameter (status %d), falling back to full recent fetches", statusErr.StatusCode)
+			s.sinceUnsupported = true
+			return s.FetchRecentComments(ctx, time.Time{})
+		}
 		return nil, fmt.Errorf("failed to fetch comments: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	return comments, nil
+}
+
+// KeyMatch is a secret found by ScanText
+type KeyMatch struct {
+	Key  string
+	Type string
+	// Encoding is empty for keys found in the raw text, otherwise the
+	// encoding ("url", "hex", "entity") that had to be undone to reveal the key
+	Encoding string
+	// Parts are the strings a combined match (e.g. AWSKeyPair) was built
+	// from, or the captures a normalized key was found as (see
+	// normalizeKey), each of which occurs in the text and must be masked
+	Parts []string
+	// source is the text the key was found in, decoded if Encoding is set
+	source string
+}
+
+// ScanText scans text for API keys and returns the found keys with their types.
+// Text longer than maxScanBytes is cut on a rune boundary before matching;
+// callers log that once per message with logScanTruncation, as a message
+// is scanned more than once.
+// URL-encoded and hex-encoded forms of the text are scanned as well; keys
+// only visible after decoding are reported with their Encoding.
+// Matching that takes longer than matchTimeout is abandoned and nothing is
+// reported for the text.
+func (s *Scanner) ScanText(text string) []KeyMatch {
+	if s.maxScanBytes > 0 && len(text) > s.maxScanBytes {
+		text = truncateToRuneBoundary(text, s.maxScanBytes)
+	}
+
+	if s.matchTimeout <= 0 {
+		return s.scanText(text)
+	}
+
+	// regexp can't be interrupted, so matching runs in its own goroutine,
+	// which is left to finish in the background if the budget runs out
+	done := make(chan []KeyMatch, 1)
+	go func() { done <- s.scanText(text) }()
+
+	timer := time.NewTimer(s.matchTimeout)
+	defer timer.Stop()
+	select {
+	case matches := <-done:
+		return matches
+	case <-timer.C:
+		scanMatchTimeoutsTotal.Inc()
+		log.Printf("⚠️  Scanning aborted: %d bytes of content not matched within SCAN_MATCH_TIMEOUT=%s", len(text), s.matchTimeout)
+		return nil
+	}
+}
+
+// logScanTruncation logs and counts that the content of a message was
+// longer than MAX_SCAN_BYTES, so only its start was scanned
+func (s *Scanner) logScanTruncation(kind, id, content string) {
+	if s.maxScanBytes > 0 && len(content) > s.maxScanBytes {
+		scanTruncatedTotal.Inc()
+		log.Printf("Content of %s %s truncated for scanning: %d bytes exceeds MAX_SCAN_BYTES=%d", kind, id, len(content), s.maxScanBytes)
+	}
+}
+
+// scanText runs every pattern over text and its decoded variants
+func (s *Scanner) scanText(text string) []KeyMatch {
+	var matches []KeyMatch
+	foundKeys := make(map[string]bool)
+
+	matches = s.matchPatterns(text, "", foundKeys, matches, patternMatchesTotal)
+	for _, variant := range decodedVariants(text, s.decodeEntities) {
+		matches = s.matchPatterns(variant.text, variant.encoding, foundKeys, matches, patternMatchesTotal)
+	}
+
+	return s.filterAllowlisted(matches)
+}
+
+// matchPatterns appends the keys in text not already in foundKeys, counting
+// them by pattern in matched unless it is nil
+func (s *Scanner) matchPatterns(text, encoding string, foundKeys map[string]bool, matches []KeyMatch, matched *LabeledCounter) []KeyMatch {
+	for _, pattern := range s.apiKeyPatterns {
+		for _, loc := range pattern.re.FindAllStringSubmatchIndex(text, -1) {
+			loc = loc[2*pattern.keyGroup : 2*pattern.keyGroup+2]
+			raw := strings.TrimSpace(text[loc[0]:loc[1]])
+			if !pattern.lengths.allows(len(raw)) {
+				continue
+			}
+			normalizedKey := normalizeKey(raw)
+			if foundKeys[normalizedKey] {
+				matches = addRawVariant(matches, normalizedKey, raw)
+				continue
+			}
+			foundKeys[normalizedKey] = true
+			matched.Inc(pattern.name)
+			keyType := pattern.keyType
+			if keyType == "Generic" {
+				if guessed := getAPIKeyType(normalizedKey); guessed != "Unknown" {
+					keyType = guessed
+				}
+			}
+			m := KeyMatch{
+				Key:      normalizedKey,
+				Type:     contextualKeyType(keyType, text, loc[0], loc[1]),
+				Encoding: encoding,
+				source:   text,
+			}
+			if raw != normalizedKey {
+				m.Parts = []string{raw}
+			}
+			matches = append(matches, m)
+		}
+	}
+
+	for _, cp := range s.contextPatterns {
+		for _, loc := range cp.re.FindAllStringIndex(text, -1) {
+			key := text[loc[0]:loc[1]]
+			if !cp.lengths.allows(len(key)) || foundKeys[key] || !hasNearbyKeyword(text, loc[0], loc[1], cp.keywords) {
+				continue
+			}
+			foundKeys[key] = true
+			matched.Inc(cp.keyType)
+			matches = append(matches, KeyMatch{
+				Key:      key,
+				Type:     cp.keyType,
+				Encoding: encoding,
+				source:   text,
+			})
+		}
+	}
+
+	if s.keyTypes.allows("AWSKeyPair") {
+		before := len(matches)
+		matches = matchAWSKeyPairs(text, encoding, foundKeys, matches)
+		matched.Add("AWSKeyPair", float64(len(matches)-before))
+	}
+	if s.keyTypes.allows("DatabaseConnectionString") {
+		before := len(matches)
+		matches = matchConnectionStrings(text, encoding, foundKeys, matches)
+		matched.Add("DatabaseConnectionString", float64(len(matches)-before))
+	}
+	if s.keyTypes.allows("AuthorizationHeader") {
+		var added int
+		matches, added = matchAuthHeaders(text, encoding, foundKeys, matches)
+		matched.Add("AuthorizationHeader", float64(added))
+	}
+
+	for _, pp := range s.piiPatterns {
+		for _, match := range pp.re.FindAllString(text, -1) {
+			if foundKeys[match] {
+				continue
+			}
+			foundKeys[match] = true
+			matched.Inc(pp.piiType)
+			matches = append(matches, KeyMatch{
+				Key:      match,
+				Type:     pp.piiType,
+				Encoding: encoding,
+				source:   text,
+			})
+		}
+	}
+
+	return matches
+}
+
+// addRawVariant records raw, a differently cased capture of the already
+// matched key, as a part of that match so it is masked in excerpts too
+func addRawVariant(matches []KeyMatch, key, raw string) []KeyMatch {
+	if raw == key {
+		return matches
+	}
+	for i := range matches {
+		if matches[i].Key == key && !slices.Contains(matches[i].Parts, raw) {
+			matches[i].Parts = append(matches[i].Parts, raw)
+		}
+	}
+	return matches
+}
+
+// matchKeys returns the keys of matches, in order, followed by the parts of
+// any combined matches
+func matchKeys(matches []KeyMatch) []string {
+	keys := make([]string, 0, len(matches))
+	for _, m := range matches {
+		keys = append(keys, m.Key)
+	}
+	for _, m := range matches {
+		keys = append(keys, m.Parts...)
+	}
+	return keys
+}
+
+// excerptKey is the string contentExcerpt centres on for m: the key itself,
+// or for combined matches the first part, as the key doesn't occur verbatim
+func (m KeyMatch) excerptKey() string {
+	if len(m.Parts) > 0 {
+		return m.Parts[0]
+	}
+	return m.Key
+}
+
+// matchTypes returns the key types of matches, in order
+func matchTypes(matches []KeyMatch) []string {
+	types := make([]string, len(matches))
+	for i, m := range matches {
+		types[i] = m.Type
+	}
+	return types
+}
+
+// ScanPost scans a post for API keys and returns findings
+func (s *Scanner) ScanPost(post MoltbookPost) []APIKeyFinding {
+	var findings []APIKeyFinding
+	s.logScanTruncation("post", post.ID, post.Content)
+
+	// Title and content are scanned apart so findings record which one the
+	// key was in. A key in both is reported once, as a title finding.
+	titleMatches := s.ScanText(post.Title)
+	var contentMatches []KeyMatch
+	inTitle := make(map[string]bool, len(titleMatches))
+	for _, m := range titleMatches {
+		inTitle[m.Key] = true
+	}
+	for _, m := range s.ScanText(post.Content) {
+		if !inTitle[m.Key] {
+			contentMatches = append(contentMatches, m)
+		}
 	}
+	keys := matchKeys(slices.Concat(titleMatches, contentMatches))
 
-	var commentsResp CommentsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&commentsResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	authorName := "Unknown"
+	if post.Author != nil {
+		authorName = post.Author.Name
 	}
 
-	if !commentsResp.Success {
-		return nil, fmt.Errorf("API returned success=false")
+	submoltName := "general"
+	if post.Submolt != nil {
+		submoltName = post.Submolt.Name
 	}
 
-	return commentsResp.Comments, nil
-}
+	foundAt := s.now()
+	latency := detectionLatency(foundAt, post.CreatedAt)
 
-// ScanText scans text for API keys and returns the found keys with their types
-func (s *Scanner) ScanText(text string) ([]string, []string) {
-	var keys []string
-	var types []string
-	foundKeys := make(map[string]bool)
+	for i, m := range slices.Concat(titleMatches, contentMatches) {
+		location, excerptSource := LocationContent, post.Content
+		if i < len(titleMatches) {
+			location, excerptSource = LocationTitle, post.Title
+		}
+		if m.Encoding != "" {
+			excerptSource = m.source
+		}
 
-	for _, pattern := range s.apiKeyPatterns {
-		matches := pattern.FindAllString(text, -1)
-		for _, match := range matches {
-			normalizedKey := strings.TrimSpace(match)
-			if foundKeys[normalizedKey] {
-				continue
-			}
-			foundKeys[normalizedKey] = true
-			keys = append(keys, normalizedKey)
-			types = append(types, getAPIKeyType(normalizedKey))
+		finding := APIKeyFinding{
+			PostID:           post.ID,
+			MessageID:        post.ID,
+			PostTitle:        post.Title,
+			AuthorName:       authorName,
+			SubmoltName:      submoltName,
+			APIKey:           storedValue(m),
+			APIKeyType:       m.Type,
+			Severity:         getSeverity(m.Type),
+			Confidence:       getConfidence(m.Type),
+			Encoding:         m.Encoding,
+			Content:          s.findingContent(excerptSource, m, keys),
+			PostURL:          fmt.Sprintf("https://www.moltbook.com/post/%s", post.ID),
+			FoundAt:          foundAt,
+			PostCreatedAt:    post.CreatedAt,
+			DetectionLatency: latency,
+			Location:         location,
 		}
+		findings = append(findings, finding)
 	}
 
-	return keys, types
+	return findings
 }
 
-// ScanPost scans a post for API keys and returns findings
-func (s *Scanner) ScanPost(post MoltbookPost) []APIKeyFinding {
+// ScanComment scans a comment for API keys and returns findings
+func (s *Scanner) ScanComment(comment MoltbookComment, postTitle string, submoltName string) []APIKeyFinding {
 	var findings []APIKeyFinding
+	s.logScanTruncation("comment", comment.ID, comment.Content)
 
-	// Combine title and content for scanning
-	textToScan := post.Title + "\n" + post.Content
+	matches := s.ScanText(comment.Content)
+	keys := matchKeys(matches)
 
-	// Track unique keys to avoid duplicates in the same post
-	foundKeys := make(map[string]bool)
+	authorName := "Unknown"
+	if comment.Author != nil {
+		authorName = comment.Author.Name
+	}
 
-	for _, pattern := range s.apiKeyPatterns {
-		matches := pattern.FindAllString(textToScan, -1)
-		for _, match := range matches {
-			// Normalize the key for deduplication
-			normalizedKey := strings.TrimSpace(match)
-			if foundKeys[normalizedKey] {
-				continue
-			}
-			foundKeys[normalizedKey] = true
+	foundAt := s.now()
+	latency := detectionLatency(foundAt, comment.CreatedAt)
+
+	for _, m := range matches {
+		finding := APIKeyFinding{
+			PostID:           comment.PostID,
+			MessageID:        comment.ID,
+			PostTitle:        postTitle + " (comment)",
+			AuthorName:       authorName,
+			SubmoltName:      submoltName,
+			APIKey:           storedValue(m),
+			APIKeyType:       m.Type,
+			Severity:         getSeverity(m.Type),
+			Confidence:       getConfidence(m.Type),
+			Encoding:         m.Encoding,
+			Content:          s.findingContent(m.source, m, keys),
+			PostURL:          fmt.Sprintf("https://www.moltbook.com/post/%s", comment.PostID),
+			FoundAt:          foundAt,
+			PostCreatedAt:    comment.CreatedAt,
+			DetectionLatency: latency,
+			Location:         LocationContent,
+		}
+		findings = append(findings, finding)
+	}
 
-			authorName := "Unknown"
-			if post.Author != nil {
-				authorName = post.Author.Name
-			}
+	return findings
+}
 
-			submoltName := "general"
-			if post.Submolt != nil {
-				submoltName = post.Submolt.Name
-			}
+// What a finding's content column holds, set by FINDING_CONTENT_MODE
+const (
+	ContentModeNone          = "none"           // nothing, the key hash identifies the finding
+	ContentModeMaskedExcerpt = "masked_excerpt" // the masked window around the key
+	ContentModeFull          = "full"           // the whole message, secrets included
+)
 
-			finding := APIKeyFinding{
-				PostID:        post.ID,
-				PostTitle:     post.Title,
-				AuthorName:    authorName,
-				SubmoltName:   submoltName,
-				APIKey:        normalizedKey,
-				APIKeyType:    getAPIKeyType(normalizedKey),
-				Content:       truncateString(post.Content, 1000),
-				PostURL:       fmt.Sprintf("https://www.moltbook.com/post/%s", post.ID),
-				FoundAt:       time.Now(),
-				PostCreatedAt: post.CreatedAt,
-			}
-			findings = append(findings, finding)
+// findingContent returns what to store as the content of finding m, found
+// in source alongside keys
+func (s *Scanner) findingContent(source string, m KeyMatch, keys []string) string {
+	switch s.contentMode {
+	case ContentModeNone:
+		return ""
+	case ContentModeFull:
+		return source
+	default:
+		return contentExcerpt(source, m.excerptKey(), keys, s.contextWindow)
+	}
+}
+
+// maxExcerptWindows bounds how many occurrences of a key contentExcerpt shows
+const maxExcerptWindows = 5
+
+// contentExcerpt returns the content surrounding each occurrence of key, with
+// window runes kept on either side and overlapping windows merged. Every key
+// in allKeys is masked before cutting so no secret ends up in the excerpt.
+// If key does not occur in content (e.g. it was only in the title), the
+// start of the masked content is returned instead.
+func contentExcerpt(content, key string, allKeys []string, window int) string {
+	masked := maskKeys(content, allKeys)
+
+	needle := maskKey(key)
+	type span struct{ start, end int }
+	var spans []span
+	for offset := 0; len(spans) < maxExcerptWindows; {
+		idx := strings.Index(masked[offset:], needle)
+		if idx < 0 {
+			break
 		}
+		start := runeOffsetBefore(masked, offset+idx, window)
+		end := runeOffsetAfter(masked, offset+idx+len(needle), window)
+		if n := len(spans); n > 0 && start <= spans[n-1].end {
+			spans[n-1].end = end
+		} else {
+			spans = append(spans, span{start, end})
+		}
+		offset += idx + len(needle)
 	}
 
-	return findings
+	if len(spans) == 0 {
+		return truncateString(masked, 2*window)
+	}
+
+	var b strings.Builder
+	for i, sp := range spans {
+		if sp.start > 0 || i > 0 {
+			b.WriteString("...")
+		}
+		b.WriteString(masked[sp.start:sp.end])
+	}
+	if spans[len(spans)-1].end < len(masked) {
+		b.WriteString("...")
+	}
+	return b.String()
 }
 
-// ScanComment scans a comment for API keys and returns findings
-func (s *Scanner) ScanComment(comment MoltbookComment, postTitle string, submoltName string) []APIKeyFinding {
-	var findings []APIKeyFinding
-	foundKeys := make(map[string]bool)
+// maskKeys returns content with every occurrence of keys masked. Longer keys
+// are masked first so a key containing another is masked whole.
+func maskKeys(content string, keys []string) string {
+	sorted := append([]string(nil), keys...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, k := range sorted {
+		content = strings.ReplaceAll(content, k, maskKey(k))
+	}
+	return content
+}
 
-	for _, pattern := range s.apiKeyPatterns {
-		matches := pattern.FindAllString(comment.Content, -1)
-		for _, match := range matches {
-			normalizedKey := strings.TrimSpace(match)
-			if foundKeys[normalizedKey] {
-				continue
-			}
-			foundKeys[normalizedKey] = true
+// maskSecrets masks every key the scanner's patterns find in text. Unlike
+// ScanText it applies no size cap, so nothing past MAX_SCAN_BYTES slips
+// through unmasked, and the keys aren't counted in
+// moltbook_pattern_matches_total, as they were already reported when the
+// text was scanned.
+func (s *Scanner) maskSecrets(text string) string {
+	matches := s.matchPatterns(text, "", make(map[string]bool), nil, nil)
+	return maskKeys(text, matchKeys(matches))
+}
 
-			authorName := "Unknown"
-			if comment.Author != nil {
-				authorName = comment.Author.Name
-			}
+// runeOffsetBefore returns the byte offset n runes before i in s
+func runeOffsetBefore(s string, i, n int) int {
+	for ; n > 0 && i > 0; n-- {
+		_, size := utf8.DecodeLastRuneInString(s[:i])
+		i -= size
+	}
+	return i
+}
 
-			finding := APIKeyFinding{
-				PostID:        comment.PostID,
-				PostTitle:     postTitle + " (comment)",
-				AuthorName:    authorName,
-				SubmoltName:   submoltName,
-				APIKey:        normalizedKey,
-				APIKeyType:    getAPIKeyType(normalizedKey),
-				Content:       truncateString(comment.Content, 1000),
-				PostURL:       fmt.Sprintf("https://www.moltbook.com/post/%s", comment.PostID),
-				FoundAt:       time.Now(),
-				PostCreatedAt: comment.CreatedAt,
-			}
-			findings = append(findings, finding)
-		}
+// runeOffsetAfter returns the byte offset n runes after i in s
+func runeOffsetAfter(s string, i, n int) int {
+	for ; n > 0 && i < len(s); n-- {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
 	}
+	return i
+}
 
-	return findings
+// detectionLatency returns how long after creation a message was scanned.
+// Negative deltas from clock skew are clamped to zero.
+func detectionLatency(foundAt, createdAt time.Time) time.Duration {
+	latency := foundAt.Sub(createdAt)
+	if latency < 0 {
+		logDebug("Clamping negative detection latency %s (created_at %s is in the future)", latency, createdAt.Format(time.RFC3339))
+		return 0
+	}
+	return latency
 }
 
-// SaveFinding saves an API key finding to ClickHouse
-func (s *Scanner) SaveFinding(ctx context.Context, finding APIKeyFinding) error {
-	query := fmt.Sprintf(`INSERT INTO %s.api_key_findings 
-		(post_id, post_title, author_name, submolt_name, api_key, api_key_type, content, post_url, found_at, post_created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, s.databaseName)
+// findingColumns are the api_key_findings columns SaveFinding writes, in the
+// order of findingRowValues
+const findingColumns = `post_id, post_title, author_name, submolt_name, api_key, api_key_type, content, post_url, found_at, post_created_at,
+	detection_latency_ms, encoding, key_sha256, source_url, origin, severity, cycle_id, location, message_id`
 
-	err := s.clickhouseConn.Exec(ctx, query,
+// findingRowValues returns the values of finding for findingColumns
+func findingRowValues(ctx context.Context, finding APIKeyFinding) []any {
+	return []any{
 		finding.PostID,
 		finding.PostTitle,
 		finding.AuthorName,
@@ -630,28 +2096,88 @@ func (s *Scanner) SaveFinding(ctx context.Context, finding APIKeyFinding) error
 		finding.PostURL,
 		finding.FoundAt,
 		finding.PostCreatedAt,
-	)
+		uint64(finding.DetectionLatency.Milliseconds()),
+		finding.Encoding,
+		finding.keySHA256(),
+		finding.SourceURL,
+		finding.Origin,
+		finding.Severity,
+		cycleID(ctx),
+		finding.Location,
+		finding.MessageID,
+	}
+}
+
+// SaveFinding saves an API key finding to ClickHouse, recording it in
+// DLQ_FILE if that fails
+func (s *Scanner) SaveFinding(ctx context.Context, finding APIKeyFinding) error {
+	err := s.saveFinding(ctx, finding)
+	recordSave(ctx, err)
 	if err != nil {
+		s.deadLetterFinding(ctx, finding, err)
+	}
+	return err
+}
+
+func (s *Scanner) saveFinding(ctx context.Context, finding APIKeyFinding) error {
+	if s.findingDedup != nil {
+		return s.saveDedupedFinding(ctx, finding, finding.keySHA256())
+	}
+	return s.insertFinding(ctx, finding)
+}
+
+// insertFinding writes finding as a new api_key_findings row
+func (s *Scanner) insertFinding(ctx context.Context, finding APIKeyFinding) error {
+	values := findingRowValues(ctx, finding)
+	query := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`,
+		s.table("api_key_findings"), findingColumns, placeholders(len(values)))
+
+	if err := s.writeLimiter.acquire(ctx); err != nil {
 		return err
 	}
+	defer s.writeLimiter.release()
 
-	return nil
+	return s.clickhouseConn.Exec(s.insertContext(ctx), query, values...)
+}
+
+// placeholders returns n comma-separated query placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
 }
 
-// SaveMessage saves a scanned message (post or comment) to ClickHouse
+// SaveMessage saves a scanned message (post or comment) to ClickHouse,
+// recording it in DLQ_FILE if that fails
 func (s *Scanner) SaveMessage(ctx context.Context, msg ScannedMessage) error {
-	query := fmt.Sprintf(`INSERT INTO %s.messages 
+	err := s.saveMessage(ctx, msg)
+	recordSave(ctx, err)
+	if err != nil {
+		s.deadLetterMessage(ctx, msg, err)
+	}
+	return err
+}
+
+func (s *Scanner) saveMessage(ctx context.Context, msg ScannedMessage) error {
+	query := fmt.Sprintf(`INSERT INTO %s 
 		(id, message_type, post_id, parent_id, title, content, author_id, author_name, 
 		 submolt_id, submolt_name, upvotes, downvotes, comment_count, message_url, 
-		 created_at, has_api_key, api_key_types)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, s.databaseName)
+		 created_at, scanned_at, has_api_key, api_key_types, cycle_id, content_sha256)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, s.table("messages"))
 
 	hasAPIKey := uint8(0)
 	if msg.HasAPIKey {
 		hasAPIKey = 1
 	}
+	scannedAt := msg.ScannedAt
+	if scannedAt.IsZero() {
+		scannedAt = s.now()
+	}
 
-	return s.clickhouseConn.Exec(ctx, query,
+	if err := s.writeLimiter.acquire(ctx); err != nil {
+		return err
+	}
+	defer s.writeLimiter.release()
+
+	return s.clickhouseConn.Exec(s.insertContext(ctx), query,
 		msg.ID,
 		msg.MessageType,
 		msg.PostID,
@@ -667,8 +2193,11 @@ func (s *Scanner) SaveMessage(ctx context.Context, msg ScannedMessage) error {
 		msg.CommentCount,
 		msg.MessageURL,
 		msg.CreatedAt,
+		scannedAt,
 		hasAPIKey,
 		msg.APIKeyTypes,
+		cycleID(ctx),
+		msg.contentSHA256(),
 	)
 }
 
@@ -688,7 +2217,7 @@ func (s *Scanner) PostToMessage(post MoltbookPost) ScannedMessage {
 		submoltName = post.Submolt.Name
 	}
 
-	_, apiKeyTypes := s.ScanText(post.Title + "\n" + post.Content)
+	apiKeyTypes := matchTypes(s.ScanText(post.Title + "\n" + post.Content))
 
 	return ScannedMessage{
 		ID:           post.ID,
@@ -706,7 +2235,7 @@ func (s *Scanner) PostToMessage(post MoltbookPost) ScannedMessage {
 		CommentCount: post.CommentCount,
 		MessageURL:   fmt.Sprintf("https://www.moltbook.com/post/%s", post.ID),
 		CreatedAt:    post.CreatedAt,
-		ScannedAt:    time.Now(),
+		ScannedAt:    s.now(),
 		HasAPIKey:    len(apiKeyTypes) > 0,
 		APIKeyTypes:  apiKeyTypes,
 	}
@@ -726,7 +2255,7 @@ func (s *Scanner) CommentToMessage(comment MoltbookComment, submoltName string)
 		parentID = *comment.ParentID
 	}
 
-	_, apiKeyTypes := s.ScanText(comment.Content)
+	apiKeyTypes := matchTypes(s.ScanText(comment.Content))
 
 	return ScannedMessage{
 		ID:           comment.ID,
@@ -744,119 +2273,335 @@ func (s *Scanner) CommentToMessage(comment MoltbookComment, submoltName string)
 		CommentCount: 0,
 		MessageURL:   fmt.Sprintf("https://www.moltbook.com/post/%s", comment.PostID),
 		CreatedAt:    comment.CreatedAt,
-		ScannedAt:    time.Now(),
+		ScannedAt:    s.now(),
 		HasAPIKey:    len(apiKeyTypes) > 0,
 		APIKeyTypes:  apiKeyTypes,
 	}
 }
 
-// Run starts the scanner loop
+// Run starts the scanner loop. When ctx is cancelled no new scan is started,
+// and the in-flight one is given up to shutdownGrace to reach a safe stopping
+// point before its work is forcibly cancelled.
 func (s *Scanner) Run(ctx context.Context) error {
-	log.Printf("Starting Moltbook API Key Scanner (poll interval: %s)", s.pollInterval)
+	log.Printf("Starting Moltbook API Key Scanner (targets: %s, poll interval: posts %s, comments %s)", s.targets, s.postInterval, s.commentInterval)
+
+	// Work runs on its own context so a shutdown request doesn't abort
+	// requests and inserts mid-cycle
+	workCtx, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+	s.shutdown = ctx.Done()
 
 	// Initialize database
-	if err := s.InitDatabase(ctx); err != nil {
+	if err := s.InitDatabase(workCtx); err != nil {
 		return fmt.Errorf("failed to initialize database: %w", err)
 	}
 
+	if s.listenAddr != "" {
+		s.startHTTPServer(ctx, s.listenAddr)
+	}
+	if s.graphQLAddr != "" {
+		s.startGraphQLServer(ctx, s.graphQLAddr)
+	}
+
 	// Load previously scanned messages
-	if err := s.LoadSeenMessages(ctx); err != nil {
+	if err := s.LoadSeenMessages(workCtx); err != nil {
 		log.Printf("Warning: failed to load seen messages: %v", err)
 	}
+	if err := s.LoadCommentCheckpoint(workCtx); err != nil {
+		log.Printf("Warning: failed to load comment checkpoint: %v", err)
+	}
+	if err := s.LoadNotifiedFingerprints(workCtx); err != nil {
+		log.Printf("Warning: failed to load notified fingerprints: %v", err)
+	}
+
+	if s.digestInterval > 0 {
+		go s.runDigest(ctx)
+	}
+
+	// Posts and comments poll on independent tickers. The post loop also
+	// covers the comments of new posts when comments are a target.
+	var loops sync.WaitGroup
+	if s.targets.posts {
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			s.pollLoop(ctx, workCtx, "Post", s.postInterval, s.scanPosts)
+		}()
+	}
+	if s.targets.comments && !s.recentComments {
+		log.Printf("Recent comments endpoint disabled (ENABLE_RECENT_COMMENTS=false), comments are only fetched per post")
+	}
+	if s.targets.comments && s.recentComments {
+		s.threadComments.active.Store(true)
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			s.pollLoop(ctx, workCtx, "Comment", s.commentInterval, s.scanComments)
+		}()
+	}
+	if s.submoltInterval > 0 {
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			s.pollLoop(ctx, workCtx, "Submolt", s.submoltInterval, s.scanSubmolts)
+		}()
+	}
+	loopDone := make(chan struct{})
+	go func() {
+		loops.Wait()
+		close(loopDone)
+	}()
+
+	<-ctx.Done()
+	log.Printf("Shutting down scanner, waiting up to %s for the current scan...", s.shutdownGrace)
+	graceCtx, cancelGrace := context.WithTimeout(context.Background(), s.shutdownGrace)
+	defer cancelGrace()
+
+	select {
+	case <-loopDone:
+		// Use what's left of the grace period to deliver queued notifications
+		s.flushNotifiers(graceCtx)
+		log.Println("Scanner stopped cleanly")
+	case <-graceCtx.Done():
+		log.Println("Shutdown grace period expired, abandoning in-flight scan")
+		cancelWork()
+		// The ClickHouse connection is closed once Run returns, so wait for
+		// the cancelled scans to unwind before handing it back
+		select {
+		case <-loopDone:
+			log.Println("Abandoned scan stopped")
+		case <-time.After(abandonTimeout):
+			return fmt.Errorf("scan loops still running %s after being cancelled", abandonTimeout)
+		}
+	}
+	return nil
+}
 
-	// Initial scan
-	if err := s.scan(ctx); err != nil {
-		log.Printf("Initial scan error: %v", err)
+// abandonTimeout bounds how long Run waits for cancelled scans to return
+// once the shutdown grace period has expired
+const abandonTimeout = 5 * time.Second
+
+// pollLoop runs scanFn immediately and then every interval until ctx is
+// cancelled. Scans run on workCtx so shutdown lets the current one finish.
+func (s *Scanner) pollLoop(ctx, workCtx context.Context, name string, interval time.Duration, scanFn func(context.Context) error) {
+	err := scanFn(workCtx)
+	s.health.record(name, err)
+	if err != nil {
+		log.Printf("Initial %s scan error: %v", strings.ToLower(name), err)
 	}
 
-	// Start periodic scanning
-	ticker := time.NewTicker(s.pollInterval)
-	defer ticker.Stop()
+	// Each cycle is scheduled a freshly jittered interval after the start of
+	// the previous one; a cycle that overruns starts the next at once
+	next := time.Now().Add(s.jitteredInterval(interval))
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Shutting down scanner...")
-			return nil
-		case <-ticker.C:
-			if err := s.scan(ctx); err != nil {
-				log.Printf("Scan error: %v", err)
+			return
+		case <-timer.C:
+			err := scanFn(workCtx)
+			s.health.record(name, err)
+			if err != nil {
+				log.Printf("%s scan error: %v", name, err)
 			}
+			next = next.Add(s.jitteredInterval(interval))
+			if now := time.Now(); next.Before(now) {
+				next = now
+			}
+			timer.Reset(time.Until(next))
 		}
 	}
 }
 
-// scan performs a single scan of the feed and comments
-func (s *Scanner) scan(ctx context.Context) error {
+// jitteredInterval returns interval varied by up to ±pollJitter of itself,
+// so replicas started together drift apart instead of polling in lockstep
+func (s *Scanner) jitteredInterval(interval time.Duration) time.Duration {
+	if s.pollJitter <= 0 {
+		return interval
+	}
+	return interval + time.Duration((rand.Float64()*2-1)*s.pollJitter*float64(interval))
+}
+
+// parsePollJitter parses POLL_JITTER as a fraction ("0.1") or percentage
+// ("10%") of the poll interval. Empty means no jitter.
+func parsePollJitter(raw string) (float64, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	percent := strings.HasSuffix(raw, "%")
+	jitter, err := strconv.ParseFloat(strings.TrimSuffix(raw, "%"), 64)
+	if percent {
+		jitter /= 100
+	}
+	if err != nil || jitter < 0 || jitter >= 1 {
+		return 0, fmt.Errorf("invalid POLL_JITTER %q: expected a fraction like 0.1 or a percentage like 10%%, below 100%%", raw)
+	}
+	return jitter, nil
+}
+
+// stopping reports whether shutdown has been requested, so scan loops can
+// stop starting new work at a safe point
+func (s *Scanner) stopping() bool {
+	select {
+	case <-s.shutdown:
+		return true
+	default:
+		return false
+	}
+}
+
+// scanPosts performs a single scan of the feed and the comments of new posts
+func (s *Scanner) scanPosts(ctx context.Context) error {
+	ctx = withCycleID(ctx)
 	newMessages := 0
 	newPosts := 0
 	newComments := 0
 	totalFindings := 0
 	saveErrors := 0
+	tooOld := 0
 
 	// Fetch and scan posts
 	posts, err := s.FetchFeed(ctx, "new", 100)
-	if err != nil {
-		log.Printf("Error fetching feed: %v", err)
-	} else {
+	switch {
+	case errors.Is(err, errNotModified):
+		logCycle(ctx, "Feed not modified since the last fetch (304), skipping feed processing")
+	case err != nil:
+		logCycle(ctx, "Error fetching feed: %v", err)
+	default:
 		for _, post := range posts {
-			// Skip already scanned posts
-			if s.seenMessages[post.ID] {
+			if s.stopping() {
+				break
+			}
+
+			// Skip submolts we're not interested in
+			if !s.submoltFilter.allows(post.Submolt) {
+				continue
+			}
+
+			// Skip old posts along with their comment trees; new comments
+			// on them still come in through the recent comments feed
+			if s.tooOld(post.CreatedAt) {
+				tooOld++
 				continue
 			}
 
-			newMessages++
-			newPosts++
+			// Skip already scanned posts; edited ones are scanned again
+			version := s.seenMessages.TryAddVersion(post.ID, contentHash(post.Title, post.Content))
+			if version == versionSeen {
+				continue
+			}
+			if version == versionEdited {
+				logCycle(ctx, "Post %s was edited, rescanning", post.ID)
+				s.recordRevision(ctx, post.ID)
+			} else {
+				newMessages++
+				newPosts++
+			}
 
 			// Convert to message and save
 			msg := s.PostToMessage(post)
 			if err := s.SaveMessage(ctx, msg); err != nil {
 				saveErrors++
 			}
+			s.recordRawPayload(ctx, post.ID, "post", post.raw)
 
-			// Scan the post for API keys
+			// Scan the post, its attachments and the documents it links to
+			// for API keys
 			findings := s.ScanPost(post)
+			findings = append(findings, s.scanPostAttachments(post, findings)...)
+			findings = append(findings, s.scanPostLinks(ctx, post, findings)...)
 
-			for _, finding := range findings {
-				if err := s.SaveFinding(ctx, finding); err != nil {
-					saveErrors++
-				} else {
-					totalFindings++
-				}
-			}
+			s.processFindings(ctx, findings, &totalFindings, &saveErrors)
 
-			s.seenMessages[post.ID] = true
-
-			// Fetch and scan comments for this post if it has any
-			if post.CommentCount > 0 {
-				s.scanPostComments(ctx, post, &newMessages, &newComments, &totalFindings, &saveErrors)
+			// Fetch and scan comments for this post if it is new and has
+			// enough; the recent comments loop catches the rest
+			if version == versionNew && s.targets.comments && post.CommentCount >= s.minComments {
+				if !s.scanPostComments(ctx, post, &newMessages, &newComments, &totalFindings, &saveErrors, &tooOld) {
+					s.commentBackfill.add(post)
+				}
 			}
 		}
 	}
 
-	// Also try to fetch recent comments directly (some APIs support this)
-	s.scanRecentComments(ctx, &newMessages, &newComments, &totalFindings, &saveErrors)
+	// Retry the comments of earlier posts whose fetch failed
+	if s.targets.comments {
+		s.retryPendingComments(ctx, &newMessages, &newComments, &totalFindings, &saveErrors, &tooOld)
+	}
+
+	s.flushRawPayloads(ctx)
+	s.logTooOld(ctx, "Post", tooOld)
+	s.seenMessages.recordMetrics()
+	s.logScanSummary(ctx, "Post", newMessages, newPosts, newComments, totalFindings, saveErrors)
+	s.checkSubmoltSpikes(ctx, "Post")
+	return s.checkSaveErrors(ctx)
+}
+
+// scanComments fetches recent comments directly (some APIs support this)
+func (s *Scanner) scanComments(ctx context.Context) error {
+	ctx = withCycleID(ctx)
+	newMessages := 0
+	newComments := 0
+	totalFindings := 0
+	saveErrors := 0
+	tooOld := 0
+
+	s.scanRecentComments(ctx, &newMessages, &newComments, &totalFindings, &saveErrors, &tooOld)
+
+	s.flushRawPayloads(ctx)
+	s.logTooOld(ctx, "Comment", tooOld)
+	s.seenMessages.recordMetrics()
+	s.logScanSummary(ctx, "Comment", newMessages, 0, newComments, totalFindings, saveErrors)
+	s.checkSubmoltSpikes(ctx, "Comment")
+	return s.checkSaveErrors(ctx)
+}
+
+// tooOld reports whether a message created at createdAt is older than
+// MAX_MESSAGE_AGE and should be skipped
+func (s *Scanner) tooOld(createdAt time.Time) bool {
+	return s.maxMessageAge > 0 && s.now().Sub(createdAt) > s.maxMessageAge
+}
+
+// logTooOld logs how many messages a scan skipped for their age
+func (s *Scanner) logTooOld(ctx context.Context, name string, tooOld int) {
+	if tooOld > 0 {
+		logCycle(ctx, "%s scan skipped %d messages older than MAX_MESSAGE_AGE=%s", name, tooOld, s.maxMessageAge)
+	}
+}
 
-	// Log summary
+// logScanSummary adds a scan to the digest counters and logs its outcome if
+// it found anything new, only breaking down the targets that are scanned
+func (s *Scanner) logScanSummary(ctx context.Context, name string, newMessages, newPosts, newComments, totalFindings, saveErrors int) {
+	s.activity.addScan(newMessages, saveErrors)
 	if newMessages > 0 || totalFindings > 0 {
-		log.Printf("📊 Scan complete: %d new messages (%d posts, %d comments), %d API keys found",
-			newMessages, newPosts, newComments, totalFindings)
+		var breakdown []string
+		if s.targets.posts {
+			breakdown = append(breakdown, fmt.Sprintf("%d posts", newPosts))
+		}
+		if s.targets.comments {
+			breakdown = append(breakdown, fmt.Sprintf("%d comments", newComments))
+		}
+		logCycle(ctx, "📊 %s scan complete: %d new messages (%s), %d API keys found",
+			name, newMessages, strings.Join(breakdown, ", "), totalFindings)
 		if saveErrors > 0 {
-			log.Printf("⚠️  %d save errors occurred", saveErrors)
+			logCycle(ctx, "⚠️  %d save errors occurred", saveErrors)
 		}
 		if totalFindings > 0 {
-			log.Printf("🔑 Found %d exposed API keys!", totalFindings)
+			logCycle(ctx, "🔑 Found %d exposed API keys!", totalFindings)
 		}
 	}
-	return nil
 }
 
-// scanPostComments scans comments for a specific post
-func (s *Scanner) scanPostComments(ctx context.Context, post MoltbookPost, newMessages *int, newComments *int, totalFindings *int, saveErrors *int) {
+// scanPostComments scans comments for a specific post and reports whether
+// they could be fetched
+func (s *Scanner) scanPostComments(ctx context.Context, post MoltbookPost, newMessages *int, newComments *int, totalFindings *int, saveErrors *int, tooOld *int) bool {
 	comments, err := s.FetchComments(ctx, post.ID)
 	if err != nil {
 		// Don't log every comment fetch error - too noisy
-		return
+		logCycleDebug(ctx, "Failed to fetch comments of post %s: %v", post.ID, err)
+		return false
 	}
 
 	submoltName := "general"
@@ -865,66 +2610,136 @@ func (s *Scanner) scanPostComments(ctx context.Context, post MoltbookPost, newMe
 	}
 
 	for _, comment := range comments {
-		if s.seenMessages[comment.ID] {
+		if s.stopping() {
+			return true
+		}
+		s.threadComments.add(comment)
+		if s.tooOld(comment.CreatedAt) {
+			*tooOld++
+			continue
+		}
+		if !s.trackComment(ctx, comment, newMessages, newComments) {
 			continue
 		}
-
-		*newMessages++
-		*newComments++
 
 		// Convert to message and save
 		msg := s.CommentToMessage(comment, submoltName)
 		if err := s.SaveMessage(ctx, msg); err != nil {
 			*saveErrors++
 		}
+		s.recordRawPayload(ctx, comment.ID, "comment", comment.raw)
 
 		// Scan for API keys
 		findings := s.ScanComment(comment, post.Title, submoltName)
-		for _, finding := range findings {
-			if err := s.SaveFinding(ctx, finding); err != nil {
-				*saveErrors++
-			} else {
-				*totalFindings++
-			}
-		}
+		s.processFindings(ctx, findings, totalFindings, saveErrors)
+	}
+	return true
+}
 
-		s.seenMessages[comment.ID] = true
+// trackComment records comment in the seen set and reports whether it needs
+// scanning: it is new, counted in newMessages and newComments, or was
+// edited since it was last scanned
+func (s *Scanner) trackComment(ctx context.Context, comment MoltbookComment, newMessages *int, newComments *int) bool {
+	switch s.seenMessages.TryAddVersion(comment.ID, contentHash("", comment.Content)) {
+	case versionNew:
+		*newMessages++
+		*newComments++
+		return true
+	case versionEdited:
+		logCycle(ctx, "Comment %s was edited, rescanning", comment.ID)
+		s.recordRevision(ctx, comment.ID)
+		return true
+	default:
+		return false
 	}
 }
 
-// scanRecentComments tries to fetch recent comments directly
-func (s *Scanner) scanRecentComments(ctx context.Context, newMessages *int, newComments *int, totalFindings *int, saveErrors *int) {
-	comments, err := s.FetchRecentComments(ctx)
+// scanRecentComments tries to fetch recent comments directly. A 404 means
+// the API has no recent comments endpoint, so it is not asked again for
+// the rest of the session.
+func (s *Scanner) scanRecentComments(ctx context.Context, newMessages *int, newComments *int, totalFindings *int, saveErrors *int, tooOld *int) {
+	if !s.recentComments {
+		return
+	}
+	comments, err := s.FetchRecentComments(ctx, s.commentsSince)
 	if err != nil {
-		// This endpoint might not exist, silently skip
+		var statusErr *APIStatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			logCycle(ctx, "Recent comments endpoint not found (status 404), disabling it for this session; comments are only fetched per post")
+			s.recentComments = false
+			s.threadComments.stop()
+			return
+		}
+		// Other failures are usually transient, try again next cycle
+		logCycleDebug(ctx, "Recent comments fetch failed: %v", err)
 		return
 	}
 
+	// The API may ignore since, so seenMessages remains the source of
+	// truth for deduplication. Comments the post loop fetched with their
+	// post since the last scan are skipped before reaching it.
+	fromThreads := s.threadComments.take()
+	processed, skipped, withPost := 0, 0, 0
+	defer func() {
+		if processed > 0 {
+			logCycle(ctx, "Recent comments: %d new, %d already seen, %d already fetched with their post", processed, skipped, withPost)
+		} else {
+			logCycleDebug(ctx, "Recent comments: %d new, %d already seen, %d already fetched with their post", processed, skipped, withPost)
+		}
+	}()
+
 	for _, comment := range comments {
-		if s.seenMessages[comment.ID] {
+		if s.stopping() {
+			return
+		}
+		if comment.CreatedAt.After(s.commentsSince) {
+			s.commentsSince = comment.CreatedAt
+		}
+		if fetchedUnchanged(fromThreads, comment) {
+			withPost++
 			continue
 		}
-
-		*newMessages++
-		*newComments++
+		if s.tooOld(comment.CreatedAt) {
+			*tooOld++
+			continue
+		}
+		if !s.trackComment(ctx, comment, newMessages, newComments) {
+			skipped++
+			continue
+		}
+		processed++
 
 		// Convert to message and save
 		msg := s.CommentToMessage(comment, "")
 		if err := s.SaveMessage(ctx, msg); err != nil {
 			*saveErrors++
 		}
+		s.recordRawPayload(ctx, comment.ID, "comment", comment.raw)
 
 		// Scan for API keys
 		findings := s.ScanComment(comment, "", "")
-		for _, finding := range findings {
-			if err := s.SaveFinding(ctx, finding); err != nil {
+		s.processFindings(ctx, findings, totalFindings, saveErrors)
+	}
+}
+
+// processFindings saves findings and dispatches the saved ones to the notifiers
+func (s *Scanner) processFindings(ctx context.Context, findings []APIKeyFinding, totalFindings *int, saveErrors *int) {
+	for _, finding := range findings {
+		if finding.Confidence < s.minConfidence {
+			if err := s.SaveQuarantinedFinding(ctx, finding); err != nil {
 				*saveErrors++
-			} else {
-				*totalFindings++
 			}
+			continue
 		}
-
-		s.seenMessages[comment.ID] = true
+		if err := s.SaveFinding(ctx, finding); err != nil {
+			*saveErrors++
+			continue
+		}
+		*totalFindings++
+		s.activity.addFinding(finding.APIKeyType)
+		recordSubmoltFinding(ctx, finding.SubmoltName)
+		detectionLatencySeconds.Observe(finding.DetectionLatency.Seconds())
+		s.notify(ctx, finding)
 	}
 }
 
@@ -933,11 +2748,66 @@ func (s *Scanner) Close() error {
 	return s.clickhouseConn.Close()
 }
 
+// maskKey hides the middle of a key so it can be shown in alerts and logs
+func maskKey(key string) string {
+	runes := []rune(key)
+	if len(runes) <= 12 {
+		return string(runes[:min(len(runes), 2)]) + "********"
+	}
+	return string(runes[:4]) + "********" + string(runes[len(runes)-4:])
+}
+
+// hashKey returns the hex SHA-256 of a key, used as a stable fingerprint
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// truncateString shortens s to at most maxLen runes, preferring to cut at the
+// last word boundary, and appends an ellipsis only when something was dropped.
 func truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
+	if utf8.RuneCountInString(s) <= maxLen {
+		return s
+	}
+
+	cut, n := 0, 0
+	for i := range s {
+		if n == maxLen {
+			cut = i
+			break
+		}
+		n++
+	}
+	truncated := s[:cut]
+
+	// Only back off to a word boundary if it keeps most of the text; CJK and
+	// other unspaced scripts fall through to the plain rune cut.
+	if idx := strings.LastIndexFunc(truncated, unicode.IsSpace); idx > len(truncated)/2 {
+		truncated = truncated[:idx]
+	}
+
+	return strings.TrimRightFunc(truncated, unicode.IsSpace) + "..."
+}
+
+// truncateToRuneBoundary cuts s to at most maxBytes bytes without splitting
+// a multibyte UTF-8 sequence.
+func truncateToRuneBoundary(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
 		return s
 	}
-	return s[:maxLen] + "..."
+	for maxBytes > 0 && !utf8.RuneStart(s[maxBytes]) {
+		maxBytes--
+	}
+	return s[:maxBytes]
+}
+
+// debugEnabled turns on logDebug output (LOG_LEVEL=debug)
+var debugEnabled bool
+
+func logDebug(format string, args ...interface{}) {
+	if debugEnabled {
+		log.Printf("Debug: "+format, args...)
+	}
 }
 
 func getEnvOrDefault(key, defaultValue string) string {
@@ -947,6 +2817,53 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getSecretEnv reads a secret from the file named by key+"_FILE" (e.g. a
+// mounted Kubernetes secret), falling back to the key variable itself.
+// Trailing newlines in the file are stripped.
+func getSecretEnv(key string) (string, error) {
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return os.Getenv(key), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s_FILE: %w", key, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func getEnvBoolOrDefault(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvFloatOrDefault(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvIntOrDefault(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
 func main() {
 	scanner, err := NewScanner()
 	if err != nil {
@@ -968,6 +2885,24 @@ func main() {
 		cancel()
 	}()
 
+	// One-off commands, e.g. "scanner reprocess"
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		if err := runCommand(ctx, scanner, os.Args[1], os.Args[2:]); err != nil {
+			log.Fatalf("Command %s failed: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	since := flag.String("since", "", "only fetch recent comments newer than this RFC 3339 time or duration ago (default: newest stored comment)")
+	flag.Parse()
+	if *since != "" {
+		t, err := parseSince(*since)
+		if err != nil {
+			log.Fatalf("Invalid -since: %v", err)
+		}
+		scanner.commentsSince = t
+	}
+
 	// Run the scanner
 	if err := scanner.Run(ctx); err != nil {
 		log.Fatalf("Scanner error: %v", err)
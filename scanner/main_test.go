@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestTruncateString(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		maxLen int
+		want   string
+	}{
+		{name: "short text untouched", in: "hello world", maxLen: 20, want: "hello world"},
+		{name: "exact length untouched", in: "héllo", maxLen: 5, want: "héllo"},
+		{name: "cut at a word boundary", in: "the quick brown fox jumps", maxLen: 18, want: "the quick brown..."},
+		{name: "no space in the second half cuts on the rune", in: "a " + strings.Repeat("b", 20), maxLen: 10, want: "a bbbbbbbb..."},
+		{name: "emoji at the cut point", in: "keys 🔑🔑🔑🔑🔑🔑", maxLen: 7, want: "keys 🔑🔑..."},
+		{name: "CJK without spaces", in: "秘密鍵が漏洩しました", maxLen: 4, want: "秘密鍵が..."},
+		{name: "CJK after a space keeps the rune cut", in: "ok 秘密鍵が漏洩しました", maxLen: 6, want: "ok 秘密鍵..."},
+		{name: "accented letters count as one rune", in: "café café café", maxLen: 7, want: "café..."},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := truncateString(tc.in, tc.maxLen)
+			if got != tc.want {
+				t.Errorf("truncateString(%q, %d) = %q, want %q", tc.in, tc.maxLen, got, tc.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncateString(%q, %d) = %q is not valid UTF-8", tc.in, tc.maxLen, got)
+			}
+		})
+	}
+}
+
+func TestTruncateToRuneBoundary(t *testing.T) {
+	// "🔑" is 4 bytes, so every cut inside it backs off to before it
+	for maxBytes := 1; maxBytes <= 4; maxBytes++ {
+		if got := truncateToRuneBoundary("a🔑", maxBytes); got != "a" {
+			t.Errorf("truncateToRuneBoundary(%q, %d) = %q, want %q", "a🔑", maxBytes, got, "a")
+		}
+	}
+	if got := truncateToRuneBoundary("a🔑", 5); got != "a🔑" {
+		t.Errorf("truncateToRuneBoundary(%q, 5) = %q, want it untouched", "a🔑", got)
+	}
+}
+
+func TestNewScannerRejectsInjectedIdentifiers(t *testing.T) {
+	tests := []struct {
+		name, env, value, wantErr string
+	}{
+		{"database with a statement", "CLICKHOUSE_DATABASE", "moltbook; DROP TABLE messages", "invalid CLICKHOUSE_DATABASE"},
+		{"database with a backtick", "CLICKHOUSE_DATABASE", "moltbook`.x", "invalid CLICKHOUSE_DATABASE"},
+		{"database with a comment", "CLICKHOUSE_DATABASE", "moltbook--", "invalid CLICKHOUSE_DATABASE"},
+		{"table prefix with a dot", "TABLE_PREFIX", "other_db.", "invalid TABLE_PREFIX"},
+		{"table prefix with a space", "TABLE_PREFIX", "x UNION SELECT", "invalid TABLE_PREFIX"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Validation happens before any connection is opened
+			t.Setenv("MOLTBOOK_API_KEY", "moltbook_sk_test")
+			t.Setenv(tc.env, tc.value)
+			s, err := NewScanner()
+			if err == nil {
+				s.Close()
+				t.Fatalf("NewScanner with %s=%q succeeded, want an error", tc.env, tc.value)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("NewScanner with %s=%q: %v, want %q", tc.env, tc.value, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuoteIdent(t *testing.T) {
+	for in, want := range map[string]string{
+		"moltbook":   "`moltbook`",
+		"a`; DROP x": "`a\\`; DROP x`",
+		`a\`:         "`a\\\\`",
+	} {
+		if got := quoteIdent(in); got != want {
+			t.Errorf("quoteIdent(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestScanTruncationLoggedOncePerMessage(t *testing.T) {
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	s := newPatternScanner()
+	s.maxScanBytes = 64
+	// Encoded text makes ScanText scan decoded variants as well
+	content := "key moltbook_sk_" + strings.Repeat("a1", 12) + " %6D%6F " + strings.Repeat("log line ", 20)
+	s.ScanPost(MoltbookPost{ID: "p1", Title: "dump", Content: content})
+	s.ScanComment(MoltbookComment{ID: "c1", PostID: "p1", Content: content}, "dump", "general")
+	s.ScanPost(MoltbookPost{ID: "p2", Title: "short", Content: "nothing to see"})
+
+	if n := strings.Count(logs.String(), "truncated for scanning"); n != 2 {
+		t.Errorf("logged %d truncation warnings for one long post and one long comment, want 2:\n%s", n, logs.String())
+	}
+}
+
+func TestMaskKey(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"sk-abcdefghijklmnop", "sk-a********mnop"},
+		{"short", "sh********"},
+		{"x", "x********"},
+		{"", "********"},
+		// Cuts fall on rune boundaries in both branches
+		{"é", "é********"},
+		{"pässwörd", "pä********"},
+		{"postgres://üser:pässwörd@höst/db", "post********t/db"},
+		{"ключ-секрет-доступа", "ключ********тупа"},
+	}
+	for _, tc := range tests {
+		got := maskKey(tc.in)
+		if got != tc.want {
+			t.Errorf("maskKey(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("maskKey(%q) = %q is not valid UTF-8", tc.in, got)
+		}
+	}
+}
+
+func TestScannerClockStampsMessagesAndAges(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	conn := &fakeConn{}
+	s := &Scanner{
+		clickhouseConn: conn,
+		writeLimiter:   newWriteLimiter(1),
+		maxMessageAge:  time.Hour,
+		clock:          func() time.Time { return now },
+	}
+
+	if s.tooOld(now.Add(-30 * time.Minute)) {
+		t.Error("a message 30 minutes old by the scanner clock is too old")
+	}
+	if !s.tooOld(now.Add(-2 * time.Hour)) {
+		t.Error("a message 2 hours old by the scanner clock is not too old")
+	}
+
+	msg := s.PostToMessage(MoltbookPost{ID: "p1", CreatedAt: now.Add(-time.Minute)})
+	if err := s.saveMessage(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	inserts := conn.inserts("messages")
+	if len(inserts) != 1 {
+		t.Fatalf("%d message inserts, want 1", len(inserts))
+	}
+	if !strings.Contains(inserts[0].query, "scanned_at") || !slices.Contains(inserts[0].args, any(now)) {
+		t.Errorf("message saved without scanned_at from the clock: %v", inserts[0].args)
+	}
+}
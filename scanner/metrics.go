@@ -0,0 +1,307 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metric is anything that can render itself in the Prometheus text format
+type metric interface {
+	writePrometheus(w io.Writer)
+}
+
+// metricsRegistry holds the process-wide metrics served on /metrics
+type metricsRegistry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+var defaultRegistry = &metricsRegistry{}
+
+func (r *metricsRegistry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// ServeHTTP renders every registered metric in the Prometheus text format
+func (r *metricsRegistry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, m := range r.metrics {
+		m.writePrometheus(w)
+	}
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	mu    sync.Mutex
+	name  string
+	help  string
+	value float64
+}
+
+// newCounter creates a counter and registers it in the default registry
+func newCounter(name, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	defaultRegistry.register(c)
+	return c
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds v, which must not be negative, to the counter
+func (c *Counter) Add(v float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.value += v
+}
+
+func (c *Counter) writePrometheus(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %s\n", c.name, c.help, c.name, c.name, formatFloat(c.value))
+}
+
+// LabeledCounter is a set of counters partitioned by the value of one label
+type LabeledCounter struct {
+	mu     sync.Mutex
+	name   string
+	help   string
+	label  string
+	values map[string]float64
+}
+
+// newLabeledCounter creates a labeled counter and registers it in the
+// default registry
+func newLabeledCounter(name, help, label string) *LabeledCounter {
+	c := &LabeledCounter{name: name, help: help, label: label, values: make(map[string]float64)}
+	defaultRegistry.register(c)
+	return c
+}
+
+// Inc adds one to the counter for labelValue
+func (c *LabeledCounter) Inc(labelValue string) {
+	c.Add(labelValue, 1)
+}
+
+// Add adds v, which must not be negative, to the counter for labelValue.
+// Adding 0 makes the label value show up in the output at zero. A nil
+// counter counts nothing.
+func (c *LabeledCounter) Add(labelValue string, v float64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[labelValue] += v
+}
+
+// snapshot returns the current value of each label value
+func (c *LabeledCounter) snapshot() map[string]float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	values := make(map[string]float64, len(c.values))
+	for v, n := range c.values {
+		values[v] = n
+	}
+	return values
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (c *LabeledCounter) writePrometheus(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	values := make([]string, 0, len(c.values))
+	for v := range c.values {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	for _, v := range values {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %s\n", c.name, c.label, labelValueEscaper.Replace(v), formatFloat(c.values[v]))
+	}
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	mu    sync.Mutex
+	name  string
+	help  string
+	value float64
+}
+
+// newGauge creates a gauge and registers it in the default registry
+func newGauge(name, help string) *Gauge {
+	g := &Gauge{name: name, help: help}
+	defaultRegistry.register(g)
+	return g
+}
+
+// Set sets the gauge to v
+func (g *Gauge) Set(v float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.value = v
+}
+
+func (g *Gauge) writePrometheus(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", g.name, g.help, g.name, g.name, formatFloat(g.value))
+}
+
+// Histogram counts observations into cumulative buckets
+type Histogram struct {
+	mu      sync.Mutex
+	name    string
+	help    string
+	buckets []float64 // upper bounds, ascending
+	counts  []uint64  // per bucket, non-cumulative
+	sum     float64
+	count   uint64
+}
+
+// newHistogram creates a histogram and registers it in the default registry
+func newHistogram(name, help string, buckets []float64) *Histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		buckets: sorted,
+		counts:  make([]uint64, len(sorted)),
+	}
+	defaultRegistry.register(h)
+	return h
+}
+
+// Observe records a single value
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *Histogram) writePrometheus(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	var cumulative uint64
+	for i, upper := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, formatFloat(upper), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, h.count)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var detectionLatencySeconds = newHistogram(
+	"moltbook_detection_latency_seconds",
+	"Time between a message being posted and a key in it being detected.",
+	[]float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600, 21600, 86400},
+)
+
+var scanMatchTimeoutsTotal = newCounter(
+	"moltbook_scan_match_timeouts_total",
+	"Messages whose pattern matching exceeded SCAN_MATCH_TIMEOUT and was abandoned.",
+)
+
+var scanTruncatedTotal = newCounter(
+	"moltbook_scan_truncated_total",
+	"Posts and comments longer than MAX_SCAN_BYTES, of which only the start was scanned.",
+)
+
+var (
+	seenMessagesGauge = newGauge(
+		"moltbook_seen_messages",
+		"Distinct message IDs currently held in the seen set, however many versions of each were scanned.",
+	)
+	seenMessagesAddedTotal = newCounter(
+		"moltbook_seen_messages_added_total",
+		"Message IDs added to the seen set.",
+	)
+)
+
+var findingsQuarantinedTotal = newCounter(
+	"moltbook_findings_quarantined_total",
+	"Findings below MIN_CONFIDENCE saved to findings_quarantine instead of alerted.",
+)
+
+var (
+	scanFailuresTotal = newCounter(
+		"moltbook_scan_failures_total",
+		"Scan cycles that returned an error, including those above MAX_SAVE_ERROR_RATE.",
+	)
+	scanLoopsFailing = newGauge(
+		"moltbook_scan_loops_failing",
+		"Scan loops whose last cycle failed.",
+	)
+	saveErrorRate = newGauge(
+		"moltbook_save_error_rate",
+		"Fraction of ClickHouse saves that failed in the last scan cycle with saves.",
+	)
+)
+
+var (
+	findingsBySubmoltTotal = newLabeledCounter(
+		"moltbook_findings_by_submolt_total",
+		"Findings saved, by the submolt of the message they were found in.",
+		"submolt",
+	)
+	submoltSpikesTotal = newCounter(
+		"moltbook_submolt_spikes_total",
+		"Scan cycles in which a submolt's findings exceeded SUBMOLT_SPIKE_MULTIPLE times its baseline.",
+	)
+)
+
+var patternMatchesTotal = newLabeledCounter(
+	"moltbook_pattern_matches_total",
+	"Keys reported by each detection pattern, including patterns that never matched.",
+	"pattern",
+)
+
+// declarePatternMetrics exports every active pattern in
+// moltbook_pattern_matches_total from the start, so dead patterns show up
+// at zero and the label set stays bounded to the known patterns
+func declarePatternMetrics(patterns []keyPattern, contextPatterns []contextPattern, piiPatterns []piiPattern, keyTypes *keyTypeFilter) {
+	for _, p := range patterns {
+		patternMatchesTotal.Add(p.name, 0)
+	}
+	for _, p := range contextPatterns {
+		patternMatchesTotal.Add(p.keyType, 0)
+	}
+	for _, name := range []string{"AWSKeyPair", "DatabaseConnectionString", "AuthorizationHeader"} {
+		if keyTypes.allows(name) {
+			patternMatchesTotal.Add(name, 0)
+		}
+	}
+	for _, p := range piiPatterns {
+		patternMatchesTotal.Add(p.piiType, 0)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import "strings"
+
+// canonicalKeyPrefixes are the fixed prefixes providers issue keys with, in
+// the casing they issue them. Patterns are matched case-insensitively, so a
+// key can be captured as e.g. "GHP_..." in one post and "ghp_..." in
+// another; normalizeKey restores the prefix so both dedupe and hash alike.
+// Only the prefix is rewritten: the body after it is case-sensitive for
+// every provider listed here.
+var canonicalKeyPrefixes = []string{
+	// OpenAI, Anthropic
+	"sk-proj-", "sk-ant-", "sk-",
+	// Google
+	"AIza", "GOCSPX-",
+	// GitHub
+	"ghp_", "gho_", "ghu_", "ghs_", "ghr_", "github_pat_",
+	// Stripe
+	"sk_live_", "sk_test_", "rk_live_", "rk_test_",
+	// SendGrid, Slack, Mailgun
+	"SG.", "xoxb-", "xoxp-", "xoxa-", "key-",
+	// Supabase, Moltbook
+	"sbp_", "moltbook_sk_",
+	// DigitalOcean
+	"dop_v1_", "doo_v1_", "dor_v1_",
+	// Package registries
+	"npm_", "pypi-", "dckr_pat_",
+	// Shopify, Square
+	"shpat_", "shpca_", "shppa_", "shpss_", "sq0atp-", "sq0csp-", "EAAA",
+	// Atlassian, Linear, Notion
+	"ATATT3", "lin_api_", "secret_", "ntn_",
+}
+
+// normalizeKey returns the canonical form of a captured key:
+//   - keys with a prefix from canonicalKeyPrefixes get that prefix's casing
+//   - AWS access key IDs are upper-cased, as the whole ID is upper case
+//   - private key headers are upper-cased; they hold no secret material
+//
+// Everything else (generic matches, Discord, Telegram, Azure, JWTs and the
+// keyword-gated formats) is case-sensitive throughout and returned as is.
+func normalizeKey(key string) string {
+	lower := strings.ToLower(key)
+	switch {
+	case len(key) == 20 && (strings.HasPrefix(lower, "akia") || strings.HasPrefix(lower, "asia")):
+		return strings.ToUpper(key)
+	case strings.HasPrefix(lower, "-----begin"):
+		return strings.ToUpper(key)
+	}
+	for _, prefix := range canonicalKeyPrefixes {
+		if len(key) > len(prefix) && strings.EqualFold(key[:len(prefix)], prefix) {
+			return prefix + key[len(prefix):]
+		}
+	}
+	return key
+}
@@ -0,0 +1,695 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Notifier delivers a finding to an external alerting system
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, finding APIKeyFinding) error
+}
+
+// flushingNotifier is implemented by notifiers that deliver asynchronously
+// and may hold undelivered findings at shutdown
+type flushingNotifier interface {
+	Notifier
+	// Flush delivers queued findings until the queue is empty or ctx ends
+	Flush(ctx context.Context)
+}
+
+// newNotifiersFromEnv builds the notifiers enabled by environment variables
+func newNotifiersFromEnv(httpClient *http.Client) ([]Notifier, error) {
+	var notifiers []Notifier
+
+	if routingKey := os.Getenv("PAGERDUTY_ROUTING_KEY"); routingKey != "" {
+		notifiers = append(notifiers, &PagerDutyNotifier{
+			routingKey: routingKey,
+			eventsURL:  "https://events.pagerduty.com/v2/enqueue",
+			httpClient: httpClient,
+		})
+	}
+
+	if webhookURL := os.Getenv("WEBHOOK_URL"); webhookURL != "" {
+		headers, err := parseHeaderList(os.Getenv("WEBHOOK_HEADERS"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid WEBHOOK_HEADERS: %w", err)
+		}
+		var tmpl *template.Template
+		if path := os.Getenv("WEBHOOK_TEMPLATE_FILE"); path != "" {
+			if tmpl, err = loadWebhookTemplate(path); err != nil {
+				return nil, err
+			}
+			log.Printf("Webhook bodies rendered from template %s", path)
+		}
+		notifiers = append(notifiers, newWebhookNotifier(
+			webhookURL,
+			os.Getenv("WEBHOOK_SECRET"),
+			headers,
+			tmpl,
+			getEnvIntOrDefault("WEBHOOK_QUEUE_SIZE", 100),
+			httpClient,
+		))
+	}
+
+	botToken := os.Getenv("TELEGRAM_BOT_TOKEN")
+	chatID := os.Getenv("TELEGRAM_CHAT_ID")
+	if botToken != "" && chatID != "" {
+		notifiers = append(notifiers, &TelegramNotifier{
+			botToken:   botToken,
+			chatID:     chatID,
+			httpClient: httpClient,
+		})
+	}
+
+	for _, n := range notifiers {
+		log.Printf("Notifier enabled: %s", n.Name())
+	}
+
+	return notifiers, nil
+}
+
+// parseHeaderList parses comma-separated Name=Value pairs
+func parseHeaderList(raw string) (http.Header, error) {
+	headers := http.Header{}
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("expected Name=Value, got %q", pair)
+		}
+		headers.Add(name, strings.TrimSpace(value))
+	}
+	return headers, nil
+}
+
+// flushNotifiers gives the notify queue, then asynchronous notifiers, until
+// ctx ends to deliver whatever they still have queued
+func (s *Scanner) flushNotifiers(ctx context.Context) {
+	if s.notifyQueue != nil {
+		s.notifyQueue.wait(ctx)
+	}
+	for _, n := range s.notifiers {
+		if f, ok := n.(flushingNotifier); ok {
+			f.Flush(ctx)
+		}
+	}
+}
+
+// notifyThrottle suppresses repeat notifications for the same key
+type notifyThrottle struct {
+	mu       sync.Mutex
+	interval time.Duration
+	lastSent map[string]time.Time // key hash -> last notification time
+}
+
+func newNotifyThrottle(interval time.Duration) *notifyThrottle {
+	return &notifyThrottle{
+		interval: interval,
+		lastSent: make(map[string]time.Time),
+	}
+}
+
+// allow reports whether a notification for keyHash may be sent now and, if
+// so, records it as sent.
+func (t *notifyThrottle) allow(keyHash string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if last, ok := t.lastSent[keyHash]; ok && now.Sub(last) < t.interval {
+		return false
+	}
+	t.lastSent[keyHash] = now
+	return true
+}
+
+// release undoes an allow at now whose notification was never sent
+func (t *notifyThrottle) release(keyHash string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.lastSent[keyHash] == now {
+		delete(t.lastSent, keyHash)
+	}
+}
+
+// notifyDedup remembers which key fingerprints have been notified, persisted
+// in the notified_fingerprints table so restarts don't re-alert. A key
+// notified more than ttl ago may alert again.
+type notifyDedup struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	notified map[string]time.Time // key hash -> last notification time
+}
+
+func newNotifyDedup(ttl time.Duration) *notifyDedup {
+	return &notifyDedup{
+		ttl:      ttl,
+		notified: make(map[string]time.Time),
+	}
+}
+
+// claim reports whether keyHash has not been notified within ttl and, if
+// so, records it as notified at now
+func (d *notifyDedup) claim(keyHash string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if last, ok := d.notified[keyHash]; ok && now.Sub(last) < d.ttl {
+		return false
+	}
+	d.notified[keyHash] = now
+	return true
+}
+
+// release undoes a claim at now whose notification was never sent
+func (d *notifyDedup) release(keyHash string, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.notified[keyHash] == now {
+		delete(d.notified, keyHash)
+	}
+}
+
+// LoadNotifiedFingerprints loads the fingerprints notified within the dedup
+// TTL from the database
+func (s *Scanner) LoadNotifiedFingerprints(ctx context.Context) error {
+	if s.notifyDedup == nil {
+		return nil
+	}
+
+	query := fmt.Sprintf(`SELECT key_sha256, max(notified_at) FROM %s
+		WHERE notified_at >= ? GROUP BY key_sha256`, s.table("notified_fingerprints"))
+	rows, err := s.clickhouseConn.Query(ctx, query, s.now().Add(-s.notifyDedup.ttl))
+	if err != nil {
+		return fmt.Errorf("failed to query notified fingerprints: %w", err)
+	}
+	defer rows.Close()
+
+	s.notifyDedup.mu.Lock()
+	defer s.notifyDedup.mu.Unlock()
+	for rows.Next() {
+		var keyHash string
+		var notifiedAt time.Time
+		if err := rows.Scan(&keyHash, &notifiedAt); err != nil {
+			return fmt.Errorf("failed to scan notified fingerprint: %w", err)
+		}
+		s.notifyDedup.notified[keyHash] = notifiedAt
+	}
+
+	log.Printf("Loaded %d previously notified fingerprints", len(s.notifyDedup.notified))
+	return rows.Err()
+}
+
+// saveNotifiedFingerprint persists that keyHash was notified at notifiedAt
+func (s *Scanner) saveNotifiedFingerprint(ctx context.Context, keyHash string, notifiedAt time.Time) error {
+	query := fmt.Sprintf(`INSERT INTO %s (key_sha256, notified_at) VALUES (?, ?)`, s.table("notified_fingerprints"))
+
+	if err := s.writeLimiter.acquire(ctx); err != nil {
+		return err
+	}
+	defer s.writeLimiter.release()
+
+	return s.clickhouseConn.Exec(ctx, query, keyHash, notifiedAt)
+}
+
+// notify sends a finding to the notifiers ALERT_ROUTES routes its severity
+// to. Keys already notified within the throttle interval, or within the
+// dedup TTL (across restarts), are skipped. Failures are logged and never
+// interrupt the scan.
+func (s *Scanner) notify(ctx context.Context, finding APIKeyFinding) {
+	if len(s.notifiers) == 0 {
+		return
+	}
+	s.claimAndDispatch(ctx, finding)
+}
+
+// claimAndDispatch dispatches a finding if ALERT_ROUTES sends its severity
+// to a notifier and the throttle and dedup allow its key now, reporting
+// whether they did and whether it was queued. A finding routed nowhere
+// claims nothing, so its key still alerts if the routes are widened or it
+// is found again at a routed severity. The claim is only persisted to
+// notified_fingerprints once the finding is queued; a finding dropped on a
+// full queue gives its claim back, so the key alerts the next time it is
+// found.
+func (s *Scanner) claimAndDispatch(ctx context.Context, finding APIKeyFinding) (claimed, queued bool) {
+	if len(s.routedNotifiers(finding.Severity)) == 0 {
+		return false, false
+	}
+	keyHash := finding.keySHA256()
+	now := s.now()
+	if !s.notifyThrottle.allow(keyHash, now) {
+		return false, false
+	}
+	if s.notifyDedup != nil && !s.notifyDedup.claim(keyHash, now) {
+		s.notifyThrottle.release(keyHash, now)
+		return false, false
+	}
+
+	if !s.dispatch(ctx, finding) {
+		s.notifyThrottle.release(keyHash, now)
+		if s.notifyDedup != nil {
+			s.notifyDedup.release(keyHash, now)
+		}
+		return true, false
+	}
+
+	if s.notifyDedup != nil {
+		if err := s.saveNotifiedFingerprint(ctx, keyHash, now); err != nil {
+			logCycle(ctx, "Warning: failed to save notified fingerprint: %v", err)
+		}
+	}
+	return true, true
+}
+
+// dispatch queues a finding for the notifiers and reports whether the
+// queue took it. A scanner without a notify queue sends it right away.
+func (s *Scanner) dispatch(ctx context.Context, finding APIKeyFinding) bool {
+	if s.notifyQueue == nil {
+		s.sendToNotifiers(ctx, finding)
+		return true
+	}
+	if err := s.notifyQueue.enqueue(ctx, finding); err != nil {
+		logCycle(ctx, "Warning: %v, dropping finding for post %s; resend it with POST /notify/replay", err, finding.PostID)
+		return false
+	}
+	return true
+}
+
+// routedNotifiers returns the notifiers ALERT_ROUTES sends findings of
+// severity to
+func (s *Scanner) routedNotifiers(severity string) []Notifier {
+	var routed []Notifier
+	for _, n := range s.notifiers {
+		if s.alertRoutes.allows(severity, n.Name()) {
+			routed = append(routed, n)
+		}
+	}
+	return routed
+}
+
+// sendToNotifiers sends a finding to the notifiers ALERT_ROUTES routes its
+// severity to, logging failures
+func (s *Scanner) sendToNotifiers(ctx context.Context, finding APIKeyFinding) {
+	for _, n := range s.routedNotifiers(finding.Severity) {
+		if err := n.Notify(ctx, finding); err != nil {
+			logCycle(ctx, "Warning: %s notification failed for post %s: %v", n.Name(), finding.PostID, err)
+		}
+	}
+}
+
+// replayResult counts what ReplayNotifications did
+type replayResult struct {
+	Findings   int `json:"findings"`
+	Dispatched int `json:"dispatched"`
+	Skipped    int `json:"skipped"`
+	Dropped    int `json:"dropped"`
+}
+
+// ReplayNotifications sends the findings found in [since, until) to the
+// notifiers again, oldest first, e.g. after a webhook outage. Unless force
+// is set, keys the throttle or dedup would skip are skipped as they would
+// be on a live finding. Findings ALERT_ROUTES sends to no notifier are
+// skipped either way. Replayed findings only carry the masked key.
+func (s *Scanner) ReplayNotifications(ctx context.Context, since, until time.Time, force bool) (replayResult, error) {
+	var result replayResult
+	if len(s.notifiers) == 0 {
+		return result, errors.New("no notifiers are configured")
+	}
+	records, err := s.QueryFindings(ctx, FindingFilter{Since: since, Until: until, After: &FindingCursor{}})
+	if err != nil {
+		return result, err
+	}
+
+	result.Findings = len(records)
+	for _, rec := range records {
+		finding := s.replayedFinding(rec)
+		claimed, queued := true, false
+		switch {
+		case !force:
+			claimed, queued = s.claimAndDispatch(ctx, finding)
+		case len(s.routedNotifiers(finding.Severity)) == 0:
+			// Forcing bypasses the throttle and dedup, not ALERT_ROUTES
+			claimed = false
+		default:
+			queued = s.dispatch(ctx, finding)
+		}
+		switch {
+		case !claimed:
+			result.Skipped++
+		case !queued:
+			result.Dropped++
+		default:
+			result.Dispatched++
+		}
+	}
+	return result, nil
+}
+
+// replayedFinding rebuilds the finding a record was saved from, with its
+// key and content masked as in DLQ_FILE and its fingerprint kept
+func (s *Scanner) replayedFinding(rec FindingRecord) APIKeyFinding {
+	return APIKeyFinding{
+		PostID:        rec.PostID,
+		MessageID:     rec.MessageID,
+		PostTitle:     rec.PostTitle,
+		AuthorName:    rec.AuthorName,
+		SubmoltName:   rec.SubmoltName,
+		APIKey:        rec.APIKeyMasked,
+		APIKeyType:    rec.APIKeyType,
+		Severity:      rec.Severity,
+		Encoding:      rec.Encoding,
+		Content:       maskKeys(s.maskSecrets(rec.Content), []string{rec.apiKey}),
+		PostURL:       rec.PostURL,
+		FoundAt:       rec.FoundAt,
+		PostCreatedAt: rec.PostCreatedAt,
+		SourceURL:     rec.SourceURL,
+		Location:      rec.Location,
+		keyHash:       rec.KeySHA256,
+	}
+}
+
+// postJSON POSTs payload as JSON and treats any non-2xx status as an error
+func postJSON(ctx context.Context, client *http.Client, endpoint string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+	return postBody(ctx, client, endpoint, body, nil)
+}
+
+// postBody POSTs an already-encoded JSON body with optional extra headers
+func postBody(ctx context.Context, client *http.Client, endpoint string, body []byte, headers http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range headers {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// Drop the URL from the error: webhook and bot URLs embed credentials
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("endpoint returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+// PagerDutyNotifier triggers PagerDuty incidents for critical findings
+type PagerDutyNotifier struct {
+	routingKey string
+	eventsURL  string
+	httpClient *http.Client
+}
+
+func (p *PagerDutyNotifier) Name() string {
+	return "pagerduty"
+}
+
+// Notify sends a trigger event for critical findings only. The dedup key is
+// derived from the key hash so repeated detections of the same secret
+// coalesce into a single incident.
+func (p *PagerDutyNotifier) Notify(ctx context.Context, finding APIKeyFinding) error {
+	if finding.Severity != SeverityCritical {
+		return nil
+	}
+
+	event := map[string]interface{}{
+		"routing_key":  p.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    "moltbook-" + finding.keySHA256(),
+		"payload": map[string]interface{}{
+			"summary":  fmt.Sprintf("Exposed %s key on Moltbook by %s in %s", finding.APIKeyType, finding.AuthorName, finding.SubmoltName),
+			"source":   "moltbook-scanner",
+			"severity": "critical",
+			"custom_details": map[string]string{
+				"api_key":    maskKey(finding.APIKey),
+				"key_type":   finding.APIKeyType,
+				"author":     finding.AuthorName,
+				"submolt":    finding.SubmoltName,
+				"post_title": finding.PostTitle,
+				"post_url":   finding.PostURL,
+			},
+		},
+		"links": []map[string]string{
+			{"href": finding.PostURL, "text": "Moltbook post"},
+		},
+	}
+
+	return postJSON(ctx, p.httpClient, p.eventsURL, event)
+}
+
+// WebhookNotifier POSTs findings as JSON to a generic HTTP endpoint. Findings
+// are queued and delivered by a background worker so a slow receiver never
+// stalls a scan; when the queue is full new findings are dropped.
+type WebhookNotifier struct {
+	url        string
+	secret     string
+	headers    http.Header
+	template   *template.Template // WEBHOOK_TEMPLATE_FILE, nil for webhookPayload JSON
+	httpClient *http.Client
+	queue      chan APIKeyFinding
+}
+
+// webhookMaxAttempts is how many times a delivery is tried before dropping it
+const webhookMaxAttempts = 3
+
+var webhookDroppedTotal = newCounter(
+	"moltbook_webhook_dropped_total",
+	"Findings dropped by the webhook notifier because the queue was full or delivery kept failing.",
+)
+
+func newWebhookNotifier(url, secret string, headers http.Header, tmpl *template.Template, queueSize int, httpClient *http.Client) *WebhookNotifier {
+	wh := &WebhookNotifier{
+		url:        url,
+		secret:     secret,
+		headers:    headers,
+		template:   tmpl,
+		httpClient: httpClient,
+		queue:      make(chan APIKeyFinding, max(queueSize, 1)),
+	}
+	go wh.worker()
+	return wh
+}
+
+// webhookPayload is the JSON body sent by WebhookNotifier. The raw key is
+// never included, only its masked form and SHA-256 fingerprint.
+//
+// When WEBHOOK_SECRET is set, each request is signed:
+//
+//	X-Signature-Timestamp: <unix seconds>
+//	X-Signature: sha256=<hex HMAC-SHA256(secret, timestamp + "." + body)>
+//
+// Receivers should recompute the HMAC over the exact request body, compare
+// it in constant time, and reject timestamps older than a few minutes to
+// prevent replay.
+type webhookPayload struct {
+	APIKeyMasked  string    `json:"api_key_masked"`
+	KeySHA256     string    `json:"key_sha256"`
+	APIKeyType    string    `json:"api_key_type"`
+	Severity      string    `json:"severity"`
+	PostID        string    `json:"post_id"`
+	PostTitle     string    `json:"post_title"`
+	AuthorName    string    `json:"author_name"`
+	SubmoltName   string    `json:"submolt_name"`
+	PostURL       string    `json:"post_url"`
+	FoundAt       time.Time `json:"found_at"`
+	PostCreatedAt time.Time `json:"post_created_at"`
+	SourceURL     string    `json:"source_url,omitempty"`
+	Origin        string    `json:"origin,omitempty"`
+	Location      string    `json:"location,omitempty"`
+}
+
+func newWebhookPayload(finding APIKeyFinding) webhookPayload {
+	return webhookPayload{
+		APIKeyMasked:  maskKey(finding.APIKey),
+		KeySHA256:     finding.keySHA256(),
+		APIKeyType:    finding.APIKeyType,
+		Severity:      finding.Severity,
+		PostID:        finding.PostID,
+		PostTitle:     finding.PostTitle,
+		AuthorName:    finding.AuthorName,
+		SubmoltName:   finding.SubmoltName,
+		PostURL:       finding.PostURL,
+		FoundAt:       finding.FoundAt,
+		PostCreatedAt: finding.PostCreatedAt,
+		SourceURL:     finding.SourceURL,
+		Origin:        finding.Origin,
+		Location:      finding.Location,
+	}
+}
+
+func (wh *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+// Notify queues the finding for delivery without blocking
+func (wh *WebhookNotifier) Notify(ctx context.Context, finding APIKeyFinding) error {
+	select {
+	case wh.queue <- finding:
+		return nil
+	default:
+		webhookDroppedTotal.Inc()
+		return fmt.Errorf("queue full (%d), dropping finding", cap(wh.queue))
+	}
+}
+
+// Flush delivers queued findings until the queue is empty or ctx ends
+func (wh *WebhookNotifier) Flush(ctx context.Context) {
+	for {
+		select {
+		case finding := <-wh.queue:
+			wh.deliverWithRetry(ctx, finding)
+		default:
+			return
+		}
+		if ctx.Err() != nil {
+			if n := len(wh.queue); n > 0 {
+				log.Printf("Warning: webhook shutdown timed out with %d findings undelivered", n)
+			}
+			return
+		}
+	}
+}
+
+func (wh *WebhookNotifier) worker() {
+	for finding := range wh.queue {
+		wh.deliverWithRetry(context.Background(), finding)
+	}
+}
+
+// deliverWithRetry tries a delivery a few times, then drops the finding
+func (wh *WebhookNotifier) deliverWithRetry(ctx context.Context, finding APIKeyFinding) {
+	var err error
+retry:
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		if err = wh.deliver(ctx, finding); err == nil {
+			return
+		}
+		if attempt < webhookMaxAttempts {
+			select {
+			case <-ctx.Done():
+				break retry
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+	}
+	webhookDroppedTotal.Inc()
+	log.Printf("Warning: webhook delivery failed for post %s, dropping: %v", finding.PostID, err)
+}
+
+func (wh *WebhookNotifier) deliver(ctx context.Context, finding APIKeyFinding) error {
+	payload := newWebhookPayload(finding)
+	if wh.template != nil {
+		body, err := renderWebhookTemplate(wh.template, payload)
+		if err != nil {
+			return fmt.Errorf("failed to render template: %w", err)
+		}
+		return wh.post(ctx, body)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+	return wh.post(ctx, body)
+}
+
+// post sends body to the webhook, signed when a secret is configured
+func (wh *WebhookNotifier) post(ctx context.Context, body []byte) error {
+	headers := wh.headers.Clone()
+	if wh.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		headers.Set("X-Signature-Timestamp", timestamp)
+		headers.Set("X-Signature", "sha256="+signWebhookBody(wh.secret, timestamp, body))
+	}
+
+	return postBody(ctx, wh.httpClient, wh.url, body, headers)
+}
+
+// signWebhookBody returns the hex HMAC-SHA256 of timestamp + "." + body
+func signWebhookBody(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// TelegramNotifier posts findings to a Telegram chat through a bot
+type TelegramNotifier struct {
+	botToken   string
+	chatID     string
+	httpClient *http.Client
+}
+
+func (t *TelegramNotifier) Name() string {
+	return "telegram"
+}
+
+// Notify sends one formatted message per finding via the sendMessage method
+func (t *TelegramNotifier) Notify(ctx context.Context, finding APIKeyFinding) error {
+	text := fmt.Sprintf("🔑 <b>Exposed %s key</b> (%s)\n"+
+		"Key: <code>%s</code>\n"+
+		"Author: %s\n"+
+		"Submolt: %s\n"+
+		"Post: %s",
+		html.EscapeString(finding.APIKeyType),
+		html.EscapeString(finding.Severity),
+		html.EscapeString(maskKey(finding.APIKey)),
+		html.EscapeString(finding.AuthorName),
+		html.EscapeString(finding.SubmoltName),
+		html.EscapeString(finding.PostURL),
+	)
+	return t.send(ctx, text)
+}
+
+// send posts an HTML-formatted message to the chat
+func (t *TelegramNotifier) send(ctx context.Context, text string) error {
+	message := map[string]interface{}{
+		"chat_id":                  t.chatID,
+		"text":                     text,
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	}
+
+	endpoint := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", t.botToken)
+	return postJSON(ctx, t.httpClient, endpoint, message)
+}
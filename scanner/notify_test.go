@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordingNotifier records the findings it is sent
+type recordingNotifier struct {
+	sent []APIKeyFinding
+}
+
+func (r *recordingNotifier) Name() string { return "recording" }
+
+func (r *recordingNotifier) Notify(ctx context.Context, finding APIKeyFinding) error {
+	r.sent = append(r.sent, finding)
+	return nil
+}
+
+func TestNotifyReleasesClaimWhenQueueIsFull(t *testing.T) {
+	ctx := context.Background()
+	block := make(chan struct{})
+	defer close(block)
+	full := newNotifyQueue(1, 1, func(ctx context.Context, finding APIKeyFinding) { <-block })
+	// One finding held by the worker and one in the queue fill it
+	full.enqueue(ctx, APIKeyFinding{})
+	for full.enqueue(ctx, APIKeyFinding{}) == nil {
+	}
+
+	recorder := &recordingNotifier{}
+	s := &Scanner{
+		notifiers:      []Notifier{recorder},
+		alertRoutes:    alertRoutes{"*": {"*": true}},
+		notifyThrottle: newNotifyThrottle(time.Hour),
+		notifyDedup:    newNotifyDedup(720 * time.Hour),
+		notifyQueue:    full,
+	}
+	finding := APIKeyFinding{PostID: "p1", APIKey: "sk-test-0123456789abcdef", Severity: SeverityCritical}
+
+	claimed, queued := s.claimAndDispatch(ctx, finding)
+	if !claimed || queued {
+		t.Fatalf("claimAndDispatch on a full queue = (%t, %t), want (true, false)", claimed, queued)
+	}
+	if len(s.notifyThrottle.lastSent) != 0 || len(s.notifyDedup.notified) != 0 {
+		t.Fatalf("dropped finding kept its claim: throttle %v, dedup %v", s.notifyThrottle.lastSent, s.notifyDedup.notified)
+	}
+
+	// Once there is room the key alerts; without the persistent dedup
+	// nothing is written to ClickHouse
+	s.notifyQueue = nil
+	s.notifyDedup = nil
+	s.notify(ctx, finding)
+	if len(recorder.sent) != 1 {
+		t.Fatalf("sent %d notifications after the drop, want 1", len(recorder.sent))
+	}
+	s.notify(ctx, finding)
+	if len(recorder.sent) != 1 {
+		t.Errorf("throttle let a repeat through: sent %d notifications", len(recorder.sent))
+	}
+}
+
+func TestNotifyClaimsNothingForUnroutedFindings(t *testing.T) {
+	ctx := context.Background()
+	conn := &fakeConn{}
+	recorder := &recordingNotifier{}
+	s := &Scanner{
+		notifiers:      []Notifier{recorder},
+		alertRoutes:    alertRoutes{SeverityCritical: {"*": true}, "*": {}},
+		notifyThrottle: newNotifyThrottle(time.Hour),
+		notifyDedup:    newNotifyDedup(720 * time.Hour),
+		clickhouseConn: conn,
+		writeLimiter:   newWriteLimiter(1),
+	}
+	finding := APIKeyFinding{PostID: "p1", APIKey: "sk-test-0123456789abcdef", Severity: SeverityHigh}
+
+	if claimed, queued := s.claimAndDispatch(ctx, finding); claimed || queued {
+		t.Fatalf("claimAndDispatch of an unrouted finding = (%t, %t), want (false, false)", claimed, queued)
+	}
+	if len(s.notifyThrottle.lastSent) != 0 || len(s.notifyDedup.notified) != 0 {
+		t.Errorf("unrouted finding claimed its key: throttle %v, dedup %v", s.notifyThrottle.lastSent, s.notifyDedup.notified)
+	}
+	if n := len(conn.inserts("notified_fingerprints")); n != 0 {
+		t.Errorf("unrouted finding persisted %d notified fingerprints", n)
+	}
+
+	// Found again at a routed severity, the key alerts
+	finding.Severity = SeverityCritical
+	s.notify(ctx, finding)
+	if len(recorder.sent) != 1 {
+		t.Fatalf("sent %d notifications once routed, want 1", len(recorder.sent))
+	}
+	if n := len(conn.inserts("notified_fingerprints")); n != 1 {
+		t.Errorf("persisted %d notified fingerprints once routed, want 1", n)
+	}
+}
+
+func TestNotifyQueueRejectsFindingsWhileDraining(t *testing.T) {
+	ctx := context.Background()
+	release := make(chan struct{})
+	var sent atomic.Int64
+	q := newNotifyQueue(2, 100, func(ctx context.Context, finding APIKeyFinding) {
+		<-release
+		sent.Add(1)
+	})
+	for i := 0; i < 3; i++ {
+		if err := q.enqueue(ctx, APIKeyFinding{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waited := make(chan struct{})
+	go func() {
+		q.wait(ctx)
+		close(waited)
+	}()
+
+	// Findings arriving during the flush are turned away rather than
+	// racing the wait; enqueue from several goroutines to let -race see it
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				q.enqueue(ctx, APIKeyFinding{})
+			}
+		}()
+	}
+	wg.Wait()
+	for {
+		if err := q.enqueue(ctx, APIKeyFinding{}); errors.Is(err, errNotifyQueueDraining) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case <-waited:
+		t.Fatal("wait returned with findings still being sent")
+	default:
+	}
+	close(release)
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait did not return once the queued findings were sent")
+	}
+	if n := sent.Load(); n < 3 {
+		t.Errorf("sent %d findings, want at least the 3 queued before draining", n)
+	}
+
+	// A second wait returns at once
+	q.wait(ctx)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Defaults for NOTIFY_WORKERS and NOTIFY_QUEUE_SIZE
+const (
+	defaultNotifyWorkers   = 4
+	defaultNotifyQueueSize = 1000
+)
+
+var (
+	notifyQueueDepth = newGauge(
+		"moltbook_notify_queue_depth",
+		"Findings waiting in the notify queue for a worker.",
+	)
+	notifyDroppedTotal = newCounter(
+		"moltbook_notify_dropped_total",
+		"Findings not sent to any notifier because the notify queue was full.",
+	)
+)
+
+// notifyJob is a finding waiting to be sent to the notifiers, with the
+// context of the cycle it was found in
+type notifyJob struct {
+	ctx     context.Context
+	finding APIKeyFinding
+}
+
+// Reasons enqueue turns a finding away
+var (
+	errNotifyQueueFull     = errors.New("notify queue full")
+	errNotifyQueueDraining = errors.New("notify queue is draining for shutdown")
+)
+
+// notifyQueue bounds notifier dispatch to a fixed number of workers, so a
+// burst of findings from a leak dump can't open an unbounded number of
+// connections to the notifiers. Findings arriving while the queue is full
+// are dropped and counted rather than blocking the scan.
+type notifyQueue struct {
+	jobs    chan notifyJob
+	workers int
+	send    func(ctx context.Context, finding APIKeyFinding)
+
+	mu       sync.Mutex
+	pending  int           // jobs queued or being sent
+	draining bool          // wait was called, so no more jobs are accepted
+	idle     chan struct{} // closed once draining and nothing is pending
+}
+
+// newNotifyQueue starts workers goroutines sending queued findings with send
+func newNotifyQueue(workers, size int, send func(ctx context.Context, finding APIKeyFinding)) *notifyQueue {
+	q := &notifyQueue{
+		jobs:    make(chan notifyJob, size),
+		workers: workers,
+		send:    send,
+		idle:    make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		go q.worker()
+	}
+	return q
+}
+
+// enqueue queues a finding without blocking. It fails if the queue is full
+// or, once wait has been called, draining; a late scan or replay then
+// can't extend the shutdown flush. The job outlives the cycle, so it keeps
+// ctx's values but not its cancellation.
+func (q *notifyQueue) enqueue(ctx context.Context, finding APIKeyFinding) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.draining {
+		return errNotifyQueueDraining
+	}
+	select {
+	case q.jobs <- notifyJob{ctx: context.WithoutCancel(ctx), finding: finding}:
+		q.pending++
+		notifyQueueDepth.Set(float64(len(q.jobs)))
+		return nil
+	default:
+		notifyDroppedTotal.Inc()
+		return fmt.Errorf("%w (%d)", errNotifyQueueFull, cap(q.jobs))
+	}
+}
+
+func (q *notifyQueue) worker() {
+	for job := range q.jobs {
+		notifyQueueDepth.Set(float64(len(q.jobs)))
+		q.send(job.ctx, job.finding)
+
+		q.mu.Lock()
+		q.pending--
+		if q.pending == 0 && q.draining {
+			close(q.idle)
+		}
+		q.mu.Unlock()
+	}
+}
+
+// wait stops the queue accepting findings and blocks until every queued
+// one was sent or ctx ends
+func (q *notifyQueue) wait(ctx context.Context) {
+	q.mu.Lock()
+	if !q.draining {
+		q.draining = true
+		if q.pending == 0 {
+			close(q.idle)
+		}
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.idle:
+	case <-ctx.Done():
+		log.Printf("Warning: notify shutdown timed out with %d findings still queued", len(q.jobs))
+	}
+}
+
+// notifyQueueStats describes the notify queue for /status
+type notifyQueueStats struct {
+	Depth    int `json:"depth"`
+	Capacity int `json:"capacity"`
+	Workers  int `json:"workers"`
+}
+
+func (q *notifyQueue) stats() notifyQueueStats {
+	if q == nil {
+		return notifyQueueStats{}
+	}
+	return notifyQueueStats{Depth: len(q.jobs), Capacity: cap(q.jobs), Workers: q.workers}
+}
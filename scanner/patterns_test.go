@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// newPatternScanner returns a scanner with every pattern enabled and no
+// ClickHouse, allowlist or scan limits beyond the defaults
+func newPatternScanner() *Scanner {
+	return &Scanner{
+		apiKeyPatterns:  compileAPIKeyPatterns(),
+		contextPatterns: compileContextPatterns(),
+		keyTypes:        newKeyTypeFilter("", ""),
+		maxScanBytes:    1 << 20,
+	}
+}
+
+// patternCase is a text and the key type it must (or, with an empty
+// keyType, must not) be reported with
+type patternCase struct {
+	name    string
+	text    string
+	keyType string // "" if nothing may be reported
+	key     string // the expected key, if it isn't simply found in text
+}
+
+// runPatternCases scans each case's text and checks the key types found
+func runPatternCases(t *testing.T, cases []patternCase) {
+	t.Helper()
+	s := newPatternScanner()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			matches := s.ScanText(tc.text)
+			if tc.keyType == "" {
+				if len(matches) > 0 {
+					t.Errorf("ScanText(%q) reported %v, want nothing", tc.text, matchTypes(matches))
+				}
+				return
+			}
+			for _, m := range matches {
+				if m.Type == tc.keyType && (tc.key == "" || m.Key == tc.key) {
+					return
+				}
+			}
+			t.Errorf("ScanText(%q) = %v (%v), want a %s match %q", tc.text, matchTypes(matches), matchKeys(matches), tc.keyType, tc.key)
+		})
+	}
+}
+
+// fakeBase64 returns the base64 encoding of n bytes of a fixed sequence
+func fakeBase64(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i*37 + 11)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func TestAzureAndDigitalOceanPatterns(t *testing.T) {
+	// Storage account keys are 64 bytes, 88 characters in base64
+	accountKey := fakeBase64(64)
+	// 100 bytes also end in "==", so the last 88 characters have the shape
+	blob := fakeBase64(100)
+
+	runPatternCases(t, []patternCase{
+		{
+			name:    "storage key in a connection string",
+			text:    "DefaultEndpointsProtocol=https;AccountName=demo;AccountKey=" + accountKey + ";EndpointSuffix=core.windows.net",
+			keyType: "AzureStorageKey",
+			key:     accountKey,
+		},
+		{
+			name:    "quoted storage key",
+			text:    `AZURE_STORAGE_KEY="` + accountKey + `"`,
+			keyType: "AzureStorageKey",
+			key:     accountKey,
+		},
+		{name: "window of a longer base64 blob", text: "img: data:image/png;base64," + blob},
+		{name: "storage key shape inside base64url", text: "eyJhbGciOiJIUzI1NiJ9_" + accountKey + "_sig"},
+		{
+			name:    "SAS token",
+			text:    "https://demo.blob.core.windows.net/c?sv=2022-11-02&ss=b&srt=co&sp=rl&sig=" + strings.Repeat("AbC0", 11) + "%3D",
+			keyType: "AzureSAS",
+		},
+		{name: "SAS query without a signature", text: "?sv=2022-11-02&ss=b&srt=co&sp=rl"},
+		{name: "DigitalOcean PAT", text: "DO_TOKEN=dop_v1_" + strings.Repeat("0a1b", 16), keyType: "DigitalOceanPAT"},
+		{name: "DigitalOcean OAuth token", text: "doo_v1_" + strings.Repeat("c3d4", 16), keyType: "DigitalOceanOAuth"},
+		{name: "DigitalOcean refresh token", text: "dor_v1_" + strings.Repeat("e5f6", 16), keyType: "DigitalOceanOAuth"},
+		{name: "DigitalOcean PAT too short", text: "dop_v1_" + strings.Repeat("0a1b", 8)},
+	})
+}
+
+func TestPackageRegistryPatterns(t *testing.T) {
+	runPatternCases(t, []patternCase{
+		{name: "npm token in .npmrc", text: "//registry.npmjs.org/:_authToken=npm_" + strings.Repeat("aB3d", 9), keyType: "NPM"},
+		{name: "npm token too short", text: "npm_" + strings.Repeat("aB3d", 5)},
+		{name: "npm package name", text: "npm_config_cache and npm_lifecycle_event are set by npm"},
+		{
+			name:    "PyPI token in .pypirc",
+			text:    "password = pypi-AgEIcHlwaS5vcmc" + strings.Repeat("CJDE2YjVl", 8),
+			keyType: "PyPI",
+		},
+		{name: "PyPI prefix without a body", text: "username = __token__, password = pypi-AgEIcHlwaS5vcmc"},
+		{name: "Docker Hub PAT", text: "docker login -u ci -p dckr_pat_" + strings.Repeat("Xy_9-", 6), keyType: "DockerHub"},
+		{name: "Docker Hub PAT too short", text: "dckr_pat_" + strings.Repeat("Xy_9", 4)},
+	})
+
+	for _, keyType := range []string{"NPM", "PyPI", "DockerHub"} {
+		if got := getSeverity(keyType); got != SeverityHigh {
+			t.Errorf("getSeverity(%q) = %s, want %s", keyType, got, SeverityHigh)
+		}
+	}
+}
+
+func TestGoogleAndFirebasePatterns(t *testing.T) {
+	googleKey := "AIza" + strings.Repeat("Sy0_aB-3", 4) + "xyz"
+	runPatternCases(t, []patternCase{
+		{name: "OAuth client secret", text: `"client_secret": "GOCSPX-` + strings.Repeat("a1B2-c", 4) + `d4_e"`, keyType: "GoogleOAuthSecret"},
+		{name: "OAuth client secret too short", text: "GOCSPX-" + strings.Repeat("a1B2", 3)},
+		{
+			name:    "FCM server key",
+			text:    "Authorization: key=AAAAbC1_dE2:" + strings.Repeat("APA91b-Hx_", 15),
+			keyType: "FirebaseCloudMessaging",
+		},
+		{name: "FCM sender prefix without a key", text: "AAAAbC1_dE2:APA91b"},
+		{name: "Google API key", text: "GOOGLE_MAPS_KEY=" + googleKey, keyType: "Google", key: googleKey},
+		{
+			name:    "Firebase web config key",
+			text:    `const firebaseConfig = { apiKey: "` + googleKey + `", authDomain: "demo.firebaseapp.com" }`,
+			keyType: "FirebaseWebKey",
+			key:     googleKey,
+		},
+	})
+
+	if got := getSeverity("FirebaseWebKey"); got != SeverityLow {
+		t.Errorf("getSeverity(FirebaseWebKey) = %s, want %s", got, SeverityLow)
+	}
+}
+
+func TestInfraPlatformContextPatterns(t *testing.T) {
+	uuid := "3f2a9c1e-7b4d-4e8f-9a0b-1c2d3e4f5a6b"
+	hex37 := strings.Repeat("0123456789abcdef", 2) + "01234"
+	token40 := strings.Repeat("Ab3_x-Z9", 5)
+	token32 := strings.Repeat("fA5t-Ly_", 4)
+
+	runPatternCases(t, []patternCase{
+		{name: "Heroku API key", text: "HEROKU_API_KEY=" + uuid, keyType: "Heroku", key: uuid},
+		{name: "UUID without Heroku context", text: "request id " + uuid + " failed"},
+		{
+			name:    "Cloudflare Global API key with email",
+			text:    "X-Auth-Email: ops@example.com\nX-Auth-Key: " + hex37,
+			keyType: "CloudflareGlobalKey",
+			key:     hex37,
+		},
+		{name: "37 hex digits without Cloudflare context", text: "checksum " + hex37},
+		{name: "Cloudflare API token", text: "CLOUDFLARE_API_TOKEN=" + token40, keyType: "CloudflareAPIToken", key: token40},
+		{name: "40 characters without Cloudflare context", text: "build " + token40},
+		{name: "Fastly token", text: "FASTLY_API_TOKEN=" + token32, keyType: "Fastly", key: token32},
+		{name: "32 characters without Fastly context", text: "session " + token32},
+	})
+}
+
+func TestShopifyAndSquarePatterns(t *testing.T) {
+	hex32 := strings.Repeat("a1B2c3D4", 4)
+	runPatternCases(t, []patternCase{
+		{name: "Shopify access token", text: "X-Shopify-Access-Token: shpat_" + hex32, keyType: "ShopifyAccessToken"},
+		{name: "Shopify custom app token", text: "shpca_" + hex32, keyType: "ShopifyCustomAppToken"},
+		{name: "Shopify private app token", text: "shppa_" + hex32, keyType: "ShopifyPrivateAppToken"},
+		{name: "Shopify shared secret", text: "SHOPIFY_SECRET=shpss_" + hex32, keyType: "ShopifySharedSecret"},
+		{name: "Shopify token with non-hex body", text: "shpat_" + strings.Repeat("zzzz", 8)},
+		{name: "Square sq0atp- access token", text: "sq0atp-" + strings.Repeat("Ab3_x-Z9Q", 2) + "Ab3_", keyType: "SquareAccessToken"},
+		{name: "Square OAuth secret", text: "sq0csp-" + strings.Repeat("Ab3_x-Z9Q", 4) + "Ab3_x-Z", keyType: "SquareOAuthSecret"},
+		{name: "Square EAAA access token", text: "SQUARE_TOKEN=EAAA" + strings.Repeat("Ab3xZ9", 10), keyType: "SquareAccessToken"},
+		{name: "Square prefix too short", text: "sq0atp-Ab3"},
+	})
+
+	for _, keyType := range []string{"ShopifyAccessToken", "ShopifyCustomAppToken", "ShopifyPrivateAppToken", "ShopifySharedSecret", "SquareAccessToken", "SquareOAuthSecret"} {
+		if got := getSeverity(keyType); got != SeverityHigh {
+			t.Errorf("getSeverity(%q) = %s, want %s", keyType, got, SeverityHigh)
+		}
+	}
+}
+
+func TestProductivityToolPatterns(t *testing.T) {
+	runPatternCases(t, []patternCase{
+		{name: "Atlassian API token", text: "JIRA_TOKEN=ATATT3xFfGF0" + strings.Repeat("Ab3_x-Z9Q=", 16), keyType: "AtlassianAPIToken"},
+		{name: "Atlassian prefix too short", text: "ATATT3xFfGF0" + strings.Repeat("Ab3", 10)},
+		{name: "Linear API key", text: "LINEAR_API_KEY=lin_api_" + strings.Repeat("aB3dE", 8), keyType: "LinearAPIKey"},
+		{name: "Linear key too short", text: "lin_api_" + strings.Repeat("aB3dE", 4)},
+		{name: "Notion secret_ token", text: "NOTION_TOKEN=secret_" + strings.Repeat("aB3dEf9", 6) + "Q", keyType: "NotionToken"},
+		{name: "Notion ntn_ token", text: "ntn_" + strings.Repeat("aB3dEf", 7) + "Qx9Z", keyType: "NotionToken"},
+		{name: "secret_ identifier", text: "func secret_handler() and secret_key_base"},
+	})
+
+	for _, keyType := range []string{"AtlassianAPIToken", "LinearAPIKey", "NotionToken"} {
+		if got := getSeverity(keyType); got != SeverityMedium {
+			t.Errorf("getSeverity(%q) = %s, want %s", keyType, got, SeverityMedium)
+		}
+	}
+}
+
+func TestTwilioAndOpenAIDoNotCollide(t *testing.T) {
+	hex32 := strings.Repeat("0123456789abcdef", 2)
+	openAI := "sk-" + strings.Repeat("aB3dE", 8)
+	runPatternCases(t, []patternCase{
+		{name: "Twilio API key SID", text: "TWILIO_API_KEY_SID SK" + hex32, keyType: "Twilio", key: "SK" + hex32},
+		{name: "lower-case sk and 32 hex digits", text: "sk" + hex32},
+		{name: "OpenAI key", text: "OPENAI=" + openAI, keyType: "OpenAI", key: openAI},
+	})
+
+	s := newPatternScanner()
+	for _, m := range s.ScanText("OPENAI=" + openAI) {
+		if m.Type == "Twilio" {
+			t.Errorf("OpenAI key reported as Twilio: %q", m.Key)
+		}
+	}
+	for _, m := range s.ScanText("SK" + hex32) {
+		if m.Type == "OpenAI" {
+			t.Errorf("Twilio SID reported as OpenAI: %q", m.Key)
+		}
+	}
+	if got := getAPIKeyType(openAI); got != "OpenAI" {
+		t.Errorf("getAPIKeyType(%q) = %s, want OpenAI", openAI, got)
+	}
+}
+
+// totalPatternMatches sums moltbook_pattern_matches_total over patterns
+func totalPatternMatches() float64 {
+	var total float64
+	for _, n := range patternMatchesTotal.snapshot() {
+		total += n
+	}
+	return total
+}
+
+func TestMaskSecretsDoesNotCountMatches(t *testing.T) {
+	openAI := "sk-" + strings.Repeat("aB3dE", 8)
+	s := newPatternScanner()
+	before := totalPatternMatches()
+
+	masked := s.maskSecrets("OPENAI=" + openAI)
+	if strings.Contains(masked, openAI) {
+		t.Fatalf("maskSecrets left the key in %q", masked)
+	}
+	if after := totalPatternMatches(); after != before {
+		t.Errorf("maskSecrets counted %g pattern matches, want 0", after-before)
+	}
+
+	s.ScanText("OPENAI=" + openAI)
+	if after := totalPatternMatches(); after != before+1 {
+		t.Errorf("ScanText counted %g pattern matches, want 1", after-before)
+	}
+}
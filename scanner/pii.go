@@ -0,0 +1,90 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PII finding types, kept apart from credential types so dashboards can
+// filter them out
+const (
+	PIITypeEmail = "Email"
+	PIITypePhone = "Phone"
+)
+
+// piiPattern matches one kind of personal contact detail
+type piiPattern struct {
+	re      *regexp.Regexp
+	piiType string
+}
+
+// compilePIIPatterns returns the email and phone detectors enabled by
+// SCAN_PII. Phone numbers are E.164 (+ and 8-15 digits) or North American
+// numbers written with separators, so bare digit runs like IDs don't match.
+func compilePIIPatterns() []piiPattern {
+	return []piiPattern{
+		{
+			re:      regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9\-]+(?:\.[a-zA-Z0-9\-]+)*\.[a-zA-Z]{2,}`),
+			piiType: PIITypeEmail,
+		},
+		{
+			re:      regexp.MustCompile(`\+[1-9][0-9]{7,14}\b`),
+			piiType: PIITypePhone,
+		},
+		{
+			re:      regexp.MustCompile(`(?:\+?1[ .\-]?)?(?:\([2-9][0-9]{2}\)|\b[2-9][0-9]{2})[ .\-]?[2-9][0-9]{2}[ .\-][0-9]{4}\b`),
+			piiType: PIITypePhone,
+		},
+	}
+}
+
+// isPIIType reports whether keyType is a PII rather than a credential type
+func isPIIType(keyType string) bool {
+	return keyType == PIITypeEmail || keyType == PIITypePhone
+}
+
+// storedValue returns the value to persist for a match: credentials as
+// found, PII partially masked so the raw contact detail is never stored
+func storedValue(m KeyMatch) string {
+	switch m.Type {
+	case PIITypeEmail:
+		return maskEmail(m.Key)
+	case PIITypePhone:
+		return maskPhone(m.Key)
+	default:
+		return m.Key
+	}
+}
+
+// maskEmail keeps the first character of the local part and the domain,
+// e.g. "j***@example.com"
+func maskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return maskKey(email)
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// maskPhone replaces every digit but the last four, keeping the formatting,
+// e.g. "+* (***) ***-0123"
+func maskPhone(phone string) string {
+	digits := 0
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits--
+			if digits >= 4 {
+				r = '*'
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
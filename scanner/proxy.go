@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// newProxyTransport returns a transport routing outbound requests through
+// the configured proxy. PROXY_URL, if set, is used for every request.
+// Otherwise HTTP_PROXY, HTTPS_PROXY and NO_PROXY apply as usual, with
+// ALL_PROXY (e.g. socks5://host:1080) covering requests neither of those
+// proxies. http, https, socks5 and socks5h proxy URLs are supported.
+func newProxyTransport() (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if raw := os.Getenv("PROXY_URL"); raw != "" {
+		proxyURL, err := parseProxyURL("PROXY_URL", raw)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Routing outbound requests through %s", proxyURL.Redacted())
+		transport.Proxy = http.ProxyURL(proxyURL)
+		return transport, nil
+	}
+
+	raw := getEnvOrDefault("ALL_PROXY", os.Getenv("all_proxy"))
+	if raw == "" {
+		transport.Proxy = http.ProxyFromEnvironment
+		return transport, nil
+	}
+	allProxy, err := parseProxyURL("ALL_PROXY", raw)
+	if err != nil {
+		return nil, err
+	}
+	noProxy := strings.Split(getEnvOrDefault("NO_PROXY", os.Getenv("no_proxy")), ",")
+	log.Printf("Routing outbound requests not covered by HTTP(S)_PROXY through %s", allProxy.Redacted())
+
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := http.ProxyFromEnvironment(req)
+		if proxyURL != nil || err != nil {
+			return proxyURL, err
+		}
+		if bypassesProxy(req.URL.Hostname(), noProxy) {
+			return nil, nil
+		}
+		return allProxy, nil
+	}
+	return transport, nil
+}
+
+// parseProxyURL validates a proxy URL read from the environment variable name
+func parseProxyURL(name, raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid %s %q: scheme must be http, https, socks5 or socks5h", name, u.Redacted())
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid %s %q: missing host", name, u.Redacted())
+	}
+	return u, nil
+}
+
+// bypassesProxy reports whether host matches a NO_PROXY entry: "*", the
+// host itself, or a domain it is under ("example.com" or ".example.com")
+func bypassesProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(host)
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		domain := strings.TrimPrefix(entry, ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+)
+
+// pruneTarget is a table the prune command deletes from and the column its
+// rows are aged by
+type pruneTarget struct {
+	table, column string
+}
+
+// pruneTargets are aged by the time rows were written, which is also the
+// first column of each table's sort key
+var pruneTargets = []pruneTarget{
+	{"api_key_findings", "found_at"},
+	{"messages", "scanned_at"},
+}
+
+// runPrune deletes findings and messages older than -older-than. Without
+// -yes it only counts the rows that would be deleted.
+func (s *Scanner) runPrune(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ContinueOnError)
+	olderThan := fs.Duration("older-than", 0, "delete rows older than this, e.g. 2160h for 90 days (required)")
+	yes := fs.Bool("yes", false, "delete the rows instead of only counting them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *olderThan <= 0 {
+		return errors.New("-older-than must be a positive duration")
+	}
+	cutoff := s.now().Add(-*olderThan)
+
+	for _, t := range pruneTargets {
+		table := s.table(t.table)
+		var count uint64
+		if err := s.clickhouseConn.QueryRow(ctx, fmt.Sprintf(`SELECT count() FROM %s WHERE %s < ?`, table, t.column), cutoff).Scan(&count); err != nil {
+			return fmt.Errorf("failed to count rows of %s: %w", t.table, err)
+		}
+		if !*yes {
+			log.Printf("Would delete %d rows of %s with %s before %s", count, t.table, t.column, cutoff.Format(time.RFC3339))
+			continue
+		}
+		if count == 0 {
+			log.Printf("No rows of %s with %s before %s", t.table, t.column, cutoff.Format(time.RFC3339))
+			continue
+		}
+		if err := s.deleteOlderThan(ctx, table, t.column, cutoff); err != nil {
+			return fmt.Errorf("failed to prune %s: %w", t.table, err)
+		}
+		log.Printf("Deleted %d rows of %s with %s before %s", count, t.table, t.column, cutoff.Format(time.RFC3339))
+	}
+	if !*yes {
+		log.Printf("Dry run: rerun with -yes to delete")
+	}
+	return nil
+}
+
+// deleteOlderThan deletes the rows of table with column before cutoff. It
+// uses a lightweight DELETE, which hides the rows at once, and falls back
+// to an ALTER TABLE DELETE mutation on servers without lightweight deletes.
+// The tables aren't partitioned, so there are no partitions to drop.
+func (s *Scanner) deleteOlderThan(ctx context.Context, table, column string, cutoff time.Time) error {
+	err := s.clickhouseConn.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE %s < ?`, table, column), cutoff)
+	if err == nil {
+		return nil
+	}
+	log.Printf("Lightweight delete on %s failed (%v), falling back to a mutation", table, err)
+	return s.clickhouseConn.Exec(ctx, fmt.Sprintf(`ALTER TABLE %s DELETE WHERE %s < ?`, table, column), cutoff)
+}
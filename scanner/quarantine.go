@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// SaveQuarantinedFinding saves a finding below MIN_CONFIDENCE to
+// findings_quarantine. Quarantined findings are not alerted; they can be
+// reviewed there and moved to api_key_findings with the promote command.
+func (s *Scanner) SaveQuarantinedFinding(ctx context.Context, finding APIKeyFinding) error {
+	values := append(findingRowValues(ctx, finding), float32(finding.Confidence))
+	query := fmt.Sprintf(`INSERT INTO %s (%s, confidence) VALUES (%s)`,
+		s.table("findings_quarantine"), findingColumns, placeholders(len(values)))
+
+	if err := s.writeLimiter.acquire(ctx); err != nil {
+		return err
+	}
+	defer s.writeLimiter.release()
+
+	err := s.clickhouseConn.Exec(s.insertContext(ctx), query, values...)
+	recordSave(ctx, err)
+	if err != nil {
+		return err
+	}
+	findingsQuarantinedTotal.Inc()
+	logDebug("Quarantined %s finding in post %s (confidence %.2f < MIN_CONFIDENCE %.2f)",
+		finding.APIKeyType, finding.PostID, finding.Confidence, s.minConfidence)
+	return nil
+}
+
+// PromoteFinding moves a quarantined finding to api_key_findings, keeping
+// its ID, and removes it from findings_quarantine. The delete is a
+// ClickHouse mutation, so the row may stay visible in the quarantine for a
+// moment after this returns.
+func (s *Scanner) PromoteFinding(ctx context.Context, id string) error {
+	quarantine := s.table("findings_quarantine")
+
+	var count uint64
+	if err := s.clickhouseConn.QueryRow(ctx, fmt.Sprintf(`SELECT count() FROM %s WHERE id = toUUID(?)`, quarantine), id).Scan(&count); err != nil {
+		return fmt.Errorf("failed to look up quarantined finding: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("no quarantined finding with id %s", id)
+	}
+
+	columns := "id, created_at, " + findingColumns
+	insert := fmt.Sprintf(`INSERT INTO %s (%s) SELECT %s FROM %s WHERE id = toUUID(?)`,
+		s.table("api_key_findings"), columns, columns, quarantine)
+	if err := s.clickhouseConn.Exec(ctx, insert, id); err != nil {
+		return fmt.Errorf("failed to copy finding: %w", err)
+	}
+	if err := s.clickhouseConn.Exec(ctx, fmt.Sprintf(`ALTER TABLE %s DELETE WHERE id = toUUID(?)`, quarantine), id); err != nil {
+		return fmt.Errorf("finding copied but not removed from quarantine: %w", err)
+	}
+
+	log.Printf("Promoted quarantined finding %s", id)
+	return nil
+}
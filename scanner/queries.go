@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AuthorRisk summarizes how often an author has leaked secrets
+type AuthorRisk struct {
+	AuthorName       string    `json:"author_name"`
+	TotalFindings    uint64    `json:"total_findings"`
+	DistinctKeys     uint64    `json:"distinct_keys"`
+	DistinctKeyTypes uint64    `json:"distinct_key_types"`
+	KeyTypes         []string  `json:"key_types"`
+	LastFoundAt      time.Time `json:"last_found_at"`
+	// RiskScore is total findings weighted by the number of distinct key
+	// types, so an account leaking many kinds of secrets ranks above one
+	// reposting the same key
+	RiskScore uint64 `json:"risk_score"`
+}
+
+// TopOffenders returns the authors with the most findings since the given
+// time, ranked by risk score
+func (s *Scanner) TopOffenders(ctx context.Context, limit int, since time.Time) ([]AuthorRisk, error) {
+	query := fmt.Sprintf(`SELECT
+			author_name,
+			count() AS total_findings,
+			uniqExact(api_key) AS distinct_keys,
+			uniqExact(api_key_type) AS distinct_key_types,
+			groupUniqArray(api_key_type) AS key_types,
+			max(found_at) AS last_found_at,
+			total_findings * distinct_key_types AS risk_score
+		FROM %s
+		WHERE found_at >= ?
+		GROUP BY author_name
+		ORDER BY risk_score DESC, total_findings DESC, author_name
+		LIMIT ?`, s.table("api_key_findings"))
+
+	rows, err := s.clickhouseConn.Query(ctx, query, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top offenders: %w", err)
+	}
+	defer rows.Close()
+
+	offenders := []AuthorRisk{}
+	for rows.Next() {
+		var a AuthorRisk
+		if err := rows.Scan(&a.AuthorName, &a.TotalFindings, &a.DistinctKeys, &a.DistinctKeyTypes, &a.KeyTypes, &a.LastFoundAt, &a.RiskScore); err != nil {
+			return nil, fmt.Errorf("failed to scan author risk: %w", err)
+		}
+		offenders = append(offenders, a)
+	}
+
+	return offenders, rows.Err()
+}
+
+// FindingRecord is a stored finding as returned by read APIs, with the key
+// masked and fingerprinted rather than returned in full
+type FindingRecord struct {
+	ID            string    `json:"id"`
+	PostID        string    `json:"post_id"`
+	MessageID     string    `json:"message_id,omitempty"`
+	PostTitle     string    `json:"post_title"`
+	AuthorName    string    `json:"author_name"`
+	SubmoltName   string    `json:"submolt_name"`
+	APIKeyMasked  string    `json:"api_key_masked"`
+	KeySHA256     string    `json:"key_sha256"`
+	APIKeyType    string    `json:"api_key_type"`
+	Severity      string    `json:"severity"`
+	Encoding      string    `json:"encoding"`
+	Location      string    `json:"location"`
+	Content       string    `json:"content"`
+	PostURL       string    `json:"post_url"`
+	SourceURL     string    `json:"source_url,omitempty"`
+	FoundAt       time.Time `json:"found_at"`
+	PostCreatedAt time.Time `json:"post_created_at"`
+
+	// apiKey is the unmasked key, only ever returned by an authorized reveal
+	apiKey string
+}
+
+// FindingFilter narrows QueryFindings; zero fields don't filter
+type FindingFilter struct {
+	ID        string
+	KeySHA256 string
+	KeyType   string
+	Submolt   string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+	// After pages through findings oldest first, starting after the cursor;
+	// a zero cursor starts at the oldest finding
+	After *FindingCursor
+}
+
+// FindingCursor is a position in the findings ordered by (found_at, id)
+type FindingCursor struct {
+	FoundAt time.Time `json:"after_found_at"`
+	ID      string    `json:"after_id"`
+}
+
+// QueryFindings returns the most recent findings matching filter, all of
+// them when Limit is 0
+func (s *Scanner) QueryFindings(ctx context.Context, filter FindingFilter) ([]FindingRecord, error) {
+	where := []string{"found_at >= ?"}
+	args := []interface{}{filter.Since}
+	if filter.ID != "" {
+		where = append(where, "id = toUUIDOrZero(?)")
+		args = append(args, filter.ID)
+	}
+	if filter.KeySHA256 != "" {
+		// Rows saved before key_sha256 existed are hashed from api_key
+		where = append(where, "if(key_sha256 = '', lower(hex(SHA256(api_key))), key_sha256) = ?")
+		args = append(args, strings.ToLower(filter.KeySHA256))
+	}
+	if filter.KeyType != "" {
+		where = append(where, "api_key_type = ?")
+		args = append(args, filter.KeyType)
+	}
+	if filter.Submolt != "" {
+		where = append(where, "submolt_name = ?")
+		args = append(args, filter.Submolt)
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "found_at < ?")
+		args = append(args, filter.Until)
+	}
+	order := "found_at DESC"
+	if filter.After != nil {
+		if !filter.After.FoundAt.IsZero() {
+			where = append(where, "(found_at, id) > (?, toUUIDOrZero(?))")
+			args = append(args, filter.After.FoundAt, filter.After.ID)
+		}
+		order = "found_at, id"
+	}
+	limit := ""
+	if filter.Limit > 0 {
+		limit = "LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	// Rows saved before key_sha256 and severity existed have them empty.
+	// The stored values are used otherwise: a finding replayed from the
+	// dead letter file keeps its real fingerprint with a masked api_key,
+	// and some findings are rated above their key type's severity.
+	query := fmt.Sprintf(`SELECT toString(id), post_id, post_title, author_name, submolt_name, api_key, api_key_type,
+			encoding, location, content, post_url, source_url, found_at, post_created_at,
+			if(key_sha256 = '', lower(hex(SHA256(api_key))), key_sha256), severity, message_id
+		FROM %s
+		WHERE %s
+		ORDER BY %s
+		%s`, s.table("api_key_findings"), strings.Join(where, " AND "), order, limit)
+
+	rows, err := s.clickhouseConn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query findings: %w", err)
+	}
+	defer rows.Close()
+
+	findings := []FindingRecord{}
+	for rows.Next() {
+		var f FindingRecord
+		var key string
+		if err := rows.Scan(&f.ID, &f.PostID, &f.PostTitle, &f.AuthorName, &f.SubmoltName, &key, &f.APIKeyType,
+			&f.Encoding, &f.Location, &f.Content, &f.PostURL, &f.SourceURL, &f.FoundAt, &f.PostCreatedAt,
+			&f.KeySHA256, &f.Severity, &f.MessageID); err != nil {
+			return nil, fmt.Errorf("failed to scan finding: %w", err)
+		}
+		f.apiKey = key
+		f.APIKeyMasked = maskKey(key)
+		if f.Severity == "" {
+			f.Severity = getSeverity(f.APIKeyType)
+		}
+		findings = append(findings, f)
+	}
+
+	return findings, rows.Err()
+}
+
+// KeySpread is everywhere one key was found, to tell a single leak from a
+// key reposted across submolts or by several authors
+type KeySpread struct {
+	KeySHA256   string          `json:"key_sha256"`
+	APIKeyType  string          `json:"api_key_type"`
+	Submolts    []string        `json:"submolts"`
+	Authors     []string        `json:"authors"`
+	FirstSeen   time.Time       `json:"first_seen"`
+	LastSeen    time.Time       `json:"last_seen"`
+	Occurrences []FindingRecord `json:"occurrences"`
+}
+
+// KeySpread returns the posts and comments the key with fingerprint keyHash
+// was found in, newest first, with the distinct submolts and authors. The
+// result has no occurrences if the key was never found.
+func (s *Scanner) KeySpread(ctx context.Context, keyHash string) (KeySpread, error) {
+	findings, err := s.QueryFindings(ctx, FindingFilter{KeySHA256: keyHash})
+	if err != nil {
+		return KeySpread{}, err
+	}
+
+	spread := KeySpread{
+		KeySHA256:   strings.ToLower(keyHash),
+		Submolts:    []string{},
+		Authors:     []string{},
+		Occurrences: findings,
+	}
+	submolts := make(map[string]bool)
+	authors := make(map[string]bool)
+	for _, f := range findings {
+		submolts[f.SubmoltName] = true
+		authors[f.AuthorName] = true
+		if spread.FirstSeen.IsZero() || f.FoundAt.Before(spread.FirstSeen) {
+			spread.FirstSeen = f.FoundAt
+		}
+		if f.FoundAt.After(spread.LastSeen) {
+			spread.LastSeen = f.FoundAt
+		}
+	}
+	if len(findings) > 0 {
+		spread.APIKeyType = findings[0].APIKeyType
+		spread.Submolts = sortedKeys(submolts)
+		spread.Authors = sortedKeys(authors)
+	}
+	return spread, nil
+}
+
+// MessageRecord is a stored message as returned by read APIs, with any
+// secrets in its title and content masked
+type MessageRecord struct {
+	ID          string    `json:"id"`
+	MessageType string    `json:"message_type"`
+	PostID      string    `json:"post_id"`
+	ParentID    string    `json:"parent_id"`
+	Title       string    `json:"title"`
+	Content     string    `json:"content"`
+	AuthorName  string    `json:"author_name"`
+	SubmoltName string    `json:"submolt_name"`
+	MessageURL  string    `json:"message_url"`
+	CreatedAt   time.Time `json:"created_at"`
+	ScannedAt   time.Time `json:"scanned_at"`
+	HasAPIKey   bool      `json:"has_api_key"`
+	APIKeyTypes []string  `json:"api_key_types"`
+}
+
+// MessageFilter narrows QueryMessages; zero fields don't filter
+type MessageFilter struct {
+	ID          string
+	MessageType string
+	AuthorName  string
+	Since       time.Time
+	Limit       int
+}
+
+// QueryMessages returns the most recently created messages matching filter
+func (s *Scanner) QueryMessages(ctx context.Context, filter MessageFilter) ([]MessageRecord, error) {
+	where := []string{"created_at >= ?"}
+	args := []interface{}{filter.Since}
+	if filter.ID != "" {
+		where = append(where, "id = ?")
+		args = append(args, filter.ID)
+	}
+	if filter.MessageType != "" {
+		where = append(where, "message_type = ?")
+		args = append(args, filter.MessageType)
+	}
+	if filter.AuthorName != "" {
+		where = append(where, "author_name = ?")
+		args = append(args, filter.AuthorName)
+	}
+	args = append(args, filter.Limit)
+
+	// Messages can be stored more than once (e.g. by reprocess, or again
+	// after an edit), so keep one row per id with the latest content
+	query := fmt.Sprintf(`SELECT id, any(message_type), any(post_id), any(parent_id),
+			argMax(title, scanned_at), argMax(content, scanned_at),
+			any(author_name), any(submolt_name), any(message_url), any(created_at) AS created,
+			min(scanned_at), argMax(has_api_key, scanned_at), argMax(api_key_types, scanned_at)
+		FROM %s
+		WHERE %s
+		GROUP BY id
+		ORDER BY created DESC
+		LIMIT ?`, s.table("messages"), strings.Join(where, " AND "))
+
+	rows, err := s.clickhouseConn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []MessageRecord{}
+	for rows.Next() {
+		var m MessageRecord
+		var hasAPIKey uint8
+		if err := rows.Scan(&m.ID, &m.MessageType, &m.PostID, &m.ParentID, &m.Title, &m.Content,
+			&m.AuthorName, &m.SubmoltName, &m.MessageURL, &m.CreatedAt, &m.ScannedAt, &hasAPIKey, &m.APIKeyTypes); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		m.Title = s.maskSecrets(m.Title)
+		m.Content = s.maskSecrets(m.Content)
+		m.HasAPIKey = hasAPIKey == 1
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
+}
+
+// TypeCount is the number of findings of one key type or severity
+type TypeCount struct {
+	Name  string
+	Count uint64
+}
+
+// FindingStats aggregates findings by key type and severity
+type FindingStats struct {
+	Total      uint64
+	ByType     []TypeCount
+	BySeverity []TypeCount
+}
+
+// severityOrder lists severities from most to least urgent
+var severityOrder = []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow}
+
+// QueryFindingStats counts findings since the given time by key type and
+// by their stored severity. Rows saved before severity was stored are
+// counted under their key type's severity.
+func (s *Scanner) QueryFindingStats(ctx context.Context, since time.Time) (FindingStats, error) {
+	query := fmt.Sprintf(`SELECT api_key_type, severity, count()
+		FROM %s
+		WHERE found_at >= ?
+		GROUP BY api_key_type, severity`, s.table("api_key_findings"))
+
+	rows, err := s.clickhouseConn.Query(ctx, query, since)
+	if err != nil {
+		return FindingStats{}, fmt.Errorf("failed to query finding stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := FindingStats{ByType: []TypeCount{}, BySeverity: []TypeCount{}}
+	byType := make(map[string]uint64)
+	bySeverity := make(map[string]uint64)
+	for rows.Next() {
+		var keyType, severity string
+		var count uint64
+		if err := rows.Scan(&keyType, &severity, &count); err != nil {
+			return FindingStats{}, fmt.Errorf("failed to scan finding stats: %w", err)
+		}
+		if severity == "" {
+			severity = getSeverity(keyType)
+		}
+		stats.Total += count
+		byType[keyType] += count
+		bySeverity[severity] += count
+	}
+	if err := rows.Err(); err != nil {
+		return FindingStats{}, err
+	}
+
+	for keyType, n := range byType {
+		stats.ByType = append(stats.ByType, TypeCount{Name: keyType, Count: n})
+	}
+	sort.Slice(stats.ByType, func(i, j int) bool {
+		a, b := stats.ByType[i], stats.ByType[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Name < b.Name
+	})
+	for _, severity := range severityOrder {
+		if n := bySeverity[severity]; n > 0 {
+			stats.BySeverity = append(stats.BySeverity, TypeCount{Name: severity, Count: n})
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQueryFindingStatsUsesStoredSeverity(t *testing.T) {
+	conn := &fakeConn{rows: func(query string, args []any) [][]any {
+		return [][]any{
+			{"AWS", SeverityCritical, uint64(2)},
+			{"AWS", SeverityHigh, uint64(1)},
+			// Saved before severity was stored
+			{"Generic", "", uint64(4)},
+		}
+	}}
+	s := &Scanner{clickhouseConn: conn}
+
+	stats, err := s.QueryFindingStats(context.Background(), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 7 {
+		t.Errorf("Total = %d, want 7", stats.Total)
+	}
+	wantTypes := []TypeCount{{"Generic", 4}, {"AWS", 3}}
+	if !reflect.DeepEqual(stats.ByType, wantTypes) {
+		t.Errorf("ByType = %v, want %v", stats.ByType, wantTypes)
+	}
+	wantSeverities := []TypeCount{{SeverityCritical, 2}, {SeverityHigh, 1}, {SeverityMedium, 4}}
+	if !reflect.DeepEqual(stats.BySeverity, wantSeverities) {
+		t.Errorf("BySeverity = %v, want %v", stats.BySeverity, wantSeverities)
+	}
+}
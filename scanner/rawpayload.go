@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// UnmarshalJSON decodes a post and keeps the exact JSON it came from, so
+// STORE_RAW_PAYLOAD can persist it for replay
+func (p *MoltbookPost) UnmarshalJSON(data []byte) error {
+	type plain MoltbookPost
+	if err := json.Unmarshal(data, (*plain)(p)); err != nil {
+		return err
+	}
+	p.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// UnmarshalJSON decodes a comment and keeps the exact JSON it came from,
+// replies included
+func (c *MoltbookComment) UnmarshalJSON(data []byte) error {
+	type plain MoltbookComment
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	c.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// rawPayload is one message's original API JSON
+type rawPayload struct {
+	id          string
+	messageType string
+	payload     json.RawMessage
+	fetchedAt   time.Time
+}
+
+// rawPayloadBuffer collects raw payloads so they are written in batches
+// rather than one insert per message
+type rawPayloadBuffer struct {
+	mu        sync.Mutex
+	rows      []rawPayload
+	batchSize int
+}
+
+// newRawPayloadBufferFromEnv returns nil unless STORE_RAW_PAYLOAD is enabled
+func newRawPayloadBufferFromEnv() *rawPayloadBuffer {
+	if !getEnvBoolOrDefault("STORE_RAW_PAYLOAD", false) {
+		return nil
+	}
+	log.Printf("Raw API payloads will be stored in raw_payloads")
+	return &rawPayloadBuffer{batchSize: max(getEnvIntOrDefault("RAW_PAYLOAD_BATCH_SIZE", 500), 1)}
+}
+
+// add queues a payload and reports whether the buffer is full
+func (b *rawPayloadBuffer) add(p rawPayload) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rows = append(b.rows, p)
+	return len(b.rows) >= b.batchSize
+}
+
+// take empties the buffer and returns what it held
+func (b *rawPayloadBuffer) take() []rawPayload {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rows := b.rows
+	b.rows = nil
+	return rows
+}
+
+// recordRawPayload queues the raw JSON of a new message, flushing once a
+// full batch is buffered. It does nothing unless STORE_RAW_PAYLOAD is set.
+func (s *Scanner) recordRawPayload(ctx context.Context, id, messageType string, raw json.RawMessage) {
+	if s.rawPayloads == nil || len(raw) == 0 {
+		return
+	}
+	full := s.rawPayloads.add(rawPayload{
+		id:          id,
+		messageType: messageType,
+		payload:     raw,
+		fetchedAt:   s.now(),
+	})
+	if full {
+		s.flushRawPayloads(ctx)
+	}
+}
+
+// flushRawPayloads writes the buffered raw payloads in a single batch.
+// Failures are logged and the batch dropped, as payloads are only kept for
+// debugging.
+func (s *Scanner) flushRawPayloads(ctx context.Context) {
+	if s.rawPayloads == nil {
+		return
+	}
+	rows := s.rawPayloads.take()
+	if len(rows) == 0 {
+		return
+	}
+	if err := s.saveRawPayloads(ctx, rows); err != nil {
+		log.Printf("Warning: failed to store %d raw payloads: %v", len(rows), err)
+	}
+}
+
+func (s *Scanner) saveRawPayloads(ctx context.Context, rows []rawPayload) error {
+	if err := s.writeLimiter.acquire(ctx); err != nil {
+		return err
+	}
+	defer s.writeLimiter.release()
+
+	batch, err := s.clickhouseConn.PrepareBatch(ctx, fmt.Sprintf(
+		`INSERT INTO %s (id, message_type, payload, fetched_at)`, s.table("raw_payloads")))
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch: %w", err)
+	}
+	for _, r := range rows {
+		if err := batch.Append(r.id, r.messageType, string(r.payload), r.fetchedAt); err != nil {
+			batch.Abort()
+			return fmt.Errorf("failed to append payload %s: %w", r.id, err)
+		}
+	}
+	return batch.Send()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// recordRevision appends the last stored version of message id to
+// message_revisions before an edit of it is saved. Only the content is
+// kept, as stored in messages, with the time it was scanned. A revision is
+// identified by that time and its content hash (the contentHash the seen
+// set compares), not by a counter, so the post and comment paths or two
+// replicas recording the same edit write the same row rather than two
+// revision numbers; the table collapses such duplicates. Rows are never
+// updated, so a key posted then edited away stays on record even once
+// messages is pruned. It does nothing unless TRACK_REVISIONS is enabled,
+// and failures are logged without interrupting the scan.
+func (s *Scanner) recordRevision(ctx context.Context, id string) {
+	if !s.trackRevisions {
+		return
+	}
+	query := fmt.Sprintf(`INSERT INTO %s (id, revision, content, observed_at)
+		SELECT id, %s, content, scanned_at
+		FROM %s
+		WHERE id = ?
+		ORDER BY scanned_at DESC
+		LIMIT 1`, s.table("message_revisions"), messageHashExpr, s.table("messages"))
+
+	if err := s.writeLimiter.acquire(ctx); err != nil {
+		return
+	}
+	defer s.writeLimiter.release()
+
+	if err := s.clickhouseConn.Exec(ctx, query, id); err != nil {
+		logCycle(ctx, "Warning: failed to record previous revision of message %s: %v", id, err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+)
+
+// defaultAlertRoutes pages PagerDuty only for critical findings and sends
+// every finding to the other notifiers
+const defaultAlertRoutes = "critical=*;*=webhook,telegram"
+
+var severities = []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow}
+
+// notifierNames are the names routes can refer to, as returned by Name()
+var notifierNames = []string{"pagerduty", "webhook", "telegram"}
+
+// alertRoutes maps a severity to the names of the notifiers that receive
+// its findings. "*" as a severity is the route for severities without one
+// of their own; "*" as a notifier name means every notifier.
+type alertRoutes map[string]map[string]bool
+
+// parseAlertRoutes parses ALERT_ROUTES, semicolon-separated
+// severity=notifier,notifier routes such as
+// "critical=pagerduty,webhook;high=webhook;*=telegram". A route with no
+// notifiers ("low=") silences that severity.
+func parseAlertRoutes(raw string) (alertRoutes, error) {
+	routes := make(alertRoutes)
+	for _, route := range strings.Split(raw, ";") {
+		route = strings.TrimSpace(route)
+		if route == "" {
+			continue
+		}
+		severity, names, ok := strings.Cut(route, "=")
+		severity = strings.ToLower(strings.TrimSpace(severity))
+		if !ok {
+			return nil, fmt.Errorf("expected severity=notifiers, got %q", route)
+		}
+		if severity != "*" && !slices.Contains(severities, severity) {
+			return nil, fmt.Errorf("unknown severity %q, expected one of %s or *", severity, strings.Join(severities, ", "))
+		}
+		if _, dup := routes[severity]; dup {
+			return nil, fmt.Errorf("severity %q routed twice", severity)
+		}
+		set := parseNameSet(names)
+		for name := range set {
+			if name != "*" && !slices.Contains(notifierNames, name) {
+				return nil, fmt.Errorf("unknown notifier %q, expected one of %s or *", name, strings.Join(notifierNames, ", "))
+			}
+		}
+		routes[severity] = set
+	}
+	return routes, nil
+}
+
+// allows reports whether findings of severity go to the named notifier
+func (r alertRoutes) allows(severity, notifier string) bool {
+	route, ok := r[severity]
+	if !ok {
+		route = r["*"]
+	}
+	return route["*"] || route[notifier]
+}
+
+// logActive logs which severities each configured notifier receives
+func (r alertRoutes) logActive(notifiers []Notifier) {
+	for _, n := range notifiers {
+		var routed []string
+		for _, severity := range severities {
+			if r.allows(severity, n.Name()) {
+				routed = append(routed, severity)
+			}
+		}
+		if len(routed) == 0 {
+			log.Printf("Warning: ALERT_ROUTES sends no findings to notifier %s", n.Name())
+			continue
+		}
+		log.Printf("Notifier %s receives %s findings", n.Name(), strings.Join(routed, ", "))
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// SARIF 2.1.0 report, reduced to the parts the export command fills in.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type sarifReport struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Properties          map[string]string `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifLevel maps a finding severity to a SARIF result level
+func sarifLevel(severity string) string {
+	switch severity {
+	case SeverityCritical, SeverityHigh:
+		return "error"
+	case SeverityMedium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// writeFindingsSARIF writes findings as a SARIF 2.1.0 report with one rule
+// per key type and one result per finding, located at the post URL. The
+// masked key only appears in the result message; the key hash is the
+// fingerprint, so repeat leaks of a key are recognised as the same issue.
+func writeFindingsSARIF(w io.Writer, findings []FindingRecord) error {
+	rules := make(map[string]sarifRule)
+	results := make([]sarifResult, 0, len(findings))
+	for _, f := range findings {
+		level := sarifLevel(f.Severity)
+		if _, ok := rules[f.APIKeyType]; !ok {
+			rules[f.APIKeyType] = sarifRule{
+				ID:                   f.APIKeyType,
+				ShortDescription:     sarifMessage{Text: fmt.Sprintf("%s secret posted on Moltbook", f.APIKeyType)},
+				DefaultConfiguration: sarifConfiguration{Level: sarifLevel(getSeverity(f.APIKeyType))},
+			}
+		}
+
+		properties := map[string]string{
+			"severity":    f.Severity,
+			"foundAt":     f.FoundAt.UTC().Format(time.RFC3339),
+			"authorName":  f.AuthorName,
+			"submoltName": f.SubmoltName,
+			"findingId":   f.ID,
+		}
+		if f.SourceURL != "" {
+			properties["sourceUrl"] = f.SourceURL
+		}
+		if f.Location != "" {
+			properties["location"] = f.Location
+		}
+
+		results = append(results, sarifResult{
+			RuleID:  f.APIKeyType,
+			Level:   level,
+			Message: sarifMessage{Text: fmt.Sprintf("%s %s found in post %q by %s", f.APIKeyType, f.APIKeyMasked, f.PostTitle, f.AuthorName)},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: f.PostURL}},
+			}},
+			PartialFingerprints: map[string]string{"keySha256/v1": f.KeySHA256},
+			Properties:          properties,
+		})
+	}
+
+	driver := sarifDriver{
+		Name:           "moltbook-scanner",
+		InformationURI: "https://github.com/mathieubellon/moltbook-scanner",
+		Rules:          make([]sarifRule, 0, len(rules)),
+	}
+	for _, rule := range rules {
+		driver.Rules = append(driver.Rules, rule)
+	}
+	sort.Slice(driver.Rules, func(i, j int) bool { return driver.Rules[i].ID < driver.Rules[j].ID })
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifReport{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	})
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// schemaColumn is a column the scanner reads or writes
+type schemaColumn struct {
+	name, typ string
+}
+
+// expectedSchema lists, per table, the columns InitDatabase creates and the
+// types ClickHouse reports for them in system.columns. Keep it in sync with
+// the CREATE TABLE and ALTER TABLE statements.
+func (s *Scanner) expectedSchema() map[string][]schemaColumn {
+	findings := []schemaColumn{
+		{"id", "UUID"},
+		{"post_id", "String"},
+		{"post_title", "String"},
+		{"author_name", "String"},
+		{"submolt_name", "String"},
+		{"api_key", "String"},
+		{"api_key_type", "String"},
+		{"content", "String"},
+		{"post_url", "String"},
+		{"found_at", "DateTime64(3)"},
+		{"post_created_at", "DateTime64(3)"},
+		{"created_at", "DateTime64(3)"},
+		{"detection_latency_ms", "UInt64"},
+		{"encoding", "LowCardinality(String)"},
+		{"key_sha256", "String"},
+		{"source_url", "String"},
+		{"origin", "LowCardinality(String)"},
+		{"severity", "LowCardinality(String)"},
+		{"cycle_id", "String"},
+		{"location", "LowCardinality(String)"},
+		{"message_id", "String"},
+		{"occurrence_count", "UInt64"},
+		{"last_seen", "DateTime64(3)"},
+	}
+	schema := map[string][]schemaColumn{
+		"api_key_findings":    findings,
+		"findings_quarantine": append(append([]schemaColumn{}, findings...), schemaColumn{"confidence", "Float32"}),
+		"messages": {
+			{"id", "String"},
+			{"message_type", "LowCardinality(String)"},
+			{"post_id", "String"},
+			{"parent_id", "String"},
+			{"title", "String"},
+			{"content", "String"},
+			{"author_id", "String"},
+			{"author_name", "String"},
+			{"submolt_id", "String"},
+			{"submolt_name", "String"},
+			{"upvotes", "Int32"},
+			{"downvotes", "Int32"},
+			{"comment_count", "Int32"},
+			{"message_url", "String"},
+			{"created_at", "DateTime64(3)"},
+			{"scanned_at", "DateTime64(3)"},
+			{"has_api_key", "UInt8"},
+			{"api_key_types", "Array(String)"},
+			{"cycle_id", "String"},
+			{"content_sha256", "String"},
+		},
+		"notified_fingerprints": {
+			{"key_sha256", "String"},
+			{"notified_at", "DateTime64(3)"},
+		},
+	}
+	if s.rawPayloads != nil {
+		schema["raw_payloads"] = []schemaColumn{
+			{"id", "String"},
+			{"message_type", "LowCardinality(String)"},
+			{"payload", "String"},
+			{"fetched_at", "DateTime64(3)"},
+		}
+	}
+	if s.trackRevisions {
+		schema["message_revisions"] = []schemaColumn{
+			{"id", "String"},
+			{"revision", "String"},
+			{"content", "String"},
+			{"observed_at", "DateTime64(3)"},
+		}
+	}
+	if s.findingDedup != nil {
+		schema["finding_occurrences"] = []schemaColumn{
+			{"key_sha256", "String"},
+			{"found_at", "DateTime64(3)"},
+			{"occurrences", "SimpleAggregateFunction(sum, UInt64)"},
+			{"last_seen", "SimpleAggregateFunction(max, DateTime64(3))"},
+		}
+	}
+	if s.createViews {
+		schema["unique_keys"] = []schemaColumn{
+			{"key_sha256", "String"},
+			{"api_key_type", "SimpleAggregateFunction(any, String)"},
+			{"first_seen", "SimpleAggregateFunction(min, DateTime64(3))"},
+			{"last_seen", "SimpleAggregateFunction(max, DateTime64(3))"},
+			{"occurrence_count", "SimpleAggregateFunction(sum, UInt64)"},
+			{"highest_severity", "SimpleAggregateFunction(max, " + severityEnum + ")"},
+		}
+	}
+	return schema
+}
+
+// ValidateSchema checks that every table has the columns the scanner
+// expects with the expected types, so a table created by hand with a
+// different schema fails at startup instead of on the first insert.
+// Extra columns are allowed.
+func (s *Scanner) ValidateSchema(ctx context.Context) error {
+	schema := s.expectedSchema()
+
+	names := make([]string, 0, len(schema))
+	for name := range schema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		actual, err := s.tableColumns(ctx, s.tablePrefix+name)
+		if err != nil {
+			return err
+		}
+		table := s.databaseName + "." + s.tablePrefix + name
+		if len(actual) == 0 {
+			problems = append(problems, fmt.Sprintf("table %s does not exist", table))
+			continue
+		}
+		for _, col := range schema[name] {
+			typ, ok := actual[col.name]
+			switch {
+			case !ok:
+				problems = append(problems, fmt.Sprintf("%s: missing column %s %s", table, col.name, col.typ))
+			case typ != col.typ:
+				problems = append(problems, fmt.Sprintf("%s: column %s is %s, expected %s", table, col.name, typ, col.typ))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("schema mismatch:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// tableColumns returns the column types of table in the scanner's database
+func (s *Scanner) tableColumns(ctx context.Context, table string) (map[string]string, error) {
+	rows, err := s.clickhouseConn.Query(ctx,
+		`SELECT name, type FROM system.columns WHERE database = ? AND table = ?`, s.databaseName, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]string)
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return nil, fmt.Errorf("failed to scan column of %s: %w", table, err)
+		}
+		columns[name] = typ
+	}
+	return columns, rows.Err()
+}
@@ -0,0 +1,538 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// seenSet records which message IDs have already been scanned, and with
+// which content
+type seenSet interface {
+	Has(id string) bool
+	Add(id string)
+	// TryAddVersion records that id was scanned with the content
+	// fingerprinted by hash and reports how that relates to what the set
+	// held for id before
+	TryAddVersion(id, hash string) messageVersion
+	Len() int
+	Stats() seenStats
+}
+
+// seenStats describes a seen set for /status
+type seenStats struct {
+	Backend string `json:"backend"`
+	Entries int    `json:"entries"`
+	// Added counts IDs added since startup, including those loaded from
+	// the database
+	Added uint64 `json:"added_total"`
+
+	// Bloom filter and LRU only
+	Capacity int `json:"capacity,omitempty"`
+
+	// Map only
+	Shards int `json:"shards,omitempty"`
+
+	// LRU only
+	TTL string `json:"ttl,omitempty"`
+
+	// Bloom filter only
+	Filters                    int     `json:"filters,omitempty"`
+	FillRatio                  float64 `json:"fill_ratio,omitempty"`
+	EstimatedFalsePositiveRate float64 `json:"estimated_false_positive_rate,omitempty"`
+}
+
+// seenConfig selects and sizes the seen set backend
+type seenConfig struct {
+	Backend    string
+	Shards     int           // map: independently locked partitions
+	Capacity   int           // bloom: expected number of IDs
+	FPRate     float64       // bloom: target false-positive rate
+	MaxEntries int           // lru: maximum IDs kept
+	TTL        time.Duration // lru: how long an ID is remembered
+}
+
+// newSeenSet builds the seen set selected by SEEN_BACKEND:
+//
+//   - "map" (default) keeps every ID exactly. Memory grows without bound,
+//     but a new message is never mistaken for an old one. IDs are spread
+//     over shards maps, each with its own lock, so concurrent lookups
+//     rarely wait on each other.
+//   - "bloom" uses a scalable bloom filter sized for capacity IDs at the
+//     given false-positive rate. Memory stays roughly fixed per capacity
+//     step, at the cost that a fpRate fraction of genuinely new messages
+//     are treated as already seen and never scanned.
+//   - "lru" keeps only the most recently seen IDs, up to a maximum count
+//     and age. It has no false positives, but a message that reappears in
+//     the feed after being forgotten is scanned again.
+func newSeenSet(cfg seenConfig) (*syncSeenSet, error) {
+	switch strings.ToLower(cfg.Backend) {
+	case "", "map":
+		if cfg.Shards <= 0 {
+			return nil, fmt.Errorf("SEEN_SHARDS must be positive, got %d", cfg.Shards)
+		}
+		sets := make([]seenSet, cfg.Shards)
+		for i := range sets {
+			sets[i] = newMapSeenSet()
+		}
+		return newSyncSeenSet(sets, false), nil
+	case "bloom":
+		if cfg.Capacity <= 0 {
+			return nil, fmt.Errorf("SEEN_CAPACITY must be positive, got %d", cfg.Capacity)
+		}
+		if cfg.FPRate <= 0 || cfg.FPRate >= 1 {
+			return nil, fmt.Errorf("SEEN_FP_RATE must be between 0 and 1, got %g", cfg.FPRate)
+		}
+		return newSyncSeenSet([]seenSet{newBloomSeenSet(cfg.Capacity, cfg.FPRate)}, false), nil
+	case "lru":
+		if cfg.MaxEntries <= 0 {
+			return nil, fmt.Errorf("SEEN_MAX_ENTRIES must be positive, got %d", cfg.MaxEntries)
+		}
+		if cfg.TTL <= 0 {
+			return nil, fmt.Errorf("SEEN_TTL must be positive, got %s", cfg.TTL)
+		}
+		// The LRU reorders entries on reads, so even Has takes the write lock
+		return newSyncSeenSet([]seenSet{newLRUSeenSet(cfg.MaxEntries, cfg.TTL)}, true), nil
+	default:
+		return nil, fmt.Errorf("invalid SEEN_BACKEND %q: must be \"map\", \"bloom\" or \"lru\"", cfg.Backend)
+	}
+}
+
+// syncSeenSet guards a seen set for use from several goroutines, such as
+// the post and comment scan loops and the /status handler. The set may be
+// split into shards, each behind its own lock and holding the IDs that
+// hash to it, so goroutines touching different IDs don't contend.
+type syncSeenSet struct {
+	shards    []*seenShard
+	exclusive bool // the sets mutate on Has, so reads need the write lock
+	added     atomic.Uint64
+}
+
+// seenShard is one lock and the part of the seen set it guards
+type seenShard struct {
+	mu  sync.RWMutex
+	set seenSet
+}
+
+func newSyncSeenSet(sets []seenSet, exclusive bool) *syncSeenSet {
+	s := &syncSeenSet{shards: make([]*seenShard, len(sets)), exclusive: exclusive}
+	for i, set := range sets {
+		s.shards[i] = &seenShard{set: set}
+	}
+	return s
+}
+
+// shardIndex returns the index of the shard holding id
+func (s *syncSeenSet) shardIndex(id string) int {
+	if len(s.shards) == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() % uint32(len(s.shards)))
+}
+
+func (s *syncSeenSet) Has(id string) bool {
+	shard := s.shards[s.shardIndex(id)]
+	if s.exclusive {
+		shard.mu.Lock()
+		defer shard.mu.Unlock()
+	} else {
+		shard.mu.RLock()
+		defer shard.mu.RUnlock()
+	}
+	return shard.set.Has(id)
+}
+
+func (s *syncSeenSet) Add(id string) {
+	shard := s.shards[s.shardIndex(id)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	s.add(shard, id)
+}
+
+// messageVersion is how a fetched message relates to what was scanned before
+type messageVersion int
+
+const (
+	versionSeen   messageVersion = iota // this exact content was already scanned
+	versionNew                          // the message ID was never seen
+	versionEdited                       // the ID was seen, but with other content
+)
+
+// TryAddVersion records that message id was scanned with the content
+// fingerprinted by hash (see contentHash). Checking and adding under the
+// shard's lock stops the post and comment scan paths from both processing
+// the same version.
+func (s *syncSeenSet) TryAddVersion(id, hash string) messageVersion {
+	shard := s.shards[s.shardIndex(id)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	version := shard.set.TryAddVersion(id, hash)
+	if version == versionNew {
+		s.countAdded()
+	}
+	return version
+}
+
+// contentHash fingerprints the text of a message for TryAddVersion. It
+// must stay in sync with messageHashExpr, which computes it in SQL for
+// messages saved without content_sha256.
+func contentHash(title, content string) string {
+	sum := sha256.Sum256([]byte(title + "\x00" + content))
+	return hex.EncodeToString(sum[:])
+}
+
+// add adds id to shard, the shard for id, if it is not present, counting
+// it in the added metric. The caller must hold the shard's write lock.
+func (s *syncSeenSet) add(shard *seenShard, id string) bool {
+	if shard.set.Has(id) {
+		return false
+	}
+	shard.set.Add(id)
+	s.countAdded()
+	return true
+}
+
+// countAdded counts an ID added to the set
+func (s *syncSeenSet) countAdded() {
+	s.added.Add(1)
+	seenMessagesAddedTotal.Inc()
+}
+
+func (s *syncSeenSet) Len() int {
+	n := 0
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		n += shard.set.Len()
+		shard.mu.RUnlock()
+	}
+	return n
+}
+
+func (s *syncSeenSet) Stats() seenStats {
+	var stats seenStats
+	for i, shard := range s.shards {
+		shard.mu.RLock()
+		shardStats := shard.set.Stats()
+		shard.mu.RUnlock()
+		if i == 0 {
+			stats = shardStats
+		} else {
+			stats.Entries += shardStats.Entries
+		}
+	}
+	if len(s.shards) > 1 {
+		stats.Shards = len(s.shards)
+	}
+	stats.Added = s.added.Load()
+	return stats
+}
+
+// recordMetrics sets the seen set size gauge to the number of distinct
+// message IDs held
+func (s *syncSeenSet) recordMetrics() {
+	seenMessagesGauge.Set(float64(s.Len()))
+}
+
+// mapSeenSet is the exact seen set, mapping each ID to the hash of the
+// content it was last scanned with ("" if added without one)
+type mapSeenSet struct {
+	ids map[string]string
+}
+
+func newMapSeenSet() *mapSeenSet {
+	return &mapSeenSet{ids: make(map[string]string)}
+}
+
+func (m *mapSeenSet) Has(id string) bool {
+	_, ok := m.ids[id]
+	return ok
+}
+
+func (m *mapSeenSet) Add(id string) {
+	if !m.Has(id) {
+		m.ids[id] = ""
+	}
+}
+
+func (m *mapSeenSet) TryAddVersion(id, hash string) messageVersion {
+	old, ok := m.ids[id]
+	m.ids[id] = hash
+	return compareVersion(old, ok, hash)
+}
+
+func (m *mapSeenSet) Len() int { return len(m.ids) }
+
+func (m *mapSeenSet) Stats() seenStats {
+	return seenStats{Backend: "map", Entries: len(m.ids)}
+}
+
+// compareVersion tells what a scan with content hash is for an ID that
+// was last scanned with old, if ok
+func compareVersion(old string, ok bool, hash string) messageVersion {
+	switch {
+	case !ok:
+		return versionNew
+	case old == hash:
+		return versionSeen
+	default:
+		return versionEdited
+	}
+}
+
+// bloomSeenSet is a scalable bloom filter: when the current filter reaches
+// its capacity a new one with twice the capacity and half the
+// false-positive rate is added, keeping the overall rate below 2x the
+// configured one. Its entries are message IDs, counted once however many
+// versions of the message were added.
+type bloomSeenSet struct {
+	filters  []*bloomFilter
+	capacity int
+	fpRate   float64
+	entries  int
+}
+
+// newBloomSeenSet sizes the first filter for capacity messages. A filter
+// can't map an ID to a hash, so TryAddVersion adds both the ID and
+// "id#hash", and the filter holds two keys per message.
+func newBloomSeenSet(capacity int, fpRate float64) *bloomSeenSet {
+	return &bloomSeenSet{
+		filters:  []*bloomFilter{newBloomFilter(2*capacity, fpRate/2)},
+		capacity: capacity,
+		fpRate:   fpRate,
+	}
+}
+
+func (b *bloomSeenSet) Has(id string) bool {
+	h1, h2 := bloomHashes(id)
+	for _, f := range b.filters {
+		if f.has(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *bloomSeenSet) Add(id string) {
+	if b.Has(id) {
+		return
+	}
+	b.addKey(id)
+	b.entries++
+}
+
+func (b *bloomSeenSet) TryAddVersion(id, hash string) messageVersion {
+	version := id + "#" + hash
+	if b.Has(version) {
+		return versionSeen
+	}
+	b.addKey(version)
+	if b.Has(id) {
+		return versionEdited
+	}
+	b.addKey(id)
+	b.entries++
+	return versionNew
+}
+
+// addKey adds key to the current filter, starting a new one when it is full
+func (b *bloomSeenSet) addKey(key string) {
+	current := b.filters[len(b.filters)-1]
+	if current.count >= current.capacity {
+		current = newBloomFilter(current.capacity*2, current.fpRate/2)
+		b.filters = append(b.filters, current)
+	}
+	h1, h2 := bloomHashes(key)
+	current.add(h1, h2)
+}
+
+func (b *bloomSeenSet) Len() int { return b.entries }
+
+func (b *bloomSeenSet) Stats() seenStats {
+	var setBits, totalBits uint64
+	notFalsePositive := 1.0
+	for _, f := range b.filters {
+		set := f.setBits()
+		setBits += set
+		totalBits += f.m
+		fill := float64(set) / float64(f.m)
+		notFalsePositive *= 1 - math.Pow(fill, float64(f.k))
+	}
+
+	return seenStats{
+		Backend:                    "bloom",
+		Entries:                    b.entries,
+		Capacity:                   b.capacity,
+		Filters:                    len(b.filters),
+		FillRatio:                  float64(setBits) / float64(totalBits),
+		EstimatedFalsePositiveRate: 1 - notFalsePositive,
+	}
+}
+
+// bloomFilter is a fixed-size bloom filter using double hashing
+type bloomFilter struct {
+	bits     []uint64
+	m        uint64 // number of bits
+	k        uint64 // number of hash functions
+	capacity int
+	fpRate   float64
+	count    int
+}
+
+func newBloomFilter(capacity int, fpRate float64) *bloomFilter {
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Max(1, math.Round(float64(m)/float64(capacity)*math.Ln2)))
+	return &bloomFilter{
+		bits:     make([]uint64, (m+63)/64),
+		m:        m,
+		k:        k,
+		capacity: capacity,
+		fpRate:   fpRate,
+	}
+}
+
+func (f *bloomFilter) add(h1, h2 uint64) {
+	for i := uint64(0); i < f.k; i++ {
+		pos := (h1 + i*h2) % f.m
+		f.bits[pos/64] |= 1 << (pos % 64)
+	}
+	f.count++
+}
+
+func (f *bloomFilter) has(h1, h2 uint64) bool {
+	for i := uint64(0); i < f.k; i++ {
+		pos := (h1 + i*h2) % f.m
+		if f.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *bloomFilter) setBits() uint64 {
+	var n int
+	for _, word := range f.bits {
+		n += bits.OnesCount64(word)
+	}
+	return uint64(n)
+}
+
+// bloomHashes derives the two base hashes used for double hashing
+func bloomHashes(id string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	h1 := h.Sum64()
+	h.Write([]byte{0})
+	h2 := h.Sum64() | 1 // odd, so successive probes don't cycle early
+	return h1, h2
+}
+
+// lruSeenSet remembers the most recently seen IDs, forgetting the oldest
+// once maxEntries is exceeded and any ID not seen for ttl
+type lruSeenSet struct {
+	maxEntries int
+	ttl        time.Duration
+	order      *list.List // front is most recently seen
+	entries    map[string]*list.Element
+	now        func() time.Time
+}
+
+type lruEntry struct {
+	id     string
+	hash   string // of the content the ID was last scanned with
+	seenAt time.Time
+}
+
+func newLRUSeenSet(maxEntries int, ttl time.Duration) *lruSeenSet {
+	return &lruSeenSet{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+func (l *lruSeenSet) Has(id string) bool {
+	return l.touch(id) != nil
+}
+
+func (l *lruSeenSet) Add(id string) {
+	if l.touch(id) == nil {
+		l.push(&lruEntry{id: id})
+	}
+}
+
+func (l *lruSeenSet) TryAddVersion(id, hash string) messageVersion {
+	entry := l.touch(id)
+	if entry == nil {
+		l.push(&lruEntry{id: id, hash: hash})
+		return versionNew
+	}
+	old := entry.hash
+	entry.hash = hash
+	return compareVersion(old, true, hash)
+}
+
+// touch returns the entry of id, moved to the front and marked seen now,
+// or nil if id is unknown or expired. Every lookup goes through it, so an
+// ID that stays in the feed is never forgotten while older ones are.
+func (l *lruSeenSet) touch(id string) *lruEntry {
+	el, ok := l.entries[id]
+	if !ok {
+		return nil
+	}
+	entry := el.Value.(*lruEntry)
+	now := l.now()
+	if now.Sub(entry.seenAt) > l.ttl {
+		l.remove(el)
+		return nil
+	}
+	entry.seenAt = now
+	l.order.MoveToFront(el)
+	return entry
+}
+
+// push adds a new entry seen now and evicts what no longer fits
+func (l *lruSeenSet) push(entry *lruEntry) {
+	now := l.now()
+	entry.seenAt = now
+	l.entries[entry.id] = l.order.PushFront(entry)
+	l.evict(now)
+}
+
+// evict drops entries beyond maxEntries and expired ones from the back
+func (l *lruSeenSet) evict(now time.Time) {
+	for back := l.order.Back(); back != nil; back = l.order.Back() {
+		if l.order.Len() <= l.maxEntries && now.Sub(back.Value.(*lruEntry).seenAt) <= l.ttl {
+			return
+		}
+		l.remove(back)
+	}
+}
+
+func (l *lruSeenSet) remove(el *list.Element) {
+	l.order.Remove(el)
+	delete(l.entries, el.Value.(*lruEntry).id)
+}
+
+func (l *lruSeenSet) Len() int { return l.order.Len() }
+
+func (l *lruSeenSet) Stats() seenStats {
+	return seenStats{
+		Backend:  "lru",
+		Entries:  l.order.Len(),
+		Capacity: l.maxEntries,
+		TTL:      l.ttl.String(),
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTryAddVersion(t *testing.T) {
+	for _, backend := range []string{"map", "bloom", "lru"} {
+		t.Run(backend, func(t *testing.T) {
+			seen, err := newSeenSet(seenConfig{
+				Backend:    backend,
+				Shards:     4,
+				Capacity:   1000,
+				FPRate:     0.001,
+				MaxEntries: 1000,
+				TTL:        time.Hour,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			steps := []struct {
+				id, hash string
+				want     messageVersion
+			}{
+				{"x", "h1", versionNew},
+				{"x", "h1", versionSeen},
+				{"x", "h2", versionEdited},
+				{"x", "h2", versionSeen},
+				{"y", "h1", versionNew},
+			}
+			for _, step := range steps {
+				if got := seen.TryAddVersion(step.id, step.hash); got != step.want {
+					t.Errorf("TryAddVersion(%q, %q) = %d, want %d", step.id, step.hash, got, step.want)
+				}
+			}
+
+			if n := seen.Len(); n != 2 {
+				t.Errorf("Len() = %d, want 2 distinct IDs", n)
+			}
+			if stats := seen.Stats(); stats.Entries != 2 || stats.Added != 2 {
+				t.Errorf("Stats() entries = %d, added = %d, want 2 and 2", stats.Entries, stats.Added)
+			}
+		})
+	}
+}
+
+func TestLRUSeenSetCountsMessages(t *testing.T) {
+	l := newLRUSeenSet(2, time.Hour)
+	l.TryAddVersion("a", "h1")
+	l.TryAddVersion("a", "h2")
+	l.TryAddVersion("b", "h1")
+	if !l.Has("a") || !l.Has("b") {
+		t.Errorf("an edit took a second slot: a=%t b=%t with SEEN_MAX_ENTRIES 2", l.Has("a"), l.Has("b"))
+	}
+}
+
+func TestLRUSeenSetRefreshesOnHit(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newLRUSeenSet(2, time.Hour)
+	l.now = func() time.Time { return now }
+
+	l.TryAddVersion("a", "h")
+	l.TryAddVersion("b", "h")
+	now = now.Add(40 * time.Minute)
+	if got := l.TryAddVersion("a", "h"); got != versionSeen {
+		t.Fatalf("TryAddVersion(a) = %d, want versionSeen", got)
+	}
+
+	// a was seen again, so it outlives the TTL counted from its first sighting
+	now = now.Add(40 * time.Minute)
+	if !l.Has("a") {
+		t.Error("a expired although it was seen within SEEN_TTL")
+	}
+	if l.Has("b") {
+		t.Error("b should have expired")
+	}
+
+	// Reading a makes it the most recently used, so d evicts c rather than a
+	l.TryAddVersion("c", "h")
+	l.Has("a")
+	l.TryAddVersion("d", "h")
+	if !l.Has("a") || l.Has("c") {
+		t.Errorf("eviction is not least recently used first: a=%t c=%t", l.Has("a"), l.Has("c"))
+	}
+}
+
+func TestSeenGaugeCountsDistinctIDs(t *testing.T) {
+	seen, err := newSeenSet(seenConfig{Backend: "map", Shards: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen.TryAddVersion("x", "h1")
+	seen.TryAddVersion("x", "h2")
+	seen.TryAddVersion("x", "h3")
+	seen.TryAddVersion("y", "h1")
+	seen.recordMetrics()
+
+	seenMessagesGauge.mu.Lock()
+	got := seenMessagesGauge.value
+	seenMessagesGauge.mu.Unlock()
+	if got != 2 {
+		t.Errorf("moltbook_seen_messages = %g, want 2", got)
+	}
+}
+
+// newFeedScanner returns a scanner that fetches its feed from a test
+// server serving *posts and writes to conn. Only posts are scanned.
+func newFeedScanner(t *testing.T, conn *fakeConn, posts *[]MoltbookPost) *Scanner {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(FeedResponse{Success: true, Posts: *posts})
+	}))
+	t.Cleanup(server.Close)
+
+	s := newPatternScanner()
+	s.moltbookAPIKey = newRotatingKey("test")
+	s.apiClient = server.Client()
+	s.baseURL = server.URL
+	s.maxRespBytes = defaultMaxResponseBytes
+	s.clickhouseConn = conn
+	s.writeLimiter = newWriteLimiter(1)
+	s.submoltFilter = newSubmoltFilter("", "", true)
+	s.activity = &activityCounters{}
+	s.trackRevisions = true
+	s.resetSeen(t)
+	return s
+}
+
+// resetSeen gives s an empty seen set, as after a restart
+func (s *Scanner) resetSeen(t *testing.T) {
+	t.Helper()
+	seen, err := newSeenSet(seenConfig{Backend: "map", Shards: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.seenMessages = seen
+}
+
+// latestMessageHashes answers the seen set query from the messages conn
+// saved, with the content_sha256 of the last version of each ID
+func latestMessageHashes(conn *fakeConn) [][]any {
+	hashes := make(map[string]string)
+	var ids []string
+	for _, st := range conn.inserts("messages") {
+		id := st.args[0].(string)
+		if _, ok := hashes[id]; !ok {
+			ids = append(ids, id)
+		}
+		hashes[id] = st.args[len(st.args)-1].(string)
+	}
+	var rows [][]any
+	for _, id := range ids {
+		rows = append(rows, []any{id, hashes[id]})
+	}
+	return rows
+}
+
+func TestEditedPostIsRescannedOnce(t *testing.T) {
+	ctx := context.Background()
+	conn := &fakeConn{}
+	conn.rows = func(query string, args []any) [][]any {
+		if strings.Contains(query, "argMax("+messageHashExpr) {
+			return latestMessageHashes(conn)
+		}
+		return nil
+	}
+	posts := []MoltbookPost{{ID: "p1", Title: "Hello", Content: "first version", CreatedAt: time.Now()}}
+	s := newFeedScanner(t, conn, &posts)
+
+	// scan runs a post scan and checks how many times p1 has been saved
+	// and how many revisions recorded
+	scan := func(step string, saves, revisions int) {
+		t.Helper()
+		if err := s.scanPosts(ctx); err != nil {
+			t.Fatalf("%s: scanPosts: %v", step, err)
+		}
+		if got := len(conn.inserts("messages")); got != saves {
+			t.Errorf("%s: message saved %d times, want %d", step, got, saves)
+		}
+		if got := len(conn.inserts("message_revisions")); got != revisions {
+			t.Errorf("%s: %d revisions recorded, want %d", step, got, revisions)
+		}
+	}
+
+	scan("first scan", 1, 0)
+	scan("unchanged", 1, 0)
+
+	posts[0].Content = "second version"
+	scan("edited", 2, 1)
+	if st := conn.inserts("message_revisions")[0]; st.args[0] != "p1" {
+		t.Errorf("revision recorded for %v, want p1", st.args[0])
+	}
+	scan("unchanged after edit", 2, 1)
+
+	// After a restart the seen set only knows what ClickHouse has stored
+	s.resetSeen(t)
+	n, err := s.loadSeenPartition(ctx, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("loadSeenPartition loaded %d messages, want 1", n)
+	}
+	scan("after restart", 2, 1)
+
+	posts[0].Content = "third version"
+	scan("edited after restart", 3, 2)
+}
+
+// BenchmarkSeenSet compares the sharded map seen set with a single shard,
+// i.e. one mutex, under parallel TryAddVersion calls. Run with -cpu to see
+// contention grow: go test -bench SeenSet -cpu 1,4,16
+func BenchmarkSeenSet(b *testing.B) {
+	for _, shards := range []int{1, 32} {
+		b.Run("shards="+strconv.Itoa(shards), func(b *testing.B) {
+			seen, err := newSeenSet(seenConfig{Backend: "map", Shards: shards})
+			if err != nil {
+				b.Fatal(err)
+			}
+			hash := contentHash("", "content")
+			var workers atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				prefix := "post-" + strconv.FormatInt(workers.Add(1), 10) + "-"
+				for i := 0; pb.Next(); i++ {
+					// Half the calls are new IDs, half repeat the last one
+					seen.TryAddVersion(prefix+strconv.Itoa(i/2), hash)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// originSelfTest tags the synthetic finding written by the selftest command
+const originSelfTest = "selftest"
+
+// selfTestStage is the outcome of one step of the selftest command
+type selfTestStage struct {
+	name string
+	err  error
+	skip string // why the stage didn't run, "" if it did
+}
+
+// runSelfTest pushes a post with a synthetic key through detection,
+// storage and, with -webhook, alert delivery, reads the finding back from
+// ClickHouse and deletes it again. Each stage is reported as PASS, FAIL or
+// SKIP; the command fails if any stage failed.
+func (s *Scanner) runSelfTest(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	webhookURL := fs.String("webhook", "", "test webhook to deliver the synthetic alert to (skipped if empty)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	suffix := hex.EncodeToString(b)
+	key := "moltbook_sk_selftest_" + suffix
+	post := MoltbookPost{
+		ID:        "selftest-" + suffix,
+		Title:     "moltbook-scanner self-test",
+		Content:   "Synthetic key for the scanner self-test, not a real credential: " + key,
+		CreatedAt: time.Now(),
+	}
+
+	var stages []selfTestStage
+	finding, err := s.selfTestDetect(post, key)
+	stages = append(stages, selfTestStage{name: "detect", err: err})
+
+	if err != nil {
+		stages = append(stages,
+			selfTestStage{name: "store", skip: "nothing detected"},
+			selfTestStage{name: "query", skip: "nothing detected"},
+			selfTestStage{name: "alert", skip: "nothing detected"},
+		)
+	} else {
+		finding.Origin = originSelfTest
+		err = s.SaveFinding(ctx, finding)
+		stages = append(stages, selfTestStage{name: "store", err: err})
+		if err != nil {
+			stages = append(stages, selfTestStage{name: "query", skip: "nothing stored"})
+		} else {
+			stages = append(stages, selfTestStage{name: "query", err: s.selfTestQuery(ctx, post.ID, hashKey(key))})
+		}
+
+		if *webhookURL == "" {
+			stages = append(stages, selfTestStage{name: "alert", skip: "no -webhook given"})
+		} else {
+			wh := &WebhookNotifier{url: *webhookURL, secret: os.Getenv("WEBHOOK_SECRET"), httpClient: s.httpClient}
+			stages = append(stages, selfTestStage{name: "alert", err: wh.deliver(ctx, finding)})
+		}
+
+		if err := s.selfTestCleanup(ctx, post.ID); err != nil {
+			log.Printf("Warning: failed to delete the self-test finding for post %s: %v", post.ID, err)
+		}
+	}
+
+	failed := 0
+	for _, stage := range stages {
+		switch {
+		case stage.skip != "":
+			log.Printf("selftest %-6s SKIP (%s)", stage.name, stage.skip)
+		case stage.err != nil:
+			failed++
+			log.Printf("selftest %-6s FAIL: %v", stage.name, stage.err)
+		default:
+			log.Printf("selftest %-6s PASS", stage.name)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d self-test stages failed", failed, len(stages))
+	}
+	return nil
+}
+
+// selfTestDetect scans post and returns the finding for key
+func (s *Scanner) selfTestDetect(post MoltbookPost, key string) (APIKeyFinding, error) {
+	for _, f := range s.ScanPost(post) {
+		if f.APIKey == key {
+			return f, nil
+		}
+	}
+	return APIKeyFinding{}, fmt.Errorf("synthetic Moltbook key not detected (is the Moltbook type disabled or allowlisted?)")
+}
+
+// selfTestQuery waits for the stored finding of post to be readable, which
+// with CLICKHOUSE_ASYNC_INSERT can take a moment after the insert returns
+func (s *Scanner) selfTestQuery(ctx context.Context, postID, keyHash string) error {
+	query := fmt.Sprintf(`SELECT count() FROM %s WHERE post_id = ? AND key_sha256 = ?`, s.table("api_key_findings"))
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var count uint64
+		if err := s.clickhouseConn.QueryRow(ctx, query, postID, keyHash).Scan(&count); err != nil {
+			return fmt.Errorf("failed to query finding: %w", err)
+		}
+		if count > 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("finding for post %s not found after 10s", postID)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// selfTestCleanup deletes the synthetic finding. Like promote's delete it is
+// a mutation, so the row may stay visible for a moment.
+func (s *Scanner) selfTestCleanup(ctx context.Context, postID string) error {
+	return s.clickhouseConn.Exec(ctx, fmt.Sprintf(`ALTER TABLE %s DELETE WHERE post_id = ? AND origin = ?`,
+		s.table("api_key_findings")), postID, originSelfTest)
+}
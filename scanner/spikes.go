@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+type cycleFindingsKey struct{}
+
+// cycleFindings counts the saved findings of one scan cycle by submolt
+type cycleFindings struct {
+	mu        sync.Mutex
+	bySubmolt map[string]int
+}
+
+// recordSubmoltFinding counts a saved finding from submolt, both in the
+// metrics and in the scan cycle of ctx, if any
+func recordSubmoltFinding(ctx context.Context, submolt string) {
+	if submolt == "" {
+		submolt = "unknown"
+	}
+	findingsBySubmoltTotal.Inc(submolt)
+
+	counts, ok := ctx.Value(cycleFindingsKey{}).(*cycleFindings)
+	if !ok {
+		return
+	}
+	counts.mu.Lock()
+	defer counts.mu.Unlock()
+	counts.bySubmolt[submolt]++
+}
+
+// findingRing holds a submolt's findings for the last cycles of a loop
+type findingRing struct {
+	counts []int
+	next   int
+	sum    int
+}
+
+// push replaces the oldest count with n
+func (r *findingRing) push(n int) {
+	r.sum += n - r.counts[r.next]
+	r.counts[r.next] = n
+	r.next = (r.next + 1) % len(r.counts)
+}
+
+// submoltSpikes flags submolts whose findings in a cycle jump well above
+// their moving average, e.g. when a submolt is used to dump leaked keys.
+// Each scan loop keeps its own baselines, as post and comment cycles see
+// different volumes.
+type submoltSpikes struct {
+	mu          sync.Mutex
+	window      int     // cycles in the moving average
+	multiple    float64 // of the baseline that counts as a spike
+	minFindings int     // below which a cycle is never a spike
+	cycles      map[string]int
+	history     map[string]map[string]*findingRing // loop to submolt to counts
+}
+
+// newSubmoltSpikesFromEnv returns nil if SUBMOLT_SPIKE_MULTIPLE is 0
+func newSubmoltSpikesFromEnv() *submoltSpikes {
+	multiple := getEnvFloatOrDefault("SUBMOLT_SPIKE_MULTIPLE", 5)
+	if multiple <= 0 {
+		return nil
+	}
+	return &submoltSpikes{
+		window:      max(getEnvIntOrDefault("SUBMOLT_SPIKE_WINDOW", 24), 1),
+		multiple:    multiple,
+		minFindings: max(getEnvIntOrDefault("SUBMOLT_SPIKE_MIN_FINDINGS", 5), 1),
+		cycles:      make(map[string]int),
+		history:     make(map[string]map[string]*findingRing),
+	}
+}
+
+// submoltSpike is a submolt whose findings in a cycle exceeded its baseline
+type submoltSpike struct {
+	submolt  string
+	findings int
+	baseline float64
+}
+
+// observe adds the per-submolt findings of one cycle of loop and returns
+// the submolts that spiked. Nothing is flagged until the loop has run a
+// full window of cycles, so startup doesn't look like a spike.
+func (d *submoltSpikes) observe(loop string, counts map[string]int) []submoltSpike {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	rings := d.history[loop]
+	if rings == nil {
+		rings = make(map[string]*findingRing)
+		d.history[loop] = rings
+	}
+	warm := d.cycles[loop] >= d.window
+	d.cycles[loop]++
+
+	var spikes []submoltSpike
+	for submolt, n := range counts {
+		ring := rings[submolt]
+		if ring == nil {
+			// A submolt without history had no findings in the window
+			ring = &findingRing{counts: make([]int, d.window)}
+			rings[submolt] = ring
+		}
+		baseline := float64(ring.sum) / float64(d.window)
+		if warm && n >= d.minFindings && float64(n) > d.multiple*baseline {
+			spikes = append(spikes, submoltSpike{submolt: submolt, findings: n, baseline: baseline})
+		}
+	}
+
+	for submolt, ring := range rings {
+		ring.push(counts[submolt])
+		if ring.sum == 0 {
+			// Quiet submolts need no history, which keeps the map small
+			delete(rings, submolt)
+		}
+	}
+
+	sort.Slice(spikes, func(i, j int) bool { return spikes[i].submolt < spikes[j].submolt })
+	return spikes
+}
+
+// checkSubmoltSpikes feeds the cycle's per-submolt findings to the spike
+// detector and logs the submolts that spiked
+func (s *Scanner) checkSubmoltSpikes(ctx context.Context, loop string) {
+	counts, ok := ctx.Value(cycleFindingsKey{}).(*cycleFindings)
+	if !ok || s.submoltSpikes == nil {
+		return
+	}
+	counts.mu.Lock()
+	defer counts.mu.Unlock()
+
+	for _, spike := range s.submoltSpikes.observe(loop, counts.bySubmolt) {
+		submoltSpikesTotal.Inc()
+		logCycle(ctx, "🚨 Findings spike in submolt %s: %d this %s cycle vs a baseline of %.1f (SUBMOLT_SPIKE_MULTIPLE=%g)",
+			spike.submolt, spike.findings, loop, spike.baseline, s.submoltSpikes.multiple)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// originSubmoltMeta tags findings in a submolt's display name or description
+const originSubmoltMeta = "submolt_meta"
+
+type SubmoltsResponse struct {
+	Success  bool      `json:"success"`
+	Submolts []Submolt `json:"submolts"`
+}
+
+// FetchSubmolts fetches every submolt with its metadata
+func (s *Scanner) FetchSubmolts(ctx context.Context) ([]Submolt, error) {
+	var submoltsResp SubmoltsResponse
+	if err := s.doRequest(ctx, s.baseURL+"/submolts", &submoltsResp); err != nil {
+		return nil, fmt.Errorf("failed to fetch submolts: %w", err)
+	}
+
+	if !submoltsResp.Success {
+		return nil, fmt.Errorf("API returned success=false")
+	}
+
+	return submoltsResp.Submolts, nil
+}
+
+// scanSubmolts scans the display name and description of each submolt for
+// keys. Submolt metadata rarely changes, so this runs on its own, slower
+// POLL_INTERVAL_SUBMOLTS, and a submolt is only rescanned after its
+// metadata changed.
+func (s *Scanner) scanSubmolts(ctx context.Context) error {
+	ctx = withCycleID(ctx)
+	submolts, err := s.FetchSubmolts(ctx)
+	if err != nil {
+		return err
+	}
+
+	scanned, totalFindings, saveErrors := 0, 0, 0
+	for _, submolt := range submolts {
+		if s.stopping() {
+			break
+		}
+		if !s.submoltFilter.allows(&submolt) {
+			continue
+		}
+		hash := contentHash(submolt.DisplayName, submolt.Description)
+		if s.submoltMeta[submolt.Name] == hash {
+			continue
+		}
+		s.submoltMeta[submolt.Name] = hash
+		scanned++
+		s.processFindings(ctx, s.ScanSubmolt(submolt), &totalFindings, &saveErrors)
+	}
+
+	if totalFindings > 0 || saveErrors > 0 {
+		logCycle(ctx, "📊 Submolt scan complete: %d of %d submolts scanned, %d API keys found, %d save errors",
+			scanned, len(submolts), totalFindings, saveErrors)
+	} else {
+		logCycleDebug(ctx, "Submolt scan complete: %d of %d submolts scanned", scanned, len(submolts))
+	}
+	s.checkSubmoltSpikes(ctx, "Submolt")
+	return s.checkSaveErrors(ctx)
+}
+
+// ScanSubmolt scans a submolt's display name and description for API keys.
+// Findings have the submolt's name as SubmoltName and no post.
+func (s *Scanner) ScanSubmolt(submolt Submolt) []APIKeyFinding {
+	text := submolt.DisplayName + "\n" + submolt.Description
+	matches := s.ScanText(text)
+	keys := matchKeys(matches)
+	foundAt := s.now()
+
+	var findings []APIKeyFinding
+	for _, m := range matches {
+		findings = append(findings, APIKeyFinding{
+			PostTitle:   submolt.DisplayName + " (submolt)",
+			AuthorName:  "Unknown",
+			SubmoltName: submolt.Name,
+			APIKey:      storedValue(m),
+			APIKeyType:  m.Type,
+			Severity:    getSeverity(m.Type),
+			Confidence:  getConfidence(m.Type),
+			Encoding:    m.Encoding,
+			Content:     s.findingContent(m.source, m, keys),
+			PostURL:     fmt.Sprintf("https://www.moltbook.com/m/%s", submolt.Name),
+			FoundAt:     foundAt,
+			Origin:      originSubmoltMeta,
+		})
+	}
+	return findings
+}
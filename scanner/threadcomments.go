@@ -0,0 +1,52 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// threadComments remembers the comments the post loop fetched through their
+// post's thread since the last recent comments scan, with their content
+// hash, so that scan can skip the ones it gets again instead of tracking
+// them a second time. It only records while the recent comments loop runs.
+type threadComments struct {
+	active atomic.Bool
+	mu     sync.Mutex
+	hashes map[string]string
+}
+
+// add records a comment fetched through its post
+func (t *threadComments) add(comment MoltbookComment) {
+	if !t.active.Load() {
+		return
+	}
+	hash := contentHash("", comment.Content)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.hashes == nil {
+		t.hashes = make(map[string]string)
+	}
+	t.hashes[comment.ID] = hash
+}
+
+// take returns the comments recorded since the last take and starts afresh
+func (t *threadComments) take() map[string]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	hashes := t.hashes
+	t.hashes = nil
+	return hashes
+}
+
+// fetchedUnchanged reports whether comment was fetched through its post,
+// per hashes from take, with the same content
+func fetchedUnchanged(hashes map[string]string, comment MoltbookComment) bool {
+	hash, ok := hashes[comment.ID]
+	return ok && hash == contentHash("", comment.Content)
+}
+
+// stop stops recording and forgets what was recorded
+func (t *threadComments) stop() {
+	t.active.Store(false)
+	t.take()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// webhookTemplateFuncs are the functions available to WEBHOOK_TEMPLATE_FILE
+// templates besides the text/template builtins
+var webhookTemplateFuncs = template.FuncMap{
+	// json encodes a value as JSON, e.g. "text": {{json .PostTitle}}, so
+	// quotes and newlines in user content can't break the body
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// loadWebhookTemplate parses the webhook body template in path. It is
+// executed with a webhookPayload, so only masked key fields are available.
+// The template is checked against a sample finding and must render valid
+// JSON, so mistakes surface at startup rather than on the first finding.
+func loadWebhookTemplate(path string) (*template.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook template: %w", err)
+	}
+	tmpl, err := template.New("webhook").Funcs(webhookTemplateFuncs).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook template: %w", err)
+	}
+
+	sample := newWebhookPayload(APIKeyFinding{
+		PostID:        "00000000-0000-0000-0000-000000000000",
+		PostTitle:     `Sample "post"` + "\n",
+		AuthorName:    "SampleMolty",
+		SubmoltName:   "general",
+		APIKey:        "sk-sample0000000000000000000000",
+		APIKeyType:    "OpenAI",
+		Severity:      SeverityCritical,
+		PostURL:       "https://www.moltbook.com/post/00000000-0000-0000-0000-000000000000",
+		FoundAt:       time.Now(),
+		PostCreatedAt: time.Now(),
+	})
+	body, err := renderWebhookTemplate(tmpl, sample)
+	if err != nil {
+		return nil, fmt.Errorf("webhook template failed on a sample finding: %w", err)
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("webhook template does not render valid JSON for a sample finding (use {{json .Field}} to quote values)")
+	}
+	return tmpl, nil
+}
+
+// renderWebhookTemplate renders the webhook body for payload
+func renderWebhookTemplate(tmpl *template.Template, payload webhookPayload) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, payload); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
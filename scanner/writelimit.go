@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// defaultClickHouseMaxConcurrency matches the driver's default MaxOpenConns
+// (MaxIdleConns 5 + 5), so writes never queue on the connection pool
+const defaultClickHouseMaxConcurrency = 10
+
+// writeLimiter bounds how many ClickHouse inserts run at once, so bursts of
+// saves don't trip TOO_MANY_SIMULTANEOUS_QUERIES
+type writeLimiter struct {
+	slots    chan struct{}
+	inFlight atomic.Int64
+}
+
+func newWriteLimiter(limit int) *writeLimiter {
+	return &writeLimiter{slots: make(chan struct{}, max(limit, 1))}
+}
+
+// acquire waits for a free write slot or for ctx to be done
+func (l *writeLimiter) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		l.inFlight.Add(1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a slot taken by acquire
+func (l *writeLimiter) release() {
+	l.inFlight.Add(-1)
+	<-l.slots
+}
+
+// writeStats describes ClickHouse write concurrency for /status
+type writeStats struct {
+	InFlight int64 `json:"in_flight"`
+	Limit    int   `json:"limit"`
+}
+
+func (l *writeLimiter) stats() writeStats {
+	return writeStats{InFlight: l.inFlight.Load(), Limit: cap(l.slots)}
+}